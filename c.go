@@ -10,8 +10,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"reflect"
 	"regexp"
+	"time"
 
 	"github.com/DoNewsCode/core/codec/yaml"
 	"github.com/DoNewsCode/core/config"
@@ -35,7 +38,12 @@ type C struct {
 	logging.LevelLogger
 	contract.Container
 	contract.Dispatcher
-	di DiContainer
+	di       DiContainer
+	plan     *di.Plan
+	planOnly bool
+	startup  *StartupReport
+	// decorated holds the types of the fields above that have decorators
+	decorated map[reflect.Type]bool
 }
 
 // ConfParser models a parser for configuration. For example, yaml.Parser.
@@ -79,6 +87,8 @@ type coreValues struct {
 	appNameProvider         AppNameProvider
 	envProvider             EnvProvider
 	loggerProvider          LoggerProvider
+	// planOnly records the graph without invoking anything
+	planOnly bool
 }
 
 // CoreOption is the option to modify core attribute.
@@ -91,6 +101,41 @@ func WithYamlFile(path string) (CoreOption, CoreOption) {
 		WithConfigWatcher(watcher.File{Path: path})
 }
 
+// WithYamlProfiles is a two-in-one coreOption like WithYamlFile. On top of the
+// base file, it layers the env overlay (eg. config.production.yaml) and the
+// local overlay (config.local.yaml) found next to it, so that only the
+// differences need to be kept per environment. The env is resolved from the
+// "env" key of the base file. Overlays that don't exist are ignored. All files
+// are watched for hot reloading. See config.ProfileFiles for details.
+//
+// The env is resolved once, when the option is created. Changing env in a
+// reloaded file doesn't switch to another overlay. Restart the application
+// for that.
+func WithYamlProfiles(path string) (CoreOption, CoreOption) {
+	var (
+		env      config.Env
+		base     struct{ Env string }
+		files    = []string{path}
+		watchers = watcher.Multi{watcher.File{Path: path}}
+	)
+	if bytes, err := ioutil.ReadFile(path); err == nil {
+		_ = yaml.Codec{}.Unmarshal(bytes, &base)
+		env = config.NewEnv(base.Env)
+	}
+	for _, overlay := range config.ProfileFiles(path, env) {
+		files = append(files, overlay)
+		watchers = append(watchers, watcher.File{Path: overlay})
+	}
+	return func(values *coreValues) {
+			// The layer on top has higher priority, so add the overlays first.
+			for i := len(files) - 1; i > 0; i-- {
+				WithConfigStack(config.OptionalFile{Path: files[i]}, config.CodecParser{Codec: yaml.Codec{}})(values)
+			}
+			WithConfigStack(file.Provider(path), config.CodecParser{Codec: yaml.Codec{}})(values)
+		},
+		WithConfigWatcher(watchers)
+}
+
 // WithInline is a CoreOption that creates a inline config in the configuration stack.
 func WithInline(key string, entry interface{}) CoreOption {
 	return WithConfigStack(confmap.Provider(map[string]interface{}{
@@ -99,6 +144,8 @@ func WithInline(key string, entry interface{}) CoreOption {
 }
 
 // WithConfigStack is a CoreOption that defines a configuration layer. See package config for details.
+// If the provider implements io.Closer, such as the remote providers holding a
+// connection, it is closed when the core shuts down.
 func WithConfigStack(provider ConfProvider, parser ConfParser) CoreOption {
 	return func(values *coreValues) {
 		values.configStack = append(values.configStack, config.ProviderSet{Parser: parser, Provider: provider})
@@ -106,12 +153,34 @@ func WithConfigStack(provider ConfProvider, parser ConfParser) CoreOption {
 }
 
 // WithConfigWatcher is a CoreOption that adds a config watcher to the core (for hot reloading configs).
+// Like the providers of WithConfigStack, it is closed when the core shuts down
+// if it implements io.Closer.
 func WithConfigWatcher(watcher contract.ConfigWatcher) CoreOption {
 	return func(values *coreValues) {
 		values.configWatcher = watcher
 	}
 }
 
+// WithPlanOnly is a CoreOption that builds the dependency graph without
+// invoking any constructor. AddModuleFunc and Invoke only record their
+// functions in the plan, and the graph command is added to the core, so that
+// missing and cyclic dependencies are reported by the command rather than
+// panicking while the core is being wired. Use it when the graph command is
+// selected:
+//
+//  var opts []core.CoreOption
+//  if len(os.Args) > 1 && os.Args[1] == "graph" {
+//    opts = append(opts, core.WithPlanOnly())
+//  }
+//  c := core.Default(opts...)
+//
+// Nothing else works in this mode, as no module is constructed.
+func WithPlanOnly() CoreOption {
+	return func(values *coreValues) {
+		values.planOnly = true
+	}
+}
+
 // SetConfigProvider is a CoreOption to replaces the default ConfigProvider.
 func SetConfigProvider(provider ConfigProvider) CoreOption {
 	return func(values *coreValues) {
@@ -184,15 +253,58 @@ func New(opts ...CoreOption) *C {
 		Container:      &container.Container{},
 		Dispatcher:     dispatcher,
 		di:             diContainer,
+		plan:           di.NewPlan(),
+		planOnly:       values.planOnly,
+		startup:        &StartupReport{},
+	}
+	if c.planOnly {
+		c.AddModule(graphModule{plan: c.plan})
+	}
+	for _, closer := range values.configClosers() {
+		c.AddModule(cleanup(closer))
 	}
 	return &c
 }
 
+// configClosers returns the Close methods of the config providers and the
+// config watcher, each called once even if it is both.
+func (v coreValues) configClosers() []func() {
+	var (
+		closers []func()
+		seen    []io.Closer
+	)
+	candidates := []interface{}{v.configWatcher}
+	for _, set := range v.configStack {
+		candidates = append(candidates, set.Provider)
+	}
+	for _, candidate := range candidates {
+		closer, ok := candidate.(io.Closer)
+		if !ok || !reflect.TypeOf(closer).Comparable() || containsCloser(seen, closer) {
+			continue
+		}
+		seen = append(seen, closer)
+		closers = append(closers, func() { _ = closer.Close() })
+	}
+	return closers
+}
+
+func containsCloser(closers []io.Closer, closer io.Closer) bool {
+	for _, c := range closers {
+		if c == closer {
+			return true
+		}
+	}
+	return false
+}
+
 // Default creates a core.C under its default state. Core dependencies are
-// already provided, and the config module and serve module are bundled.
+// already provided, and the config module and serve module are bundled. The
+// registered modules enabled by the config are added as well, see
+// RegisterModule and ModuleSet.
 func Default(opts ...CoreOption) *C {
 	c := New(opts...)
 	c.ProvideEssentials()
+	c.addRegisteredModules()
 	return c
 }
 
@@ -249,9 +361,6 @@ func (c *C) Provide(deps di.Deps) {
 }
 
 func (c *C) provide(constructor interface{}) {
-
-	var shouldMakeFunc bool
-
 	ftype := reflect.TypeOf(constructor)
 	if ftype == nil {
 		panic("can't provide an untyped nil")
@@ -259,43 +368,38 @@ func (c *C) provide(constructor interface{}) {
 	if ftype.Kind() != reflect.Func {
 		panic(fmt.Sprintf("must provide constructor function, got %v (type %v)", constructor, ftype))
 	}
+	c.plan.Provide(constructor)
+	desc, _ := di.Describe(constructor)
 
 	inTypes := make([]reflect.Type, 0)
 	outTypes := make([]reflect.Type, 0)
 	for i := 0; i < ftype.NumOut(); i++ {
 		outT := ftype.Out(i)
 		if isCleanup(outT) {
-			shouldMakeFunc = true
 			continue
 		}
-		if isModule(outT) {
-			shouldMakeFunc = true
-		}
 		outTypes = append(outTypes, outT)
 	}
 
 	for i := 0; i < ftype.NumIn(); i++ {
-		inT := ftype.In(i)
-		if isModule(inT) {
-			shouldMakeFunc = true
-		}
-		inTypes = append(inTypes, inT)
+		inTypes = append(inTypes, ftype.In(i))
 	}
 
-	// no cleanup or module, we can use normal dig.
-	if !shouldMakeFunc {
-		err := c.di.Provide(constructor)
-		if err != nil {
-			panic(err)
-		}
-		return
-	}
-
-	// has cleanup or module, use reflect.MakeFunc as interceptor.
+	// use reflect.MakeFunc as interceptor, to time the constructor and to
+	// collect the cleanup functions and modules.
 	fnType := reflect.FuncOf(inTypes, outTypes, ftype.IsVariadic() /* variadic */)
 	fn := reflect.MakeFunc(fnType, func(args []reflect.Value) []reflect.Value {
-		filteredOuts := make([]reflect.Value, 0)
-		outVs := reflect.ValueOf(constructor).Call(args)
+		var (
+			filteredOuts = make([]reflect.Value, 0)
+			outVs        []reflect.Value
+			start        = time.Now()
+		)
+		if ftype.IsVariadic() {
+			outVs = reflect.ValueOf(constructor).CallSlice(args)
+		} else {
+			outVs = reflect.ValueOf(constructor).Call(args)
+		}
+		timing := ConstructorTiming{Name: desc.Name, Location: desc.Location, Duration: time.Since(start)}
 		for _, v := range outVs {
 			vType := v.Type()
 			if isCleanup(vType) {
@@ -305,8 +409,16 @@ func (c *C) provide(constructor interface{}) {
 			if isModule(vType) {
 				c.AddModule(v.Interface().(di.Modular).Module())
 			}
+			if vType == _errType && !v.IsNil() {
+				// dig reports the error as coming from reflect.makeFuncStub,
+				// so name the constructor in the error instead.
+				timing.Err = v.Interface().(error)
+				err := fmt.Errorf("%s (%s): %w", desc.Name, desc.Location, timing.Err)
+				v = reflect.ValueOf(&err).Elem()
+			}
 			filteredOuts = append(filteredOuts, v)
 		}
+		c.startup.record(timing)
 		return filteredOuts
 	})
 	err := c.di.Provide(fn.Interface())
@@ -331,6 +443,9 @@ func (c *C) ProvideEssentials() {
 		LevelLogger       logging.LevelLogger
 		Dispatcher        contract.Dispatcher
 		DefaultConfigs    []config.ExportedConfig `group:"config,flatten"`
+		Plan              *di.Plan
+		StartupReport     *StartupReport
+		Preload           preloadFunc
 	}
 
 	c.provide(func() coreDependencies {
@@ -344,6 +459,9 @@ func (c *C) ProvideEssentials() {
 			LevelLogger:       c.LevelLogger,
 			Dispatcher:        c.Dispatcher,
 			DefaultConfigs:    provideDefaultConfig(),
+			Plan:              c.plan,
+			StartupReport:     c.startup,
+			Preload:           c.preloadFactories,
 		}
 		if cc, ok := c.ConfigAccessor.(contract.ConfigRouter); ok {
 			coreDependencies.ConfigRouter = cc
@@ -355,19 +473,50 @@ func (c *C) ProvideEssentials() {
 	})
 }
 
+// preloadFunc makes the connections of the factories whose kinds are listed at
+// "startup.eager". It is called by the serve command. See di.Factory.Preload.
+type preloadFunc func() error
+
+// preloadFactories constructs the factories provided to the "factories" group
+// and preloads them, unless no kind is eager.
+func (c *C) preloadFactories() error {
+	var eager []string
+	_ = c.ConfigAccessor.Unmarshal("startup.eager", &eager)
+	if len(eager) == 0 {
+		return nil
+	}
+	type factoriesIn struct {
+		di.In
+
+		Factories []*di.Factory `group:"factories"`
+	}
+	return c.invoke(func(in factoriesIn) error {
+		for _, factory := range in.Factories {
+			if err := factory.Preload(c.ConfigAccessor); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
 // Serve runs the serve command bundled in the core.
 // For larger projects, consider use full-featured ServeModule instead of calling serve directly.
 func (c *C) Serve(ctx context.Context) error {
-	return c.di.Invoke(func(in serveIn) error {
+	return c.invoke(func(in serveIn) error {
 		cmd := newServeCmd(in)
 		return cmd.ExecuteContext(ctx)
 	})
 }
 
 // AddModuleFunc add the module after Invoking its' constructor. Clean up
-// functions and errors are handled automatically.
+// functions and errors are handled automatically. With WithPlanOnly, the
+// constructor is only recorded.
 func (c *C) AddModuleFunc(constructor interface{}) {
 	c.provide(constructor)
+	if c.planOnly {
+		return
+	}
 	ftype := reflect.TypeOf(constructor)
 	targetTypes := make([]reflect.Type, 0)
 	for i := 0; i < ftype.NumOut(); i++ {
@@ -389,7 +538,7 @@ func (c *C) AddModuleFunc(constructor interface{}) {
 		return nil
 	})
 
-	err := c.di.Invoke(fn.Interface())
+	err := c.invoke(fn.Interface())
 	if err != nil {
 		panic(err)
 	}
@@ -403,15 +552,140 @@ func (c *C) AddModuleFunc(constructor interface{}) {
 //
 // It internally calls uber's dig library. Consult dig's documentation for
 // details. (https://pkg.go.dev/go.uber.org/dig)
+//
+// With WithPlanOnly, the function is only recorded.
 func (c *C) Invoke(function interface{}) {
-	err := c.di.Invoke(function)
+	c.plan.Invoke(function)
+	if c.planOnly {
+		return
+	}
+	err := c.invoke(function)
 	if err != nil {
 		re := regexp.MustCompile(` missing dependencies for function "reflect"\.makeFuncStub \(.+?\):`)
-		err = errors.New(re.ReplaceAllString(err.Error(), ""))
+		msg := re.ReplaceAllString(err.Error(), "")
+		re = regexp.MustCompile(` from function "reflect"\.makeFuncStub \(.+?\):`)
+		err = errors.New(re.ReplaceAllString(msg, " from function"))
 		panic(err)
 	}
 }
 
+// Decorate wraps a dependency after its construction. The decorator must be a
+// function in the form of:
+//
+//  func(t T, deps...) T
+//
+// or return (T, error). For example, to wrap the logger handed to the modules:
+//
+//  c.Decorate(func(logger log.Logger, env contract.Env) log.Logger {
+//    return log.With(logger, "env", env.String())
+//  })
+//
+// Decorators of the same type are applied in the order of the calls, before the
+// dependency is handed to anyone. Therefore, Decorate panics if anything has
+// been invoked since the dependency was provided, including the modules added
+// by AddModuleFunc. With core.Default(), decorate the essentials before adding
+// the modules:
+//
+//  c := core.Default()
+//  c.Decorate(func(dispatcher contract.Dispatcher) contract.Dispatcher {
+//    return &auditDispatcher{Dispatcher: dispatcher}
+//  })
+//  c.AddModuleFunc(...)
+//
+// The decorated log.Logger, logging.LevelLogger and contract.Dispatcher are
+// also assigned to the fields of C on the next Invoke, so that c.Info and
+// c.Dispatch go through the decorators too. Decorators are listed by the graph
+// command.
+func (c *C) Decorate(decorator interface{}) {
+	decorable, ok := c.di.(interface {
+		Decorate(decorator interface{}) error
+	})
+	if !ok {
+		panic(fmt.Sprintf("%T does not support decorators", c.di))
+	}
+	if err := decorable.Decorate(decorator); err != nil {
+		panic(err)
+	}
+	c.plan.Decorate(decorator)
+	if t := reflect.TypeOf(decorator).In(0); isDecorableField(t) {
+		if c.decorated == nil {
+			c.decorated = make(map[reflect.Type]bool)
+		}
+		c.decorated[t] = true
+	}
+}
+
+var (
+	loggerType      = reflect.TypeOf((*log.Logger)(nil)).Elem()
+	levelLoggerType = reflect.TypeOf((*logging.LevelLogger)(nil)).Elem()
+	dispatcherType  = reflect.TypeOf((*contract.Dispatcher)(nil)).Elem()
+)
+
+func isDecorableField(t reflect.Type) bool {
+	return t == loggerType || t == levelLoggerType || t == dispatcherType
+}
+
+// invoke calls the function with the dependency graph, after syncing the
+// decorated fields of C with the graph.
+func (c *C) invoke(function interface{}) error {
+	if len(c.decorated) > 0 {
+		type fieldsIn struct {
+			di.In
+
+			Logger      log.Logger          `optional:"true"`
+			LevelLogger logging.LevelLogger `optional:"true"`
+			Dispatcher  contract.Dispatcher `optional:"true"`
+		}
+		err := c.di.Invoke(func(in fieldsIn) {
+			if c.decorated[loggerType] && in.Logger != nil {
+				c.LevelLogger = logging.WithLevel(in.Logger)
+			}
+			if c.decorated[levelLoggerType] && in.LevelLogger != nil {
+				c.LevelLogger = in.LevelLogger
+			}
+			if c.decorated[dispatcherType] && in.Dispatcher != nil {
+				c.Dispatcher = in.Dispatcher
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return c.di.Invoke(function)
+}
+
+// Scope returns a copy of C backed by a child scope of its dependency graph.
+// The overrides take the place of the providers of the same types in the copy,
+// while c is left untouched. This is useful to swap a dependency in tests, or
+// to wire a tenant differently. See di.Graph.Scope for details.
+//
+// The overrides are handed to the dependency graph as is, so they should not
+// return clean up functions or modules. The copy shares the rest of c, such as
+// the config and the modules.
+func (c *C) Scope(overrides di.Deps) (*C, error) {
+	scoper, ok := c.di.(interface {
+		Scope(overrides ...interface{}) (*di.Graph, error)
+	})
+	if !ok {
+		return nil, fmt.Errorf("%T does not support scopes", c.di)
+	}
+	child, err := scoper.Scope(overrides...)
+	if err != nil {
+		return nil, err
+	}
+	scoped := *c
+	scoped.di = child
+	scoped.plan = c.plan.Clone()
+	scoped.decorated = make(map[reflect.Type]bool, len(c.decorated))
+	for t := range c.decorated {
+		scoped.decorated[t] = true
+	}
+	for _, override := range overrides {
+		scoped.plan.Provide(override)
+	}
+	return &scoped, nil
+}
+
 func isCleanup(v reflect.Type) bool {
 	if v.Kind() == reflect.Func && v.NumIn() == 0 && v.NumOut() == 0 {
 		return true
@@ -2,6 +2,7 @@ package core
 
 import (
 	"context"
+	"errors"
 	"io/ioutil"
 	"os"
 	"strings"
@@ -17,6 +18,7 @@ import (
 	"github.com/DoNewsCode/core/srvgrpc"
 	"github.com/DoNewsCode/core/srvhttp"
 
+	"github.com/go-kit/kit/log"
 	"github.com/spf13/cobra"
 	"github.com/stretchr/testify/assert"
 )
@@ -66,6 +68,52 @@ func TestC_Serve(t *testing.T) {
 	assert.Equal(t, int32(4), atomic.LoadInt32(&called))
 }
 
+type preloadOut struct {
+	di.Out
+
+	Factories []*di.Factory `group:"factories,flatten"`
+}
+
+func TestC_Serve_preload(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		configs map[string]interface{}
+		made    []string
+		err     bool
+	}{
+		{"eager", map[string]interface{}{"default": nil}, []string{"default"}, false},
+		{"broken", map[string]interface{}{"default": nil, "broken": nil}, []string{"broken"}, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var made []string
+			c := New(
+				WithInline("http.disable", "true"),
+				WithInline("grpc.disable", "true"),
+				WithInline("cron.disable", "true"),
+				WithInline("startup.eager", []string{"preload"}),
+				WithInline("preload", tc.configs),
+			)
+			c.ProvideEssentials()
+			// Nothing injects the factory, yet serve preloads it.
+			c.Provide(di.Deps{func() preloadOut {
+				factory := di.NewFactory(func(name string) (di.Pair, error) {
+					made = append(made, name)
+					if name == "broken" {
+						return di.Pair{}, errors.New("broken")
+					}
+					return di.Pair{Conn: name, Closer: func() {}}, nil
+				}, di.WithConfigKind("preload"))
+				return preloadOut{Factories: []*di.Factory{factory}}
+			}})
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			err := c.Serve(ctx)
+			assert.Equal(t, tc.err, err != nil, err)
+			assert.Equal(t, tc.made, made)
+		})
+	}
+}
+
 func TestC_ServeDisable(t *testing.T) {
 	var called int32
 	c := New(
@@ -155,6 +203,104 @@ func TestC_Provide(t *testing.T) {
 	assert.Len(t, c.Modules(), 2)
 }
 
+type tenant struct {
+	Name string
+}
+
+type greeter struct {
+	Tenant tenant
+}
+
+func TestC_Scope(t *testing.T) {
+	c := New()
+	c.Provide(di.Deps{
+		func() tenant { return tenant{Name: "default"} },
+		func(t tenant) *greeter { return &greeter{Tenant: t} },
+	})
+
+	scoped, err := c.Scope(di.Deps{func() tenant { return tenant{Name: "foo"} }})
+	assert.NoError(t, err)
+	scoped.Invoke(func(g *greeter) {
+		assert.Equal(t, "foo", g.Tenant.Name)
+	})
+	c.Invoke(func(g *greeter) {
+		assert.Equal(t, "default", g.Tenant.Name)
+	})
+}
+
+func TestC_Scope_partial(t *testing.T) {
+	c := New()
+	c.ProvideEssentials()
+
+	// Overriding the logger keeps the other core dependencies.
+	logger := log.NewNopLogger()
+	scoped, err := c.Scope(di.Deps{func() log.Logger { return logger }})
+	assert.NoError(t, err)
+	scoped.Invoke(func(l log.Logger, env contract.Env, conf contract.ConfigAccessor) {
+		assert.Equal(t, logger, l)
+		assert.NotNil(t, env)
+		assert.NotNil(t, conf)
+	})
+}
+
+func TestC_Decorate(t *testing.T) {
+	c := Default()
+	c.Provide(di.Deps{func() tenant { return tenant{Name: "default"} }})
+	c.Decorate(func(tn tenant, env contract.Env) tenant {
+		tn.Name = tn.Name + "@" + env.String()
+		return tn
+	})
+	c.Decorate(func(d contract.Dispatcher) contract.Dispatcher {
+		return d
+	})
+	c.Invoke(func(tn tenant) {
+		assert.Equal(t, "default@local", tn.Name)
+	})
+	assert.Panics(t, func() {
+		c.Decorate(func(tn tenant) tenant { return tn })
+	})
+}
+
+type countingDispatcher struct {
+	contract.Dispatcher
+	count *int
+}
+
+func (d countingDispatcher) Dispatch(ctx context.Context, topic interface{}, payload interface{}) error {
+	*d.count++
+	return d.Dispatcher.Dispatch(ctx, topic, payload)
+}
+
+func TestC_Decorate_essentials(t *testing.T) {
+	var (
+		dispatched int
+		logged     []interface{}
+	)
+	c := New()
+	c.ProvideEssentials()
+	c.Decorate(func(d contract.Dispatcher) contract.Dispatcher {
+		return countingDispatcher{Dispatcher: d, count: &dispatched}
+	})
+	c.Decorate(func(logger log.Logger, env contract.Env) log.Logger {
+		return log.LoggerFunc(func(keyvals ...interface{}) error {
+			logged = append(logged, keyvals...)
+			return logger.Log(append(keyvals, "env", env.String())...)
+		})
+	})
+	c.AddModuleFunc(func(d contract.Dispatcher, logger log.Logger) tenant {
+		d.Dispatch(context.Background(), "module", nil)
+		logger.Log("msg", "module")
+		return tenant{}
+	})
+	assert.Equal(t, 1, dispatched)
+	assert.Contains(t, logged, "module")
+
+	c.Dispatch(context.Background(), "core", nil)
+	c.Info("core")
+	assert.Equal(t, 2, dispatched)
+	assert.Contains(t, logged, "core")
+}
+
 type a struct{}
 type b struct{}
 
@@ -199,3 +345,52 @@ func TestC_cleanup(t *testing.T) {
 	assert.True(t, dependencyCleanupCalled)
 	assert.True(t, moduleCleanupCalled)
 }
+
+type closingProvider struct {
+	closed int
+}
+
+func (c *closingProvider) ReadBytes() ([]byte, error) {
+	return nil, nil
+}
+
+func (c *closingProvider) Read() (map[string]interface{}, error) {
+	return map[string]interface{}{}, nil
+}
+
+func (c *closingProvider) Watch(ctx context.Context, reload func() error) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (c *closingProvider) Close() error {
+	c.closed++
+	return nil
+}
+
+func TestC_closeConfigProvider(t *testing.T) {
+	p := &closingProvider{}
+	c := New(WithConfigStack(p, nil), WithConfigWatcher(p))
+	assert.Zero(t, p.closed)
+	c.Shutdown()
+	assert.Equal(t, 1, p.closed)
+}
+
+func TestWithYamlProfiles(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "profiles")
+	defer os.RemoveAll(dir)
+
+	base := dir + "/config.yaml"
+	ioutil.WriteFile(base, []byte("name: app\nenv: prod\nhttp:\n  addr: :8080\ngrpc:\n  addr: :9090\n"), os.ModePerm)
+	ioutil.WriteFile(dir+"/config.production.yaml", []byte("http:\n  addr: :80\n"), os.ModePerm)
+	ioutil.WriteFile(dir+"/config.staging.yaml", []byte("http:\n  addr: :81\n"), os.ModePerm)
+
+	c := New(WithYamlProfiles(base))
+	assert.Equal(t, ":80", c.String("http.addr"))
+	assert.Equal(t, ":9090", c.String("grpc.addr"))
+
+	ioutil.WriteFile(dir+"/config.local.yaml", []byte("grpc:\n  addr: :9091\n"), os.ModePerm)
+	c = New(WithYamlProfiles(base))
+	assert.Equal(t, ":80", c.String("http.addr"))
+	assert.Equal(t, ":9091", c.String("grpc.addr"))
+}
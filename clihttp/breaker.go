@@ -0,0 +1,75 @@
+package clihttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/DoNewsCode/core/config"
+	"github.com/sony/gobreaker"
+)
+
+// ErrCircuitOpen is returned when the circuit breaker of the host rejects the
+// request.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// BreakerConfig configures the per-host circuit breakers. The breaker of a
+// host opens after FailureThreshold consecutive failures, which are transport
+// errors and 5xx responses. After OpenTimeout, up to HalfOpenRequests requests
+// probe the host, and the breaker closes if they succeed. A zero
+// FailureThreshold disables the breakers.
+type BreakerConfig struct {
+	FailureThreshold uint32          `json:"failureThreshold" yaml:"failureThreshold"`
+	OpenTimeout      config.Duration `json:"openTimeout" yaml:"openTimeout"`
+	HalfOpenRequests uint32          `json:"halfOpenRequests" yaml:"halfOpenRequests"`
+}
+
+// breakers holds a circuit breaker for each host.
+type breakers struct {
+	conf BreakerConfig
+
+	mu       sync.Mutex
+	breakers map[string]*gobreaker.TwoStepCircuitBreaker
+}
+
+func newBreakers(conf BreakerConfig) *breakers {
+	return &breakers{conf: conf, breakers: make(map[string]*gobreaker.TwoStepCircuitBreaker)}
+}
+
+func (b *breakers) get(host string) *gobreaker.TwoStepCircuitBreaker {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if cb, ok := b.breakers[host]; ok {
+		return cb
+	}
+	cb := gobreaker.NewTwoStepCircuitBreaker(gobreaker.Settings{
+		Name:        host,
+		MaxRequests: b.conf.HalfOpenRequests,
+		Timeout:     b.conf.OpenTimeout.Duration,
+		ReadyToTrip: func(counts gobreaker.Counts) bool {
+			return counts.ConsecutiveFailures >= b.conf.FailureThreshold
+		},
+	})
+	b.breakers[host] = cb
+	return cb
+}
+
+// allow asks the breaker of the host for permission. The returned function
+// reports the outcome of the request.
+func (b *breakers) allow(host string) (func(resp *http.Response, err error), error) {
+	done, err := b.get(host).Allow()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrCircuitOpen, host)
+	}
+	return func(resp *http.Response, err error) {
+		// A request canceled by the caller says nothing about the host.
+		done((err == nil && resp.StatusCode < 500) || errors.Is(err, context.Canceled))
+	}, nil
+}
+
+func isCircuitOpen(err error) bool {
+	return errors.Is(err, ErrCircuitOpen)
+}
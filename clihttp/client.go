@@ -1,13 +1,52 @@
 /*
 Package clihttp adds opentracing support to http client.
+
+On top of tracing, the Client optionally retries the idempotent requests,
+guards every host with a circuit breaker, applies a default timeout and
+measures the requests.
+
+Integration
+
+package clihttp exports the configuration in the following format:
+	httpClient:
+	    default:
+	        timeout: 10s
+	        retry:
+	            maxAttempts: 3
+	            initialBackoff: 100ms
+	            maxBackoff: 2s
+	            backoffMultiplier: 2
+	            retryableStatusCodes: [429, 502, 503, 504]
+	        breaker:
+	            failureThreshold: 5
+	            openTimeout: 30s
+	            halfOpenRequests: 1
+	        requestLogThreshold: 5000
+	        responseLogThreshold: 5000
+
+Add the http client dependency to core:
+
+	var c *core.C = core.New()
+	c.Provide(clihttp.Providers())
+
+Then inject *clihttp.Client, or clihttp.Maker to factory a *clihttp.Client
+with a specific configuration entry.
+
+	c.Invoke(func(maker clihttp.Maker) {
+		client, err := maker.Make("default")
+		// do something with client
+	})
 */
 package clihttp
 
 import (
 	"bytes"
+	"context"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/DoNewsCode/core/contract"
 	"github.com/opentracing-contrib/go-stdlib/nethttp"
@@ -28,6 +67,11 @@ type Client struct {
 	underlying           contract.HttpDoer
 	requestLogThreshold  int
 	responseLogThreshold int
+	retry                RetryPolicy
+	breakers             *breakers
+	timeout              time.Duration
+	metrics              *RequestDurationSeconds
+	routeFunc            func(req *http.Request) string
 }
 
 // Option changes the behavior of Client.
@@ -56,6 +100,52 @@ func WithResponseLogThreshold(num int) Option {
 	}
 }
 
+// WithRetry is an option that retries the failed requests with an idempotent
+// method. See RetryPolicy.
+func WithRetry(policy RetryPolicy) Option {
+	return func(client *Client) {
+		client.retry = policy
+	}
+}
+
+// WithCircuitBreaker is an option that guards every host with a circuit
+// breaker. Requests rejected by an open breaker fail with ErrCircuitOpen. See
+// BreakerConfig.
+func WithCircuitBreaker(conf BreakerConfig) Option {
+	return func(client *Client) {
+		client.breakers = nil
+		if conf.FailureThreshold > 0 {
+			client.breakers = newBreakers(conf)
+		}
+	}
+}
+
+// WithTimeout is an option that sets the timeout of the requests without a
+// deadline, including the retries and the reading of the response body.
+func WithTimeout(timeout time.Duration) Option {
+	return func(client *Client) {
+		client.timeout = timeout
+	}
+}
+
+// WithMetrics is an option that records the duration of every attempt in the
+// histogram.
+func WithMetrics(metrics *RequestDurationSeconds) Option {
+	return func(client *Client) {
+		client.metrics = metrics
+	}
+}
+
+// WithRouteFunc is an option that sets the route label of the metrics. By
+// default, the route is empty, as the paths may contain identifiers which
+// would make the cardinality of the label unbounded. Return a route template,
+// such as /users/{id}, rather than the path.
+func WithRouteFunc(f func(req *http.Request) string) Option {
+	return func(client *Client) {
+		client.routeFunc = f
+	}
+}
+
 // NewClient creates a Client with tracing support.
 func NewClient(tracer opentracing.Tracer, options ...Option) *Client {
 	baseClient := &http.Client{Transport: &nethttp.Transport{}}
@@ -64,6 +154,9 @@ func NewClient(tracer opentracing.Tracer, options ...Option) *Client {
 		underlying:           baseClient,
 		requestLogThreshold:  5000,
 		responseLogThreshold: 5000,
+		routeFunc: func(req *http.Request) string {
+			return ""
+		},
 	}
 	for _, f := range options {
 		f(c)
@@ -73,6 +166,22 @@ func NewClient(tracer opentracing.Tracer, options ...Option) *Client {
 
 // Do sends the request.
 func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if _, ok := ctx.Deadline(); !ok && c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		response, err := c.do(req.WithContext(ctx))
+		if err != nil {
+			cancel()
+			return response, err
+		}
+		response.Body = cancelCloser{ReadCloser: response.Body, cancel: cancel}
+		return response, nil
+	}
+	return c.do(req)
+}
+
+func (c *Client) do(req *http.Request) (*http.Response, error) {
 	clientSpan, ctx := opentracing.StartSpanFromContextWithTracer(req.Context(), c.tracer, "HTTP Client")
 	defer clientSpan.Finish()
 
@@ -86,7 +195,7 @@ func (c *Client) Do(req *http.Request) (*http.Response, error) {
 	c.logRequest(req, clientSpan)
 
 	c.tracer.Inject(clientSpan.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(req.Header))
-	response, err := c.underlying.Do(req)
+	response, err := c.doWithRetry(req, clientSpan)
 	if err != nil {
 		return response, err
 	}
@@ -96,6 +205,65 @@ func (c *Client) Do(req *http.Request) (*http.Response, error) {
 	return response, err
 }
 
+// doWithRetry sends the request until it succeeds, or the retry policy gives
+// up.
+func (c *Client) doWithRetry(req *http.Request, span opentracing.Span) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		response, err := c.attempt(req)
+		if attempt >= c.retry.MaxAttempts || !c.retry.shouldRetry(req, response, err) {
+			return response, err
+		}
+		wait, ok := c.retry.backoff(attempt, response)
+		if !ok {
+			return response, err
+		}
+		if deadline, ok := req.Context().Deadline(); ok && time.Until(deadline) < wait {
+			return response, err
+		}
+		if req.GetBody != nil {
+			body, bodyErr := req.GetBody()
+			if bodyErr != nil {
+				return response, err
+			}
+			req.Body = body
+		}
+		drain(response)
+		span.LogKV("event", "retry", "attempt", attempt+1, "wait", wait.String())
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// attempt sends the request once, through the circuit breaker of the host.
+func (c *Client) attempt(req *http.Request) (*http.Response, error) {
+	var done func(*http.Response, error)
+	if c.breakers != nil {
+		var err error
+		if done, err = c.breakers.allow(req.URL.Host); err != nil {
+			return nil, err
+		}
+	}
+	start := time.Now()
+	response, err := c.underlying.Do(req)
+	if c.metrics != nil {
+		code := "error"
+		if err == nil {
+			code = strconv.Itoa(response.StatusCode)
+		}
+		c.metrics.Host(req.URL.Host).Route(c.routeFunc(req)).Code(code).Observe(time.Since(start).Seconds())
+	}
+	if done != nil {
+		done(response, err)
+	}
+	return response, err
+}
+
 func (c *Client) logRequest(req *http.Request, span opentracing.Span) {
 	if req.Body == nil || c.requestLogThreshold <= 0 {
 		return
@@ -143,3 +311,15 @@ type readCloser struct {
 	io.Closer
 	io.Reader
 }
+
+// cancelCloser releases the context of the timeout when the body is closed.
+type cancelCloser struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c cancelCloser) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
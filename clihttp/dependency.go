@@ -0,0 +1,163 @@
+package clihttp
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/DoNewsCode/core/config"
+	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/di"
+	"github.com/opentracing-contrib/go-stdlib/nethttp"
+	"github.com/opentracing/opentracing-go"
+)
+
+/*
+Providers returns a set of dependency providers related to http clients. It
+includes the Maker, the default *Client and exported configs.
+
+	Depends On:
+		contract.ConfigUnmarshaler
+		ClientOptionsInterceptor `optional:"true"`
+		opentracing.Tracer       `optional:"true"`
+		*RequestDurationSeconds  `optional:"true"`
+		contract.Dispatcher      `optional:"true"`
+	Provide:
+		Maker
+		Factory
+		*Client
+*/
+func Providers() []interface{} {
+	return []interface{}{provideHTTPFactory, provideDefaultClient, provideConfig}
+}
+
+// ClientOptionsInterceptor intercepts the options before creating the client
+// so you can make amendment to them. Useful for the options that can not be
+// mapped to a text representation, such as WithRouteFunc or WithDoer.
+type ClientOptionsInterceptor func(name string, opts *[]Option)
+
+// ClientConfig is the configuration of a http client.
+type ClientConfig struct {
+	// Timeout is the default timeout of the requests without a deadline. Zero
+	// means no timeout.
+	Timeout config.Duration `json:"timeout" yaml:"timeout"`
+	// Retry is the retry policy of the idempotent requests.
+	Retry RetryPolicy `json:"retry" yaml:"retry"`
+	// Breaker configures the per-host circuit breakers.
+	Breaker BreakerConfig `json:"breaker" yaml:"breaker"`
+	// RequestLogThreshold and ResponseLogThreshold limit the bytes of the
+	// bodies logged in the spans. See WithRequestLogThreshold.
+	RequestLogThreshold  int `json:"requestLogThreshold" yaml:"requestLogThreshold"`
+	ResponseLogThreshold int `json:"responseLogThreshold" yaml:"responseLogThreshold"`
+}
+
+// factoryIn is the injection parameter for provideHTTPFactory.
+type factoryIn struct {
+	di.In
+
+	Conf        contract.ConfigUnmarshaler
+	Interceptor ClientOptionsInterceptor `optional:"true"`
+	Tracer      opentracing.Tracer       `optional:"true"`
+	Metrics     *RequestDurationSeconds  `optional:"true"`
+	Dispatcher  contract.Dispatcher      `optional:"true"`
+}
+
+// factoryOut is the result of provideHTTPFactory.
+type factoryOut struct {
+	di.Out
+
+	Maker     Maker
+	Factory   Factory
+	Factories []*di.Factory `group:"factories,flatten"`
+}
+
+// provideHTTPFactory creates Factory and *Client. It is a valid dependency for
+// package core. Every client has its own connection pool, whose idle
+// connections are closed when the factory is closed, or when the configuration
+// of the client changes on reload.
+func provideHTTPFactory(p factoryIn) (factoryOut, func(), error) {
+	factory := di.NewFactory(func(name string) (di.Pair, error) {
+		var conf ClientConfig
+		if err := p.Conf.Unmarshal(fmt.Sprintf("httpClient.%s", name), &conf); err != nil {
+			return di.Pair{}, fmt.Errorf("httpClient configuration %s not valid: %w", name, err)
+		}
+		tracer := p.Tracer
+		if tracer == nil {
+			tracer = opentracing.GlobalTracer()
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		opts := []Option{
+			WithDoer(&http.Client{Transport: &nethttp.Transport{RoundTripper: transport}}),
+			WithTimeout(conf.Timeout.Duration),
+			WithRetry(conf.Retry),
+			WithCircuitBreaker(conf.Breaker),
+			WithRequestLogThreshold(conf.RequestLogThreshold),
+			WithResponseLogThreshold(conf.ResponseLogThreshold),
+		}
+		if p.Metrics != nil {
+			opts = append(opts, WithMetrics(p.Metrics))
+		}
+		if p.Interceptor != nil {
+			p.Interceptor(name, &opts)
+		}
+		return di.Pair{
+			Conn: NewClient(tracer, opts...),
+			Closer: func() {
+				transport.CloseIdleConnections()
+			},
+		}, nil
+	}, di.WithConfigKind("httpClient"))
+	httpFactory := Factory{factory}
+	if err := httpFactory.Preload(p.Conf); err != nil {
+		httpFactory.Close()
+		return factoryOut{}, nil, err
+	}
+	httpFactory.SubscribeReloadEventFrom(p.Dispatcher)
+	return factoryOut{
+		Maker:     httpFactory,
+		Factory:   httpFactory,
+		Factories: []*di.Factory{factory},
+	}, httpFactory.Close, nil
+}
+
+func provideDefaultClient(maker Maker) (*Client, error) {
+	return maker.Make("default")
+}
+
+type configOut struct {
+	di.Out
+
+	Config []config.ExportedConfig `group:"config,flatten"`
+}
+
+// provideConfig exports the default http client configuration.
+func provideConfig() configOut {
+	configs := []config.ExportedConfig{
+		{
+			Owner: "clihttp",
+			Data: map[string]interface{}{
+				"httpClient": map[string]ClientConfig{
+					"default": {
+						Timeout: config.Duration{Duration: 10 * time.Second},
+						Retry: RetryPolicy{
+							MaxAttempts:          3,
+							InitialBackoff:       config.Duration{Duration: 100 * time.Millisecond},
+							MaxBackoff:           config.Duration{Duration: 2 * time.Second},
+							BackoffMultiplier:    2,
+							RetryableStatusCodes: []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout},
+						},
+						Breaker: BreakerConfig{
+							FailureThreshold: 5,
+							OpenTimeout:      config.Duration{Duration: 30 * time.Second},
+							HalfOpenRequests: 1,
+						},
+						RequestLogThreshold:  5000,
+						ResponseLogThreshold: 5000,
+					},
+				},
+			},
+			Comment: "The configuration of http clients",
+		},
+	}
+	return configOut{Config: configs}
+}
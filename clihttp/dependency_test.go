@@ -0,0 +1,62 @@
+package clihttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core/config"
+	"github.com/knadh/koanf/parsers/yaml"
+	"github.com/knadh/koanf/providers/rawbytes"
+	"github.com/stretchr/testify/assert"
+	yaml2 "gopkg.in/yaml.v3"
+)
+
+func TestNewHTTPFactory(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	var intercepted []string
+	out, cleanup, err := provideHTTPFactory(factoryIn{
+		Conf: config.MapAdapter{"httpClient": map[string]interface{}{
+			"default": map[string]interface{}{
+				"timeout": "1s",
+				"retry":   map[string]interface{}{"maxAttempts": 2},
+			},
+			"alternative": map[string]interface{}{},
+		}},
+		Interceptor: func(name string, opts *[]Option) {
+			intercepted = append(intercepted, name)
+		},
+	})
+	assert.NoError(t, err)
+
+	def, err := out.Maker.Make("default")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Second, def.timeout)
+	assert.Equal(t, 2, def.retry.MaxAttempts)
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	resp, err := def.Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	alt, err := out.Maker.Make("alternative")
+	assert.NoError(t, err)
+	assert.NotSame(t, def, alt)
+	assert.Nil(t, alt.breakers)
+	assert.Equal(t, []string{"default", "alternative"}, intercepted)
+	cleanup()
+}
+
+func TestProvideConfigs(t *testing.T) {
+	var conf ClientConfig
+	c := provideConfig()
+	assert.NotEmpty(t, c.Config)
+	bytes, _ := yaml2.Marshal(c.Config[0].Data)
+	k, _ := config.NewConfig(config.WithProviderLayer(rawbytes.Provider(bytes), yaml.Parser()))
+	assert.NoError(t, k.Unmarshal("httpClient.default", &conf))
+	assert.Equal(t, 10*time.Second, conf.Timeout.Duration)
+	assert.Equal(t, 3, conf.Retry.MaxAttempts)
+	assert.Equal(t, uint32(5), conf.Breaker.FailureThreshold)
+}
@@ -0,0 +1,25 @@
+package clihttp
+
+import (
+	"github.com/DoNewsCode/core/di"
+)
+
+// Maker is models Factory
+type Maker interface {
+	Make(name string) (*Client, error)
+}
+
+// Factory is a *di.Factory that creates *Client using a specific
+// configuration entry.
+type Factory struct {
+	*di.Factory
+}
+
+// Make creates *Client using a specific configuration entry.
+func (r Factory) Make(name string) (*Client, error) {
+	client, err := r.Factory.Make(name)
+	if err != nil {
+		return nil, err
+	}
+	return client.(*Client), nil
+}
@@ -0,0 +1,52 @@
+package clihttp
+
+import (
+	"github.com/go-kit/kit/metrics"
+)
+
+// RequestDurationSeconds is a Histogram that measures the outgoing HTTP
+// requests. Every attempt of a retried request is observed.
+type RequestDurationSeconds struct {
+	// Histogram is the underlying histogram of RequestDurationSeconds.
+	Histogram metrics.Histogram
+
+	// labels
+	host  string
+	route string
+	code  string
+}
+
+// Host specifies the host label for RequestDurationSeconds.
+func (r *RequestDurationSeconds) Host(host string) *RequestDurationSeconds {
+	return &RequestDurationSeconds{
+		Histogram: r.Histogram.With("host", host),
+		host:      host,
+		route:     r.route,
+		code:      r.code,
+	}
+}
+
+// Route specifies the route label for RequestDurationSeconds.
+func (r *RequestDurationSeconds) Route(route string) *RequestDurationSeconds {
+	return &RequestDurationSeconds{
+		Histogram: r.Histogram.With("route", route),
+		host:      r.host,
+		route:     route,
+		code:      r.code,
+	}
+}
+
+// Code specifies the code label for RequestDurationSeconds.
+func (r *RequestDurationSeconds) Code(code string) *RequestDurationSeconds {
+	return &RequestDurationSeconds{
+		Histogram: r.Histogram.With("code", code),
+		host:      r.host,
+		route:     r.route,
+		code:      code,
+	}
+}
+
+// Observe records the time taken by the request.
+func (r RequestDurationSeconds) Observe(seconds float64) {
+	r.Histogram.Observe(seconds)
+}
@@ -0,0 +1,56 @@
+package clihttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/opentracing/opentracing-go"
+	"github.com/stretchr/testify/assert"
+)
+
+type histogram struct {
+	labels []string
+	values []float64
+}
+
+func (h *histogram) With(labelValues ...string) metrics.Histogram {
+	h.labels = append(h.labels, labelValues...)
+	return h
+}
+
+func (h *histogram) Observe(value float64) {
+	h.values = append(h.values, value)
+}
+
+func TestClient_metrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+	h := &histogram{}
+	client := NewClient(opentracing.NoopTracer{}, WithMetrics(&RequestDurationSeconds{Histogram: h}), WithRouteFunc(func(req *http.Request) string {
+		return "/users/{id}"
+	}))
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/users/1", nil)
+	resp, err := client.Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, []string{"host", req.URL.Host, "route", "/users/{id}", "code", "404"}, h.labels)
+	assert.Len(t, h.values, 1)
+}
+
+func TestClient_metrics_defaultRoute(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	h := &histogram{}
+	client := NewClient(opentracing.NoopTracer{}, WithMetrics(&RequestDurationSeconds{Histogram: h}))
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/users/1", nil)
+	resp, err := client.Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, []string{"host", req.URL.Host, "route", "", "code", "200"}, h.labels)
+}
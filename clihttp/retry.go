@@ -0,0 +1,124 @@
+package clihttp
+
+import (
+	"io"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/DoNewsCode/core/config"
+)
+
+// RetryPolicy retries the requests with an idempotent method that fail with a
+// transport error or one of the retryable status codes. Requests are not
+// retried if MaxAttempts is less than 2.
+//
+// The backoff before the nth retry is a random duration up to
+// min(InitialBackoff * BackoffMultiplier^(n-1), MaxBackoff), but no less than
+// the Retry-After of the response. No retry is made if Retry-After asks for a
+// longer wait than MaxBackoff.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first one.
+	MaxAttempts       int             `json:"maxAttempts" yaml:"maxAttempts"`
+	InitialBackoff    config.Duration `json:"initialBackoff" yaml:"initialBackoff"`
+	MaxBackoff        config.Duration `json:"maxBackoff" yaml:"maxBackoff"`
+	BackoffMultiplier float64         `json:"backoffMultiplier" yaml:"backoffMultiplier"`
+	// RetryableStatusCodes are the response status codes worth a retry, such
+	// as 503.
+	RetryableStatusCodes []int `json:"retryableStatusCodes" yaml:"retryableStatusCodes"`
+}
+
+// shouldRetry reports whether the attempt that gave resp and err is worth a
+// retry.
+func (r RetryPolicy) shouldRetry(req *http.Request, resp *http.Response, err error) bool {
+	if !isIdempotent(req.Method) {
+		return false
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	if err != nil {
+		return req.Context().Err() == nil && !isCircuitOpen(err)
+	}
+	for _, code := range r.RetryableStatusCodes {
+		if resp.StatusCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns the wait before the retry following the nth attempt, as
+// described by RetryPolicy. The second return value is false if Retry-After
+// asks for a longer wait than MaxBackoff.
+func (r RetryPolicy) backoff(attempt int, resp *http.Response) (time.Duration, bool) {
+	multiplier := r.BackoffMultiplier
+	if multiplier <= 0 {
+		multiplier = 1
+	}
+	wait := time.Duration(float64(r.InitialBackoff.Duration) * math.Pow(multiplier, float64(attempt-1)))
+	if wait > r.MaxBackoff.Duration || wait < 0 {
+		wait = r.MaxBackoff.Duration
+	}
+	if wait > 0 {
+		wait = time.Duration(rand.Int63n(int64(wait)))
+	}
+	if resp == nil {
+		return wait, true
+	}
+	after, ok := retryAfter(resp.Header.Get("Retry-After"), time.Now())
+	if !ok {
+		return wait, true
+	}
+	if after > r.MaxBackoff.Duration {
+		return 0, false
+	}
+	if after > wait {
+		wait = after
+	}
+	return wait, true
+}
+
+// retryAfter parses the Retry-After header, either in seconds or as a date.
+func retryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	if d := date.Sub(now); d > 0 {
+		return d, true
+	}
+	return 0, true
+}
+
+// isIdempotent reports whether the method is idempotent, as defined in RFC
+// 7231.
+func isIdempotent(method string) bool {
+	switch method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// drain discards the rest of the response so that the connection can be
+// reused.
+func drain(resp *http.Response) {
+	if resp == nil || resp.Body == nil {
+		return
+	}
+	_, _ = io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 4096))
+	_ = resp.Body.Close()
+}
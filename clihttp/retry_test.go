@@ -0,0 +1,162 @@
+package clihttp
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core/config"
+	"github.com/opentracing/opentracing-go"
+	"github.com/stretchr/testify/assert"
+)
+
+var testRetryPolicy = RetryPolicy{
+	MaxAttempts:          3,
+	InitialBackoff:       config.Duration{Duration: time.Millisecond},
+	MaxBackoff:           config.Duration{Duration: 10 * time.Millisecond},
+	BackoffMultiplier:    2,
+	RetryableStatusCodes: []int{http.StatusServiceUnavailable},
+}
+
+func TestClient_retry(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write(body)
+	}))
+	defer server.Close()
+	client := NewClient(opentracing.NoopTracer{}, WithRetry(testRetryPolicy))
+
+	cases := []struct {
+		name     string
+		method   string
+		code     int
+		attempts int32
+	}{
+		{"idempotent", http.MethodPut, http.StatusOK, 3},
+		{"not idempotent", http.MethodPost, http.StatusServiceUnavailable, 1},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			atomic.StoreInt32(&attempts, 0)
+			req, _ := http.NewRequest(c.method, server.URL, strings.NewReader("foo"))
+			resp, err := client.Do(req)
+			assert.NoError(t, err)
+			defer resp.Body.Close()
+			assert.Equal(t, c.code, resp.StatusCode)
+			assert.Equal(t, c.attempts, atomic.LoadInt32(&attempts))
+			if c.code == http.StatusOK {
+				body, _ := ioutil.ReadAll(resp.Body)
+				assert.Equal(t, "foo", string(body))
+			}
+		})
+	}
+}
+
+func TestClient_retryAfter(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.Header().Set("Retry-After", r.URL.Query().Get("after"))
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	policy := testRetryPolicy
+	policy.MaxAttempts = 2
+	policy.MaxBackoff = config.Duration{Duration: 2 * time.Second}
+	client := NewClient(opentracing.NoopTracer{}, WithRetry(policy))
+
+	start := time.Now()
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"?after=1", nil)
+	resp, err := client.Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, int32(2), atomic.LoadInt32(&attempts))
+	assert.GreaterOrEqual(t, int64(time.Since(start)), int64(time.Second))
+
+	// A longer wait than the max backoff is not honored by a retry.
+	atomic.StoreInt32(&attempts, 0)
+	req, _ = http.NewRequest(http.MethodGet, server.URL+"?after=60", nil)
+	resp, err = client.Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		value string
+		wait  time.Duration
+		ok    bool
+	}{
+		{"", 0, false},
+		{"3", 3 * time.Second, true},
+		{"-1", 0, false},
+		{"Tue, 01 Jun 2021 00:00:05 GMT", 5 * time.Second, true},
+		{"Mon, 31 May 2021 00:00:00 GMT", 0, true},
+		{"foo", 0, false},
+	}
+	for _, c := range cases {
+		wait, ok := retryAfter(c.value, now)
+		assert.Equal(t, c.wait, wait, c.value)
+		assert.Equal(t, c.ok, ok, c.value)
+	}
+}
+
+func TestClient_circuitBreaker(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	client := NewClient(opentracing.NoopTracer{}, WithCircuitBreaker(BreakerConfig{
+		FailureThreshold: 2,
+		OpenTimeout:      config.Duration{Duration: time.Minute},
+	}))
+
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		resp, err := client.Do(req)
+		assert.NoError(t, err)
+		resp.Body.Close()
+	}
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	_, err := client.Do(req)
+	assert.True(t, errors.Is(err, ErrCircuitOpen))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&attempts))
+}
+
+func TestClient_timeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer server.Close()
+	client := NewClient(opentracing.NoopTracer{}, WithTimeout(10*time.Millisecond))
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	_, err := client.Do(req)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	// A deadline of the caller takes precedence.
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	req, _ = http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	resp, err := client.Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+}
@@ -0,0 +1,114 @@
+// Package dotenv provides the dotenv codec.
+//
+// A dotenv file is flat, so nested keys are joined by ".":
+//
+//  http.addr=":8080"
+//  log.level="debug"
+//
+// All scalars are written as strings, which the config package converts back
+// into the target type on unmarshal. Lists are written as JSON arrays and
+// decoded as such.
+package dotenv
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/DoNewsCode/core/codec/internal/plain"
+	"github.com/joho/godotenv"
+)
+
+// Codec is a Codec implementation with dotenv.
+type Codec struct{}
+
+// Marshal serialize the interface{} to []byte
+func (Codec) Marshal(v interface{}) ([]byte, error) {
+	m, err := plain.ToMap(v)
+	if err != nil {
+		return nil, err
+	}
+	env := make(map[string]string)
+	if err := flatten(env, m, ""); err != nil {
+		return nil, err
+	}
+	if len(env) == 0 {
+		return nil, nil
+	}
+	s, err := godotenv.Marshal(env)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(s + "\n"), nil
+}
+
+// Unmarshal deserialize the []byte to interface{}
+func (Codec) Unmarshal(data []byte, v interface{}) error {
+	env, err := godotenv.Unmarshal(string(data))
+	if err != nil {
+		return err
+	}
+	m := make(map[string]interface{})
+	for k, value := range env {
+		var decoded interface{} = value
+		if strings.HasPrefix(value, "[") || strings.HasPrefix(value, "{") {
+			var list interface{}
+			if err := json.Unmarshal([]byte(value), &list); err == nil {
+				decoded = list
+			}
+		}
+		if err := setPath(m, strings.Split(k, "."), decoded); err != nil {
+			return err
+		}
+	}
+	return plain.FromMap(m, v)
+}
+
+func flatten(env map[string]string, m map[string]interface{}, prefix string) error {
+	for k, value := range m {
+		key := prefix + k
+		switch item := value.(type) {
+		case map[string]interface{}:
+			if len(item) == 0 {
+				env[key] = "{}"
+				continue
+			}
+			if err := flatten(env, item, key+"."); err != nil {
+				return err
+			}
+		case []interface{}:
+			bytes, err := json.Marshal(item)
+			if err != nil {
+				return err
+			}
+			env[key] = string(bytes)
+		default:
+			env[key] = fmt.Sprint(item)
+		}
+	}
+	return nil
+}
+
+func setPath(m map[string]interface{}, path []string, value interface{}) error {
+	for i, segment := range path[:len(path)-1] {
+		next, ok := m[segment]
+		if !ok {
+			next = make(map[string]interface{})
+			m[segment] = next
+		}
+		nested, ok := next.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("key %s conflicts with a value", strings.Join(path[:i+1], "."))
+		}
+		m = nested
+	}
+	last := path[len(path)-1]
+	if _, ok := m[last].(map[string]interface{}); ok {
+		if _, ok := value.(map[string]interface{}); ok {
+			return nil
+		}
+		return fmt.Errorf("key %s conflicts with a nested key", strings.Join(path, "."))
+	}
+	m[last] = value
+	return nil
+}
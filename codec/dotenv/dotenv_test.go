@@ -0,0 +1,45 @@
+package dotenv
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCodec(t *testing.T) {
+	in := map[string]interface{}{
+		"name": "app",
+		"http": map[string]interface{}{
+			"addr":  ":8080",
+			"port":  8080,
+			"debug": true,
+			"dsn":   "user:pa$$word@tcp(127.0.0.1:3306)/db#main",
+			"peers": []string{"a", "b"},
+		},
+	}
+	data, err := Codec{}.Marshal(in)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "http.port=\"8080\"\n")
+
+	var m map[string]interface{}
+	assert.NoError(t, Codec{}.Unmarshal(data, &m))
+	assert.Equal(t, map[string]interface{}{
+		"name": "app",
+		"http": map[string]interface{}{
+			"addr":  ":8080",
+			"port":  "8080",
+			"debug": "true",
+			"dsn":   "user:pa$$word@tcp(127.0.0.1:3306)/db#main",
+			"peers": []interface{}{"a", "b"},
+		},
+	}, m)
+}
+
+func TestCodec_Unmarshal(t *testing.T) {
+	var m map[string]interface{}
+	assert.NoError(t, Codec{}.Unmarshal([]byte("# comment\nexport log.level=debug\n"), &m))
+	assert.Equal(t, map[string]interface{}{"log": map[string]interface{}{"level": "debug"}}, m)
+
+	m = nil
+	assert.Error(t, Codec{}.Unmarshal([]byte("log=debug\nlog.level=debug\n"), &m))
+}
@@ -0,0 +1,146 @@
+// Package hcl provides the hcl codec. It reads and writes version 1 of the
+// HashiCorp configuration language, which is what most ops teams have in their
+// Terraform-era tooling.
+//
+// Values are converted to plain maps before encoding, so struct fields are
+// named after their json tags.
+package hcl
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/DoNewsCode/core/codec/internal/plain"
+	"github.com/hashicorp/hcl"
+)
+
+// Codec is a Codec implementation with hcl.
+type Codec struct{}
+
+// Marshal serialize the interface{} to []byte
+func (Codec) Marshal(v interface{}) ([]byte, error) {
+	m, err := plain.ToMap(v)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := writeObject(&buf, m, ""); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal deserialize the []byte to interface{}
+func (Codec) Unmarshal(data []byte, v interface{}) error {
+	var m map[string]interface{}
+	if err := hcl.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	flatten(m)
+	return plain.FromMap(m, v)
+}
+
+// flatten turns the blocks, which hcl decodes as lists of maps, back into
+// maps. Repeated blocks are deep-merged. See
+// https://github.com/hashicorp/hcl/issues/162.
+func flatten(m map[string]interface{}) {
+	for k, value := range m {
+		if blocks, ok := value.([]map[string]interface{}); ok {
+			merged := make(map[string]interface{})
+			for _, block := range blocks {
+				flatten(block)
+				merge(merged, block)
+			}
+			m[k] = merged
+			continue
+		}
+		if nested, ok := value.(map[string]interface{}); ok {
+			flatten(nested)
+		}
+	}
+}
+
+// merge copies src into dst, merging the nested maps.
+func merge(dst, src map[string]interface{}) {
+	for k, value := range src {
+		nested, ok1 := value.(map[string]interface{})
+		existing, ok2 := dst[k].(map[string]interface{})
+		if ok1 && ok2 {
+			merge(existing, nested)
+			continue
+		}
+		dst[k] = value
+	}
+}
+
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+func writeObject(buf *bytes.Buffer, m map[string]interface{}, indent string) error {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		key := k
+		if !identifier.MatchString(k) {
+			key = strconv.Quote(k)
+		}
+		if nested, ok := m[k].(map[string]interface{}); ok {
+			fmt.Fprintf(buf, "%s%s {\n", indent, key)
+			if err := writeObject(buf, nested, indent+"  "); err != nil {
+				return err
+			}
+			fmt.Fprintf(buf, "%s}\n", indent)
+			continue
+		}
+		fmt.Fprintf(buf, "%s%s = ", indent, key)
+		if err := writeValue(buf, m[k], indent); err != nil {
+			return err
+		}
+		buf.WriteString("\n")
+	}
+	return nil
+}
+
+func writeValue(buf *bytes.Buffer, v interface{}, indent string) error {
+	switch value := v.(type) {
+	case string:
+		buf.WriteString(strconv.Quote(value))
+	case bool:
+		buf.WriteString(strconv.FormatBool(value))
+	case int64:
+		buf.WriteString(strconv.FormatInt(value, 10))
+	case float64:
+		s := strconv.FormatFloat(value, 'f', -1, 64)
+		if !strings.Contains(s, ".") {
+			s += ".0"
+		}
+		buf.WriteString(s)
+	case map[string]interface{}:
+		buf.WriteString("{\n")
+		if err := writeObject(buf, value, indent+"  "); err != nil {
+			return err
+		}
+		buf.WriteString(indent + "}")
+	case []interface{}:
+		buf.WriteString("[")
+		for i, item := range value {
+			if i != 0 {
+				buf.WriteString(", ")
+			}
+			if err := writeValue(buf, item, indent); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("]")
+	default:
+		return fmt.Errorf("cannot encode type %T as hcl", v)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package hcl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCodec(t *testing.T) {
+	in := map[string]interface{}{
+		"name":     "app",
+		"with.dot": 1,
+		"http": map[string]interface{}{
+			"addr":  "local\"host",
+			"ratio": 0.5,
+			"debug": true,
+			"peers": []string{"a", "b"},
+			"tls":   map[string]interface{}{"enabled": false},
+			"routes": []map[string]interface{}{
+				{"path": "/"},
+			},
+		},
+	}
+	data, err := Codec{}.Marshal(in)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "http {\n")
+	assert.Contains(t, string(data), "\"with.dot\" = 1\n")
+
+	var m map[string]interface{}
+	assert.NoError(t, Codec{}.Unmarshal(data, &m))
+	assert.Equal(t, map[string]interface{}{
+		"name":     "app",
+		"with.dot": 1,
+		"http": map[string]interface{}{
+			"addr":  "local\"host",
+			"ratio": 0.5,
+			"debug": true,
+			"peers": []interface{}{"a", "b"},
+			"tls":   map[string]interface{}{"enabled": false},
+			"routes": []interface{}{
+				map[string]interface{}{"path": "/"},
+			},
+		},
+	}, m)
+}
+
+func TestCodec_Unmarshal(t *testing.T) {
+	var out struct {
+		HTTP struct {
+			Addr string `json:"addr"`
+		} `json:"http"`
+	}
+	err := Codec{}.Unmarshal([]byte("http {\n  addr = \":8080\"\n}\n"), &out)
+	assert.NoError(t, err)
+	assert.Equal(t, ":8080", out.HTTP.Addr)
+
+	assert.Error(t, Codec{}.Unmarshal([]byte("http {"), &out))
+}
+
+func TestCodec_Unmarshal_repeatedBlocks(t *testing.T) {
+	var m map[string]interface{}
+	err := Codec{}.Unmarshal([]byte("kafka {\n  writer {\n    a = 1\n  }\n}\nkafka {\n  writer {\n    b = 2\n  }\n  reader {\n    c = 3\n  }\n}\n"), &m)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"kafka": map[string]interface{}{
+			"writer": map[string]interface{}{"a": 1, "b": 2},
+			"reader": map[string]interface{}{"c": 3},
+		},
+	}, m)
+}
@@ -0,0 +1,79 @@
+// Package plain converts between Go values and plain config maps for the
+// codecs whose underlying libraries only work with maps.
+//
+// The conversion goes through encoding/json, so struct fields are named after
+// their json tags, like everywhere else in package core.
+package plain
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// ToMap converts v into a map made of map[string]interface{}, []interface{},
+// string, bool, int64 and float64. Nil values are dropped, as none of the
+// formats backed by this package can represent them.
+func ToMap(v interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var out interface{}
+	if err := decoder.Decode(&out); err != nil {
+		return nil, err
+	}
+	m, ok := normalize(out).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%T can not be encoded as a map", v)
+	}
+	return m, nil
+}
+
+// FromMap stores m into v. If v is a *map[string]interface{}, the entries are
+// copied as is. Otherwise, m is decoded into v with encoding/json.
+func FromMap(m map[string]interface{}, v interface{}) error {
+	if p, ok := v.(*map[string]interface{}); ok {
+		if *p == nil {
+			*p = make(map[string]interface{}, len(m))
+		}
+		for k, value := range m {
+			(*p)[k] = value
+		}
+		return nil
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func normalize(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for k, item := range value {
+			if item == nil {
+				delete(value, k)
+				continue
+			}
+			value[k] = normalize(item)
+		}
+		return value
+	case []interface{}:
+		for i := range value {
+			value[i] = normalize(value[i])
+		}
+		return value
+	case json.Number:
+		if i, err := value.Int64(); err == nil {
+			return i
+		}
+		f, _ := value.Float64()
+		return f
+	default:
+		return value
+	}
+}
@@ -0,0 +1,35 @@
+// Package toml provides the toml codec.
+//
+// Values are converted to plain maps before encoding, so struct fields are
+// named after their json tags.
+package toml
+
+import (
+	"github.com/DoNewsCode/core/codec/internal/plain"
+	"github.com/pelletier/go-toml"
+)
+
+// Codec is a Codec implementation with toml.
+type Codec struct{}
+
+// Marshal serialize the interface{} to []byte
+func (Codec) Marshal(v interface{}) ([]byte, error) {
+	m, err := plain.ToMap(v)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := toml.TreeFromMap(m)
+	if err != nil {
+		return nil, err
+	}
+	return tree.Marshal()
+}
+
+// Unmarshal deserialize the []byte to interface{}
+func (Codec) Unmarshal(data []byte, v interface{}) error {
+	tree, err := toml.LoadBytes(data)
+	if err != nil {
+		return err
+	}
+	return plain.FromMap(tree.ToMap(), v)
+}
@@ -0,0 +1,48 @@
+package toml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type server struct {
+	Addr    string   `json:"addr"`
+	Port    int      `json:"port"`
+	Debug   bool     `json:"debug"`
+	Ratio   float64  `json:"ratio"`
+	Peers   []string `json:"peers"`
+	Skipped *string  `json:"skipped"`
+}
+
+func TestCodec(t *testing.T) {
+	in := map[string]interface{}{
+		"name": "app",
+		"http": server{Addr: "localhost", Port: 8080, Debug: true, Ratio: 0.5, Peers: []string{"a", "b"}},
+	}
+	data, err := Codec{}.Marshal(in)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "[http]")
+	assert.Contains(t, string(data), "port = 8080")
+	assert.NotContains(t, string(data), "skipped")
+
+	var m map[string]interface{}
+	assert.NoError(t, Codec{}.Unmarshal(data, &m))
+	assert.Equal(t, "app", m["name"])
+	assert.Equal(t, int64(8080), m["http"].(map[string]interface{})["port"])
+
+	var out struct {
+		Name string `json:"name"`
+		HTTP server `json:"http"`
+	}
+	assert.NoError(t, Codec{}.Unmarshal(data, &out))
+	assert.Equal(t, in["http"], out.HTTP)
+}
+
+func TestCodec_Unmarshal(t *testing.T) {
+	var m map[string]interface{}
+	assert.NoError(t, Codec{}.Unmarshal([]byte(""), &m))
+	assert.Empty(t, m)
+
+	assert.Error(t, Codec{}.Unmarshal([]byte("foo = "), &m))
+}
@@ -0,0 +1,172 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/di"
+	"github.com/DoNewsCode/core/events"
+)
+
+// Binding is a typed, live-updating view of a config path. The path is
+// unmarshalled into a struct once, and again on every successful reload, so the
+// readers always see the current value through Load without taking any lock.
+//
+//  binding, _ := config.Bind(conf, "http", HTTPConfig{})
+//  binding.SubscribeReloadEventFrom(dispatcher)
+//  addr := binding.Load().(HTTPConfig).Addr
+type Binding struct {
+	path  string
+	typ   reflect.Type
+	value atomic.Value
+
+	mu         sync.Mutex
+	callbacks  []func(oldValue, newValue interface{})
+	reloadOnce sync.Once
+}
+
+// Bind unmarshals the config at path into a value of the same type as
+// prototype and returns a *Binding holding it. The prototype can be either a
+// value or a pointer; Load always returns a value.
+func Bind(conf contract.ConfigUnmarshaler, path string, prototype interface{}) (*Binding, error) {
+	typ := reflect.TypeOf(prototype)
+	if typ == nil {
+		return nil, fmt.Errorf("can't bind %s to an untyped nil", path)
+	}
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	b := &Binding{path: path, typ: typ}
+	value, err := b.unmarshal(conf)
+	if err != nil {
+		return nil, err
+	}
+	b.value.Store(value)
+	return b, nil
+}
+
+// Path returns the config path of the binding.
+func (b *Binding) Path() string {
+	return b.path
+}
+
+// Load returns a snapshot of the current value. The returned value has the
+// same type as the prototype passed to Bind, dereferenced if it is a pointer.
+func (b *Binding) Load() interface{} {
+	return b.value.Load().(box).v
+}
+
+// OnChange registers a callback that is called after the value has been
+// replaced by a different one.
+func (b *Binding) OnChange(callback func(oldValue, newValue interface{})) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.callbacks = append(b.callbacks, callback)
+}
+
+// Update unmarshals the path from conf again and replaces the value if it has
+// changed. If the unmarshalling fails, the current value is kept and the error
+// is returned.
+func (b *Binding) Update(conf contract.ConfigUnmarshaler) error {
+	newValue, err := b.unmarshal(conf)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	oldValue := b.value.Load().(box)
+	if reflect.DeepEqual(oldValue.v, newValue.v) {
+		return nil
+	}
+	b.value.Store(newValue)
+	for _, callback := range b.callbacks {
+		callback(oldValue.v, newValue.v)
+	}
+	return nil
+}
+
+// SubscribeReloadEventFrom subscribes to the reload events from dispatcher and
+// updates the value on every reload that touches the path. Invalid values are
+// ignored and the last good value is kept.
+func (b *Binding) SubscribeReloadEventFrom(dispatcher contract.Dispatcher) {
+	if dispatcher == nil {
+		return
+	}
+	b.reloadOnce.Do(func() {
+		dispatcher.Subscribe(events.Listen(events.OnReload, func(ctx context.Context, event interface{}) error {
+			payload, ok := event.(events.OnReloadPayload)
+			if !ok || payload.NewConf == nil || !payload.Diff.HasChanged(b.path) {
+				return nil
+			}
+			_ = b.Update(payload.NewConf)
+			return nil
+		}))
+	})
+}
+
+// box wraps the value so that atomic.Value accepts nil interfaces and always
+// sees the same concrete type.
+type box struct {
+	v interface{}
+}
+
+func (b *Binding) unmarshal(conf contract.ConfigUnmarshaler) (box, error) {
+	ptr := reflect.New(b.typ)
+	if err := conf.Unmarshal(b.path, ptr.Interface()); err != nil {
+		return box{}, fmt.Errorf("unable to bind %s: %w", b.path, err)
+	}
+	return box{v: ptr.Elem().Interface()}, nil
+}
+
+// BindingIn is the injection parameter for the constructor returned by
+// ProvideBinding.
+type BindingIn struct {
+	di.In
+
+	Conf       contract.ConfigUnmarshaler
+	Dispatcher contract.Dispatcher `optional:"true"`
+}
+
+var (
+	_bindingInType = reflect.TypeOf(BindingIn{})
+	_bindingType   = reflect.TypeOf(&Binding{})
+	_outType       = reflect.TypeOf(di.Out{})
+	_errType       = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// ProvideBinding returns a constructor for core.Provide that binds the path
+// and keeps it updated on reload. The *Binding is provided under the name of
+// the path, so that several bindings can coexist:
+//
+//  c.Provide(di.Deps{config.ProvideBinding("http", HTTPConfig{})})
+//
+//  type handlerIn struct {
+//    di.In
+//    HTTP *config.Binding `name:"http"`
+//  }
+func ProvideBinding(path string, prototype interface{}) interface{} {
+	outType := reflect.StructOf([]reflect.StructField{
+		{Name: "Out", Type: _outType, Anonymous: true},
+		{Name: "Binding", Type: _bindingType, Tag: reflect.StructTag(fmt.Sprintf(`name:"%s"`, path))},
+	})
+	fnType := reflect.FuncOf([]reflect.Type{_bindingInType}, []reflect.Type{outType, _errType}, false)
+	fn := reflect.MakeFunc(fnType, func(args []reflect.Value) []reflect.Value {
+		in := args[0].Interface().(BindingIn)
+		out := reflect.New(outType).Elem()
+		binding, err := Bind(in.Conf, path, prototype)
+		if err != nil {
+			return []reflect.Value{out, reflect.ValueOf(&err).Elem()}
+		}
+		binding.SubscribeReloadEventFrom(in.Dispatcher)
+		out.Field(1).Set(reflect.ValueOf(binding))
+		return []reflect.Value{out, reflect.Zero(_errType)}
+	})
+	return fn.Interface()
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/di"
+	"github.com/DoNewsCode/core/events"
+	"github.com/stretchr/testify/assert"
+)
+
+type bindingTestConfig struct {
+	Addr    string `json:"addr"`
+	Disable bool   `json:"disable"`
+}
+
+func TestBinding(t *testing.T) {
+	t.Parallel()
+	var addr = ":8080"
+	dispatcher := &events.SyncDispatcher{}
+	conf, _ := NewConfig(
+		WithDispatcher(dispatcher),
+		WithProviderLayer(providerFunc(func() (map[string]interface{}, error) {
+			return map[string]interface{}{
+				"http": map[string]interface{}{"addr": addr},
+				"grpc": map[string]interface{}{"addr": ":9090"},
+			}, nil
+		}), nil),
+	)
+
+	binding, err := Bind(conf, "http", &bindingTestConfig{})
+	assert.NoError(t, err)
+	assert.Equal(t, "http", binding.Path())
+	assert.Equal(t, bindingTestConfig{Addr: ":8080"}, binding.Load())
+	binding.SubscribeReloadEventFrom(dispatcher)
+
+	var changes []interface{}
+	binding.OnChange(func(oldValue, newValue interface{}) {
+		changes = append(changes, oldValue, newValue)
+	})
+
+	// Reload without changes.
+	assert.NoError(t, conf.Reload())
+	assert.Empty(t, changes)
+
+	addr = ":8081"
+	assert.NoError(t, conf.Reload())
+	assert.Equal(t, bindingTestConfig{Addr: ":8081"}, binding.Load())
+	assert.Equal(t, []interface{}{bindingTestConfig{Addr: ":8080"}, bindingTestConfig{Addr: ":8081"}}, changes)
+
+	// Invalid values are ignored.
+	assert.Error(t, binding.Update(MapAdapter{"http": map[string]interface{}{"addr": []string{"a", "b"}}}))
+	assert.Equal(t, bindingTestConfig{Addr: ":8081"}, binding.Load())
+
+	_, err = Bind(conf, "http", nil)
+	assert.Error(t, err)
+}
+
+func TestProvideBinding(t *testing.T) {
+	t.Parallel()
+	g := di.NewGraph()
+	g.Provide(func() contract.ConfigUnmarshaler {
+		return MapAdapter{"http": map[string]interface{}{"addr": ":8080"}}
+	})
+	assert.NoError(t, g.Provide(ProvideBinding("http", bindingTestConfig{})))
+
+	type in struct {
+		di.In
+		HTTP *Binding `name:"http"`
+	}
+	assert.NoError(t, g.Invoke(func(i in) {
+		assert.Equal(t, ":8080", i.HTTP.Load().(bindingTestConfig).Addr)
+	}))
+}
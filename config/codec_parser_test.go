@@ -1,8 +1,12 @@
 package config
 
 import (
+	"github.com/DoNewsCode/core/codec/dotenv"
+	"github.com/DoNewsCode/core/codec/hcl"
+	"github.com/DoNewsCode/core/codec/toml"
 	"github.com/DoNewsCode/core/codec/yaml"
 	yaml2 "github.com/knadh/koanf/parsers/yaml"
+	"github.com/knadh/koanf/providers/rawbytes"
 	"github.com/stretchr/testify/assert"
 	"io/ioutil"
 	"testing"
@@ -24,3 +28,16 @@ func TestCodecParser_Unmarshal(t *testing.T) {
 	expected, _ := yaml2.Parser().Unmarshal(raw)
 	assert.Equal(t, expected, data)
 }
+
+func TestCodecParser_layers(t *testing.T) {
+	conf, err := NewConfig(
+		WithProviderLayer(rawbytes.Provider([]byte("http.addr=\":9090\"\n")), CodecParser{dotenv.Codec{}}),
+		WithProviderLayer(rawbytes.Provider([]byte("[http]\ntimeout = 3\n")), CodecParser{toml.Codec{}}),
+		WithProviderLayer(rawbytes.Provider([]byte("log {\n  level = \"debug\"\n}\n")), CodecParser{hcl.Codec{}}),
+		WithProviderLayer(rawbytes.Provider([]byte("http:\n  addr: :8080\n")), CodecParser{yaml.Codec{}}),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, ":9090", conf.String("http.addr"))
+	assert.Equal(t, 3, conf.Int("http.timeout"))
+	assert.Equal(t, "debug", conf.String("log.level"))
+}
@@ -4,12 +4,14 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/DoNewsCode/core/contract"
 	"github.com/DoNewsCode/core/events"
 	"github.com/knadh/koanf"
+	"github.com/knadh/koanf/maps"
 	"github.com/knadh/koanf/providers/confmap"
 	"github.com/mitchellh/mapstructure"
 )
@@ -18,6 +20,8 @@ import (
 type KoanfAdapter struct {
 	layers     []ProviderSet
 	validators []Validator
+	cipher     Cipher
+	policy     ReloadPolicy
 	watcher    contract.ConfigWatcher
 	dispatcher contract.Dispatcher
 	delimiter  string
@@ -71,9 +75,25 @@ func WithValidators(validators ...Validator) Option {
 	}
 }
 
+// WithCipher is an option for *KoanfAdapter that decrypts all values in the
+// ENC[...] form after the configuration stack is loaded. See AESGCM.
+func WithCipher(cipher Cipher) Option {
+	return func(option *KoanfAdapter) {
+		option.cipher = cipher
+	}
+}
+
+// WithReloadPolicy changes how the watcher notifications are debounced and how
+// failed reloads are retried. Defaults to DefaultReloadPolicy.
+func WithReloadPolicy(policy ReloadPolicy) Option {
+	return func(option *KoanfAdapter) {
+		option.policy = policy
+	}
+}
+
 // NewConfig creates a new *KoanfAdapter.
 func NewConfig(options ...Option) (*KoanfAdapter, error) {
-	adapter := KoanfAdapter{delimiter: "."}
+	adapter := KoanfAdapter{delimiter: ".", policy: DefaultReloadPolicy}
 
 	for _, f := range options {
 		f(&adapter)
@@ -101,6 +121,17 @@ func (k *KoanfAdapter) Reload() error {
 		}
 	}
 
+	if k.cipher != nil {
+		decrypted, err := decryptMap(k.cipher, tmp.Raw())
+		if err != nil {
+			return fmt.Errorf("unable to decrypt config %w", err)
+		}
+		tmp = koanf.New(".")
+		if err := tmp.Load(confmap.Provider(decrypted, ""), nil); err != nil {
+			return fmt.Errorf("unable to load decrypted config %w", err)
+		}
+	}
+
 	for _, f := range k.validators {
 		if err := f(tmp.Raw()); err != nil {
 			return fmt.Errorf("validation failed: %w", err)
@@ -108,11 +139,15 @@ func (k *KoanfAdapter) Reload() error {
 	}
 
 	k.rwlock.Lock()
+	old := k.K.Raw()
 	k.K = tmp
 	k.rwlock.Unlock()
 
 	if k.dispatcher != nil {
-		k.dispatcher.Dispatch(context.Background(), events.OnReload, events.OnReloadPayload{NewConf: k})
+		k.dispatcher.Dispatch(context.Background(), events.OnReload, events.OnReloadPayload{
+			NewConf: k,
+			Diff:    Diff(old, tmp.Raw()),
+		})
 	}
 
 	return nil
@@ -121,12 +156,16 @@ func (k *KoanfAdapter) Reload() error {
 // Watch uses the internal watcher to watch the configuration reload signals.
 // This function should be registered in the run group. If the watcher is nil,
 // this call will block until context expired.
+//
+// The reloads are coordinated by a ReloadCoordinator, so a failed reload keeps
+// the last good configuration, is retried according to the ReloadPolicy and
+// dispatches events.OnReloadFailed instead of stopping the watch.
 func (k *KoanfAdapter) Watch(ctx context.Context) error {
 	if k.watcher == nil {
 		<-ctx.Done()
 		return ctx.Err()
 	}
-	return k.watcher.Watch(ctx, k.Reload)
+	return NewReloadCoordinator(k.watcher, k.dispatcher, k.policy).Watch(ctx, k.Reload)
 }
 
 // Unmarshal unmarshals a given key path into the given struct using the mapstructure lib.
@@ -220,6 +259,35 @@ func (k *KoanfAdapter) Duration(s string) time.Duration {
 	return k.K.Duration(s)
 }
 
+// Diff computes the key level difference between two nested config maps. Slices
+// are compared as a whole.
+func Diff(oldConf, newConf map[string]interface{}) *events.ConfigDiff {
+	var (
+		diff       events.ConfigDiff
+		oldFlat, _ = maps.Flatten(oldConf, nil, ".")
+		newFlat, _ = maps.Flatten(newConf, nil, ".")
+	)
+	for key, newValue := range newFlat {
+		oldValue, ok := oldFlat[key]
+		if !ok {
+			diff.Added = append(diff.Added, key)
+			continue
+		}
+		if !reflect.DeepEqual(oldValue, newValue) {
+			diff.Modified = append(diff.Modified, key)
+		}
+	}
+	for key := range oldFlat {
+		if _, ok := newFlat[key]; !ok {
+			diff.Removed = append(diff.Removed, key)
+		}
+	}
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Modified)
+	return &diff
+}
+
 // MapAdapter implements ConfigUnmarshaler and ConfigRouter.
 // It is primarily used for testing
 type MapAdapter map[string]interface{}
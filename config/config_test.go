@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/DoNewsCode/core/config/watcher"
+	"github.com/DoNewsCode/core/events"
 	"github.com/knadh/koanf"
 	"github.com/knadh/koanf/parsers/json"
 	"github.com/knadh/koanf/parsers/yaml"
@@ -227,3 +228,64 @@ func prepareYamlTestSubject(t *gotesting.T) *KoanfAdapter {
 	ka := KoanfAdapter{K: k}
 	return &ka
 }
+
+func TestDiff(t *gotesting.T) {
+	t.Parallel()
+	diff := Diff(
+		map[string]interface{}{
+			"gorm":  map[string]interface{}{"default": map[string]interface{}{"dsn": "foo"}, "other": map[string]interface{}{"dsn": "bar"}},
+			"addrs": []interface{}{"a", "b"},
+			"name":  "app",
+		},
+		map[string]interface{}{
+			"gorm":  map[string]interface{}{"default": map[string]interface{}{"dsn": "baz"}, "other": map[string]interface{}{"dsn": "bar"}},
+			"addrs": []interface{}{"a", "c"},
+			"env":   "local",
+		},
+	)
+	assert.Equal(t, []string{"env"}, diff.Added)
+	assert.Equal(t, []string{"name"}, diff.Removed)
+	assert.Equal(t, []string{"addrs", "gorm.default.dsn"}, diff.Modified)
+	assert.True(t, diff.HasChanged("gorm.default"))
+	assert.True(t, diff.HasChanged("gorm"))
+	assert.False(t, diff.HasChanged("gorm.other"))
+	assert.False(t, diff.HasChanged("gorm.def"))
+	assert.False(t, diff.IsEmpty())
+	assert.True(t, Diff(map[string]interface{}{"foo": "bar"}, map[string]interface{}{"foo": "bar"}).IsEmpty())
+}
+
+func TestKoanfAdapter_Reload_diff(t *gotesting.T) {
+	t.Parallel()
+	var (
+		diff  *events.ConfigDiff
+		value = "bar"
+	)
+	dispatcher := &events.SyncDispatcher{}
+	dispatcher.Subscribe(events.Listen(events.OnReload, func(ctx context.Context, event interface{}) error {
+		diff = event.(events.OnReloadPayload).Diff
+		return nil
+	}))
+	conf, err := NewConfig(
+		WithDispatcher(dispatcher),
+		WithProviderLayer(providerFunc(func() (map[string]interface{}, error) {
+			return map[string]interface{}{"foo": value, "baz": "qux"}, nil
+		}), nil),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"baz", "foo"}, diff.Added)
+
+	value = "quux"
+	assert.NoError(t, conf.Reload())
+	assert.Empty(t, diff.Added)
+	assert.Equal(t, []string{"foo"}, diff.Modified)
+}
+
+type providerFunc func() (map[string]interface{}, error)
+
+func (p providerFunc) ReadBytes() ([]byte, error) {
+	return nil, errors.New("not supported")
+}
+
+func (p providerFunc) Read() (map[string]interface{}, error) {
+	return p()
+}
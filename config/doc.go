@@ -45,13 +45,37 @@
 //
 //  go run main.go config init -o ./config/config.yaml
 //
+// If the file already exists, only the missing entries are added, including the
+// ones nested below existing keys. In yaml, they are inserted in place and the
+// rest of the file, comments included, is left untouched. Existing keys are
+// never overwritten. To preview the entries that would be added:
+//
+//  go run main.go config diff -t ./config/config.yaml
+//
+// Besides yaml and json, these commands and config verify also speak toml, hcl
+// and dotenv through the --style flag. The same codecs can be used as layers in
+// the stack:
+//
+//  config.WithProviderLayer(file.Provider("./config/.env"), config.CodecParser{Codec: dotenv.Codec{}})
+//
+// Encryption
+//
+// Secrets can be committed into configuration files in an encrypted form, such
+// as ENC[AES256_GCM,...]. When a Cipher is set via WithCipher, these values are
+// decrypted every time the configuration stack is reloaded. Package core enables
+// decryption automatically if the key is found in $CORE_CONFIG_KEY_FILE or
+// $CORE_CONFIG_KEY. Use the config module to produce and inspect encrypted values:
+//
+//  go run main.go config encrypt mypassword
+//  go run main.go config decrypt -t ./config/config.yaml
+//
 // Best Practice
 //
 // In general you should not pass contract.ConfigAccessor or config.KoanfAdapter to your services. You should only
 // pass unmarshalled strings and structs that matters. You don't want your service unnecessarily depend on package config.
 //
-// The only exception is when you need configuration hot reload. In this case, you have to pass the contract.ConfigAccessor to your
-// service, and access the config repeatedly in each request/iteration of you service.
+// The only exception is when you need configuration hot reload. In this case, bind the config path to a struct with
+// Bind or ProvideBinding, and pass the *Binding to your service. Binding.Load always returns the latest value.
 //
 // Future scope
 //
@@ -0,0 +1,175 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// Environment variables consulted by LoadKey.
+const (
+	// EnvKey holds the base64 encoded encryption key.
+	EnvKey = "CORE_CONFIG_KEY"
+	// EnvKeyFile holds the path to a file containing the base64 encoded encryption key.
+	EnvKeyFile = "CORE_CONFIG_KEY_FILE"
+)
+
+const (
+	encryptedPrefix = "ENC[AES256_GCM,"
+	encryptedSuffix = "]"
+)
+
+// Cipher encrypts and decrypts individual configuration values.
+type Cipher interface {
+	// Encrypt encrypts the plaintext and returns it in the ENC[...] form.
+	Encrypt(plaintext []byte) (string, error)
+	// Decrypt takes a value in the ENC[...] form and returns the plaintext.
+	Decrypt(value string) ([]byte, error)
+}
+
+// AESGCM is a Cipher that seals values with AES-256 in GCM mode. Encrypted
+// values look like ENC[AES256_GCM,<base64 of nonce and ciphertext>], so they
+// can be committed directly into configuration files.
+type AESGCM struct {
+	aead cipher.AEAD
+}
+
+// NewAESGCM creates a *AESGCM from a 32 bytes key.
+func NewAESGCM(key []byte) (*AESGCM, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("AES256_GCM requires a 32 bytes key, got %d bytes", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &AESGCM{aead: aead}, nil
+}
+
+// Encrypt implements Cipher.
+func (a *AESGCM) Encrypt(plaintext []byte) (string, error) {
+	nonce := make([]byte, a.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := a.aead.Seal(nonce, nonce, plaintext, nil)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed) + encryptedSuffix, nil
+}
+
+// Decrypt implements Cipher.
+func (a *AESGCM) Decrypt(value string) ([]byte, error) {
+	if !IsEncrypted(value) {
+		return nil, fmt.Errorf("value is not in the form of %s...%s", encryptedPrefix, encryptedSuffix)
+	}
+	encoded := strings.TrimSuffix(strings.TrimPrefix(value, encryptedPrefix), encryptedSuffix)
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("malformed encrypted value: %w", err)
+	}
+	if len(sealed) < a.aead.NonceSize() {
+		return nil, errors.New("malformed encrypted value: too short")
+	}
+	nonce, ciphertext := sealed[:a.aead.NonceSize()], sealed[a.aead.NonceSize():]
+	plaintext, err := a.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt value: %w", err)
+	}
+	return plaintext, nil
+}
+
+// IsEncrypted reports whether the value is in the ENC[AES256_GCM,...] form.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, encryptedPrefix) && strings.HasSuffix(value, encryptedSuffix)
+}
+
+// KeyFromEnv reads the base64 encoded key from the given environmental variable.
+func KeyFromEnv(name string) ([]byte, error) {
+	encoded, ok := os.LookupEnv(name)
+	if !ok {
+		return nil, fmt.Errorf("environmental variable %s is not set", name)
+	}
+	return decodeKey(encoded)
+}
+
+// KeyFromFile reads the base64 encoded key from the given file.
+func KeyFromFile(path string) ([]byte, error) {
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read key file: %w", err)
+	}
+	return decodeKey(string(bytes))
+}
+
+// LoadKey resolves the encryption key from the environment. The file named by
+// CORE_CONFIG_KEY_FILE takes precedence over CORE_CONFIG_KEY. If neither is
+// set, LoadKey returns a nil key and no error.
+func LoadKey() ([]byte, error) {
+	if path := os.Getenv(EnvKeyFile); path != "" {
+		return KeyFromFile(path)
+	}
+	if _, ok := os.LookupEnv(EnvKey); ok {
+		return KeyFromEnv(EnvKey)
+	}
+	return nil, nil
+}
+
+func decodeKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("key must be base64 encoded: %w", err)
+	}
+	return key, nil
+}
+
+// decryptMap returns a copy of the map where all encrypted values, including
+// those nested in maps and slices, are replaced by their plaintext.
+func decryptMap(c Cipher, m map[string]interface{}) (map[string]interface{}, error) {
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		decrypted, err := decryptValue(c, v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", k, err)
+		}
+		out[k] = decrypted
+	}
+	return out, nil
+}
+
+func decryptValue(c Cipher, v interface{}) (interface{}, error) {
+	switch x := v.(type) {
+	case string:
+		if !IsEncrypted(x) {
+			return x, nil
+		}
+		plaintext, err := c.Decrypt(x)
+		if err != nil {
+			return nil, err
+		}
+		return string(plaintext), nil
+	case map[string]interface{}:
+		return decryptMap(c, x)
+	case []interface{}:
+		out := make([]interface{}, len(x))
+		for i := range x {
+			decrypted, err := decryptValue(c, x[i])
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+			out[i] = decrypted
+		}
+		return out, nil
+	default:
+		return v, nil
+	}
+}
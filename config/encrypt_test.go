@@ -0,0 +1,128 @@
+package config
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/knadh/koanf/providers/confmap"
+	"github.com/stretchr/testify/assert"
+)
+
+var testKey = []byte("0123456789abcdef0123456789abcdef")
+
+func TestAESGCM(t *testing.T) {
+	t.Parallel()
+	cipher, err := NewAESGCM(testKey)
+	assert.NoError(t, err)
+
+	encrypted, err := cipher.Encrypt([]byte("secret"))
+	assert.NoError(t, err)
+	assert.True(t, IsEncrypted(encrypted))
+
+	plaintext, err := cipher.Decrypt(encrypted)
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", string(plaintext))
+
+	_, err = cipher.Decrypt("secret")
+	assert.Error(t, err)
+
+	_, err = cipher.Decrypt("ENC[AES256_GCM,Zm9v]")
+	assert.Error(t, err)
+
+	other, _ := NewAESGCM([]byte("fedcba9876543210fedcba9876543210"))
+	_, err = other.Decrypt(encrypted)
+	assert.Error(t, err)
+
+	_, err = NewAESGCM([]byte("short"))
+	assert.Error(t, err)
+}
+
+func TestKoanfAdapter_Reload_decrypt(t *testing.T) {
+	t.Parallel()
+	cipher, _ := NewAESGCM(testKey)
+	password, _ := cipher.Encrypt([]byte("bar"))
+	port, _ := cipher.Encrypt([]byte("3306"))
+
+	conf, err := NewConfig(
+		WithCipher(cipher),
+		WithProviderLayer(confmap.Provider(map[string]interface{}{
+			"gorm.default.password": password,
+			"gorm.default.port":     port,
+			"addrs":                 []interface{}{password, "baz"},
+			"plain":                 "qux",
+		}, "."), nil),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, "bar", conf.String("gorm.default.password"))
+	assert.Equal(t, 3306, conf.Int("gorm.default.port"))
+	assert.Equal(t, []string{"bar", "baz"}, conf.Strings("addrs"))
+	assert.Equal(t, "qux", conf.String("plain"))
+
+	_, err = NewConfig(
+		WithCipher(cipher),
+		WithProviderLayer(confmap.Provider(map[string]interface{}{
+			"foo": "ENC[AES256_GCM,bad]",
+		}, "."), nil),
+	)
+	assert.Error(t, err)
+}
+
+func TestLoadKey(t *testing.T) {
+	encoded := base64.StdEncoding.EncodeToString(testKey)
+
+	os.Unsetenv(EnvKeyFile)
+	os.Unsetenv(EnvKey)
+	key, err := LoadKey()
+	assert.NoError(t, err)
+	assert.Nil(t, key)
+
+	os.Setenv(EnvKey, encoded)
+	defer os.Unsetenv(EnvKey)
+	key, err = LoadKey()
+	assert.NoError(t, err)
+	assert.Equal(t, testKey, key)
+
+	f, _ := ioutil.TempFile("", "key")
+	defer os.Remove(f.Name())
+	f.WriteString(base64.StdEncoding.EncodeToString([]byte("fedcba9876543210fedcba9876543210")) + "\n")
+	f.Close()
+	os.Setenv(EnvKeyFile, f.Name())
+	defer os.Unsetenv(EnvKeyFile)
+	key, err = LoadKey()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("fedcba9876543210fedcba9876543210"), key)
+}
+
+func TestModule_ProvideCommand_encryptCmd(t *testing.T) {
+	f, _ := ioutil.TempFile("", "key")
+	defer os.Remove(f.Name())
+	f.WriteString(base64.StdEncoding.EncodeToString(testKey))
+	f.Close()
+
+	run := func(args ...string) string {
+		var out bytes.Buffer
+		rootCmd := setup()
+		rootCmd.SetOut(&out)
+		rootCmd.SetIn(strings.NewReader("from stdin\n"))
+		rootCmd.SetArgs(append(args, "--keyFile", f.Name()))
+		assert.NoError(t, rootCmd.Execute())
+		return strings.TrimSpace(out.String())
+	}
+
+	encrypted := run("config", "encrypt", "secret")
+	assert.True(t, IsEncrypted(encrypted))
+	assert.Equal(t, "secret", run("config", "decrypt", encrypted))
+
+	encrypted = run("config", "encrypt")
+	assert.Equal(t, "from stdin", run("config", "decrypt", encrypted))
+
+	target, _ := ioutil.TempFile("", "*.yaml")
+	defer os.Remove(target.Name())
+	target.WriteString("foo: " + encrypted + "\n")
+	target.Close()
+	assert.Equal(t, "foo: from stdin", run("config", "decrypt", "--targetFile", target.Name()))
+}
@@ -0,0 +1,196 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/DoNewsCode/core/contract"
+	"gopkg.in/yaml.v3"
+)
+
+// normalize converts v into the plain map the codec would produce when reading
+// it back from a file, so that it can be compared with the content of the file.
+func normalize(codec contract.Codec, v interface{}) (map[string]interface{}, error) {
+	data, err := codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out map[string]interface{}
+	if err := codec.Unmarshal(data, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// subtract returns the entries of defaults that are absent from existing. The
+// nested maps are compared recursively. A value the user has set, even to
+// something of another type, is never part of the result.
+func subtract(defaults, existing map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{})
+	for k, v := range defaults {
+		old, ok := existing[k]
+		if !ok {
+			out[k] = v
+			continue
+		}
+		newMap, ok1 := v.(map[string]interface{})
+		oldMap, ok2 := old.(map[string]interface{})
+		if !ok1 || !ok2 {
+			continue
+		}
+		if sub := subtract(newMap, oldMap); len(sub) > 0 {
+			out[k] = sub
+		}
+	}
+	return out
+}
+
+// mergeMissing copies the entries of src that are absent from dst into dst.
+func mergeMissing(dst, src map[string]interface{}) {
+	for k, v := range src {
+		old, ok := dst[k]
+		if !ok {
+			dst[k] = v
+			continue
+		}
+		newMap, ok1 := v.(map[string]interface{})
+		oldMap, ok2 := old.(map[string]interface{})
+		if ok1 && ok2 {
+			mergeMissing(oldMap, newMap)
+		}
+	}
+}
+
+// missingEntries returns the entries of config that are absent from confMap,
+// normalized by the codec. confMap is updated to include them, so that several
+// configs sharing a top level key are merged rather than duplicated.
+func missingEntries(codec contract.Codec, config ExportedConfig, confMap map[string]interface{}) (map[string]interface{}, error) {
+	defaults, err := normalize(codec, config.Data)
+	if err != nil {
+		return nil, err
+	}
+	missing := subtract(defaults, confMap)
+	mergeMissing(confMap, missing)
+	return missing, nil
+}
+
+// insertYAML inserts the missing entries below the existing keys of the yaml
+// document. Only the nested entries are inserted: the missing top level keys
+// are left to the caller. The lines of the document are kept as they are, so
+// the comments, blank lines and ordering of the user survive.
+func insertYAML(src []byte, missing map[string]interface{}) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(src, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return src, nil
+	}
+
+	lines := strings.Split(string(src), "\n")
+	inserts := make(map[int][]string)
+	step := indentStep(doc.Content[0])
+	if step == 0 {
+		step = 4
+	}
+	if err := insertMapping(doc.Content[0], missing, len(lines), step, lines, inserts, true); err != nil {
+		return nil, err
+	}
+	if len(inserts) == 0 {
+		return src, nil
+	}
+
+	var out []string
+	for i, line := range lines {
+		out = append(out, line)
+		out = append(out, inserts[i+1]...)
+	}
+	return []byte(strings.Join(out, "\n")), nil
+}
+
+// insertMapping records the lines to insert into the mapping node, which spans
+// up to the line end (1-based, inclusive).
+func insertMapping(node *yaml.Node, missing map[string]interface{}, end int, step int, lines []string, inserts map[int][]string, top bool) error {
+	var absent = make(map[string]interface{})
+	for k, v := range missing {
+		absent[k] = v
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		sub, ok := missing[key.Value].(map[string]interface{})
+		delete(absent, key.Value)
+		if !ok || value.Kind != yaml.MappingNode || value.Style&yaml.FlowStyle != 0 {
+			continue
+		}
+		valueEnd := end
+		if i+2 < len(node.Content) {
+			next := node.Content[i+2]
+			valueEnd = next.Line - 1
+			if next.HeadComment != "" {
+				valueEnd -= strings.Count(next.HeadComment, "\n") + 1
+			}
+		}
+		if err := insertMapping(value, sub, valueEnd, step, lines, inserts, false); err != nil {
+			return err
+		}
+	}
+
+	if top || len(absent) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(step)
+	if err := encoder.Encode(absent); err != nil {
+		return err
+	}
+	data := buf.Bytes()
+	indent := strings.Repeat(" ", node.Content[0].Column-1)
+	pos := end
+	for pos > 1 && strings.TrimSpace(lines[pos-1]) == "" {
+		pos--
+	}
+	for _, line := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+		inserts[pos] = append(inserts[pos], indent+line)
+	}
+	return nil
+}
+
+// indentStep finds the indentation the document uses for nested mappings.
+func indentStep(node *yaml.Node) int {
+	if node.Kind != yaml.MappingNode {
+		return 0
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if value.Kind != yaml.MappingNode || value.Style&yaml.FlowStyle != 0 || len(value.Content) == 0 {
+			continue
+		}
+		return value.Content[0].Column - key.Column
+	}
+	return 0
+}
+
+// diffLines lists the flattened paths of the missing entries with their
+// values, one per line.
+func diffLines(missing map[string]interface{}, prefix string) []string {
+	var lines []string
+	for k, v := range missing {
+		path := prefix + k
+		if m, ok := v.(map[string]interface{}); ok && len(m) > 0 {
+			lines = append(lines, diffLines(m, path+".")...)
+			continue
+		}
+		value, err := json.Marshal(v)
+		if err != nil {
+			value = []byte(fmt.Sprint(v))
+		}
+		lines = append(lines, fmt.Sprintf("+ %s: %s", path, value))
+	}
+	sort.Strings(lines)
+	return lines
+}
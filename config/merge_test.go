@@ -0,0 +1,65 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubtract(t *testing.T) {
+	defaults := map[string]interface{}{
+		"a": 1,
+		"b": map[string]interface{}{"c": 2, "d": 3},
+		"e": map[string]interface{}{"f": 4},
+	}
+	existing := map[string]interface{}{
+		"a": 10,
+		"b": map[string]interface{}{"c": 20},
+		"e": "overridden",
+	}
+	assert.Equal(t, map[string]interface{}{
+		"b": map[string]interface{}{"d": 3},
+	}, subtract(defaults, existing))
+}
+
+func TestInsertYAML(t *testing.T) {
+	cases := []struct {
+		name     string
+		src      string
+		missing  map[string]interface{}
+		expected string
+	}{
+		{
+			"before the comment of the next key",
+			"a:\n    b: 1 # keep\n# about c\nc: 2\n",
+			map[string]interface{}{"a": map[string]interface{}{"d": 3}},
+			"a:\n    b: 1 # keep\n    d: 3\n# about c\nc: 2\n",
+		},
+		{
+			"after a literal block",
+			"a:\n  b: |\n    line1\n    line2\n\n",
+			map[string]interface{}{"a": map[string]interface{}{"c": map[string]interface{}{"d": 1}}},
+			"a:\n  b: |\n    line1\n    line2\n  c:\n    d: 1\n\n",
+		},
+		{
+			"flow mappings are left alone",
+			"a: {b: 1}\n",
+			map[string]interface{}{"a": map[string]interface{}{"c": 2}},
+			"a: {b: 1}\n",
+		},
+		{
+			"top level keys are left to the caller",
+			"a: 1\n",
+			map[string]interface{}{"b": 2},
+			"a: 1\n",
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			out, err := insertYAML([]byte(c.src), c.missing)
+			assert.NoError(t, err)
+			assert.Equal(t, c.expected, string(out))
+		})
+	}
+}
@@ -1,16 +1,24 @@
 package config
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/DoNewsCode/core/codec/dotenv"
+	"github.com/DoNewsCode/core/codec/hcl"
 	"github.com/DoNewsCode/core/codec/json"
+	"github.com/DoNewsCode/core/codec/toml"
 	"github.com/DoNewsCode/core/codec/yaml"
 	"github.com/DoNewsCode/core/contract"
 	"github.com/DoNewsCode/core/di"
+	"github.com/DoNewsCode/core/events"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
 	"github.com/oklog/run"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
@@ -29,6 +37,7 @@ type ConfigIn struct {
 	di.In
 
 	Conf            contract.ConfigAccessor
+	Logger          log.Logger          `optional:"true"`
 	Dispatcher      contract.Dispatcher `optional:"true"`
 	ExportedConfigs []ExportedConfig    `group:"config"`
 }
@@ -47,6 +56,19 @@ func New(p ConfigIn) (Module, error) {
 		return Module{}, err
 	}
 
+	if p.Dispatcher != nil && p.Logger != nil {
+		p.Dispatcher.Subscribe(events.Listen(events.OnReloadFailed, func(ctx context.Context, event interface{}) error {
+			payload := event.(events.OnReloadFailedPayload)
+			level.Warn(p.Logger).Log(
+				"msg", "failed to reload config, keeping the previous one",
+				"attempt", payload.Attempt,
+				"willRetry", payload.WillRetry,
+				"err", payload.Err,
+			)
+			return nil
+		}))
+	}
+
 	return Module{
 		dispatcher:      p.Dispatcher,
 		conf:            adapter,
@@ -70,6 +92,8 @@ func (m Module) ProvideCommand(command *cobra.Command) {
 	var (
 		targetFilePath string
 		style          string
+		keyFilePath    string
+		profile        string
 	)
 	initCmd := &cobra.Command{
 		Use:   "init [module]",
@@ -87,20 +111,9 @@ func (m Module) ProvideCommand(command *cobra.Command) {
 			if err != nil {
 				return err
 			}
-			if len(args) == 0 {
-				exportedConfigs = m.exportedConfigs
-			}
-			if len(args) >= 1 {
-				var copy = make([]ExportedConfig, 0)
-				for i := range m.exportedConfigs {
-					for j := 0; j < len(args); j++ {
-						if args[j] == m.exportedConfigs[i].Owner {
-							copy = append(copy, m.exportedConfigs[i])
-							break
-						}
-					}
-				}
-				exportedConfigs = copy
+			exportedConfigs = m.selectConfigs(args)
+			if profile != "" {
+				return scaffoldProfile(targetFilePath, profile, style, exportedConfigs)
 			}
 			os.MkdirAll(filepath.Dir(targetFilePath), os.ModePerm)
 			targetFile, err = os.OpenFile(targetFilePath,
@@ -141,21 +154,7 @@ func (m Module) ProvideCommand(command *cobra.Command) {
 			if err != nil {
 				return err
 			}
-			if len(args) == 0 {
-				exportedConfigs = m.exportedConfigs
-			}
-			if len(args) >= 1 {
-				var copy = make([]ExportedConfig, 0)
-				for i := range m.exportedConfigs {
-					for j := 0; j < len(args); j++ {
-						if args[j] == m.exportedConfigs[i].Owner {
-							copy = append(copy, m.exportedConfigs[i])
-							break
-						}
-					}
-				}
-				exportedConfigs = copy
-			}
+			exportedConfigs = m.selectConfigs(args)
 			os.MkdirAll(filepath.Dir(targetFilePath), os.ModePerm)
 			targetFile, err = os.OpenFile(targetFilePath,
 				handler.flags(), os.ModePerm)
@@ -183,6 +182,127 @@ func (m Module) ProvideCommand(command *cobra.Command) {
 		},
 	}
 
+	diffCmd := &cobra.Command{
+		Use:   "diff [module]",
+		Short: "preview the changes of config init.",
+		Long:  "list the default config entries that config init would add to the config file.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			codec, err := getCodec(style)
+			if err != nil {
+				return err
+			}
+			data, err := ioutil.ReadFile(targetFilePath)
+			if err != nil && !os.IsNotExist(err) {
+				return errors.Wrap(err, "failed to read config file")
+			}
+			var confMap map[string]interface{}
+			if len(data) > 0 {
+				if err := codec.Unmarshal(data, &confMap); err != nil {
+					return errors.Wrap(err, "failed to unmarshal config file")
+				}
+			}
+			if confMap == nil {
+				confMap = make(map[string]interface{})
+			}
+			for _, config := range m.selectConfigs(args) {
+				missing, err := missingEntries(codec, config, confMap)
+				if err != nil {
+					return err
+				}
+				if len(missing) == 0 {
+					continue
+				}
+				if config.Comment != "" {
+					fmt.Fprintln(cmd.OutOrStdout(), "# "+config.Comment)
+				}
+				for _, line := range diffLines(missing, "") {
+					fmt.Fprintln(cmd.OutOrStdout(), line)
+				}
+			}
+			return nil
+		},
+	}
+
+	initCmd.Flags().StringVarP(
+		&profile,
+		"env",
+		"e",
+		"",
+		"Scaffold the overlay of the target file for the given env, such as production or local",
+	)
+
+	encryptCmd := &cobra.Command{
+		Use:   "encrypt [value]",
+		Short: "encrypt a config value.",
+		Long:  "encrypt a config value so that it can be committed into the config file. If no value is given, read it from stdin.",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cipher, err := m.resolveCipher(keyFilePath)
+			if err != nil {
+				return err
+			}
+			var plaintext []byte
+			if len(args) == 1 {
+				plaintext = []byte(args[0])
+			} else {
+				plaintext, err = ioutil.ReadAll(cmd.InOrStdin())
+				if err != nil {
+					return errors.Wrap(err, "failed to read from stdin")
+				}
+				plaintext = bytes.TrimRight(plaintext, "\r\n")
+			}
+			encrypted, err := cipher.Encrypt(plaintext)
+			if err != nil {
+				return errors.Wrap(err, "failed to encrypt value")
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), encrypted)
+			return nil
+		},
+	}
+
+	decryptCmd := &cobra.Command{
+		Use:   "decrypt [value]",
+		Short: "decrypt a config value or the config file.",
+		Long:  "decrypt a config value. If no value is given, print the config file with all values decrypted.",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cipher, err := m.resolveCipher(keyFilePath)
+			if err != nil {
+				return err
+			}
+			if len(args) == 1 {
+				plaintext, err := cipher.Decrypt(args[0])
+				if err != nil {
+					return err
+				}
+				fmt.Fprintln(cmd.OutOrStdout(), string(plaintext))
+				return nil
+			}
+			codec, err := getCodec(style)
+			if err != nil {
+				return err
+			}
+			data, err := ioutil.ReadFile(targetFilePath)
+			if err != nil {
+				return errors.Wrap(err, "failed to read config file")
+			}
+			var confMap map[string]interface{}
+			if err := codec.Unmarshal(data, &confMap); err != nil {
+				return errors.Wrap(err, "failed to unmarshal config file")
+			}
+			confMap, err = decryptMap(cipher, confMap)
+			if err != nil {
+				return errors.Wrap(err, "failed to decrypt config file")
+			}
+			data, err = codec.Marshal(confMap)
+			if err != nil {
+				return err
+			}
+			_, err = cmd.OutOrStdout().Write(data)
+			return err
+		},
+	}
+
 	configCmd := &cobra.Command{
 		Use:   "config",
 		Short: "manage configuration",
@@ -207,13 +327,41 @@ func (m Module) ProvideCommand(command *cobra.Command) {
 		"style",
 		"s",
 		"yaml",
-		"The output file style",
+		"The output file style: yaml, json, toml, hcl or env",
+	)
+	configCmd.PersistentFlags().StringVarP(
+		&keyFilePath,
+		"keyFile",
+		"k",
+		"",
+		fmt.Sprintf("The file containing the encryption key (defaults to $%s or $%s)", EnvKeyFile, EnvKey),
 	)
 	configCmd.AddCommand(initCmd)
 	configCmd.AddCommand(verifyCmd)
+	configCmd.AddCommand(diffCmd)
+	configCmd.AddCommand(encryptCmd)
+	configCmd.AddCommand(decryptCmd)
 	command.AddCommand(configCmd)
 }
 
+// selectConfigs returns the exported configs owned by the given modules, or all
+// of them if no module is given.
+func (m Module) selectConfigs(owners []string) []ExportedConfig {
+	if len(owners) == 0 {
+		return m.exportedConfigs
+	}
+	var selected = make([]ExportedConfig, 0)
+	for i := range m.exportedConfigs {
+		for j := 0; j < len(owners); j++ {
+			if owners[j] == m.exportedConfigs[i].Owner {
+				selected = append(selected, m.exportedConfigs[i])
+				break
+			}
+		}
+	}
+	return selected
+}
+
 func loadValidators(k *KoanfAdapter, exportedConfigs []ExportedConfig) error {
 	for _, config := range exportedConfigs {
 		if config.Validate == nil {
@@ -229,17 +377,104 @@ func loadValidators(k *KoanfAdapter, exportedConfigs []ExportedConfig) error {
 	return nil
 }
 
-func getHandler(style string) (handler, error) {
+// scaffoldProfile creates the overlay of the target file for the given env. All
+// entries are commented out, so the overlay changes nothing until the user
+// uncomments the entries to override.
+func scaffoldProfile(targetFilePath string, profile string, style string, configs []ExportedConfig) error {
+	if style != "yaml" {
+		return fmt.Errorf("overlays are only supported in yaml, got %s", style)
+	}
+	env := NewEnv(profile)
+	if env == EnvUnknown {
+		return fmt.Errorf("unknown env %s", profile)
+	}
+	overlayPath := ProfileFile(targetFilePath, env.String())
+	if _, err := os.Stat(overlayPath); err == nil {
+		return fmt.Errorf("overlay %s already exists", overlayPath)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# Overrides of %s for the %s env.\n", filepath.Base(targetFilePath), env)
+	fmt.Fprintln(&buf, "# Uncomment the entries to override.")
+	for _, config := range configs {
+		data, err := yaml.Codec{}.Marshal(config.Data)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(&buf)
+		if config.Comment != "" {
+			fmt.Fprintln(&buf, "## "+config.Comment)
+		}
+		for _, line := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+			fmt.Fprintln(&buf, "# "+line)
+		}
+	}
+
+	os.MkdirAll(filepath.Dir(overlayPath), os.ModePerm)
+	if err := ioutil.WriteFile(overlayPath, buf.Bytes(), 0644); err != nil {
+		return errors.Wrap(err, "failed to write overlay")
+	}
+	return nil
+}
+
+// resolveCipher finds the cipher for the encrypt and decrypt commands. The key
+// file given on the command line wins, followed by the cipher of the running
+// config and the key in the environment.
+func (m Module) resolveCipher(keyFilePath string) (Cipher, error) {
+	if keyFilePath != "" {
+		key, err := KeyFromFile(keyFilePath)
+		if err != nil {
+			return nil, err
+		}
+		return NewAESGCM(key)
+	}
+	if m.conf != nil && m.conf.cipher != nil {
+		return m.conf.cipher, nil
+	}
+	key, err := LoadKey()
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, fmt.Errorf("no encryption key found, use --keyFile or set $%s", EnvKey)
+	}
+	return NewAESGCM(key)
+}
+
+func getCodec(style string) (contract.Codec, error) {
 	switch style {
 	case "json":
-		return rewriteHandler{codec: json.NewCodec(json.WithIndent("  "))}, nil
+		return json.NewCodec(json.WithIndent("  ")), nil
 	case "yaml":
-		return appendHandler{codec: yaml.Codec{}}, nil
+		return yaml.Codec{}, nil
+	case "toml":
+		return toml.Codec{}, nil
+	case "hcl":
+		return hcl.Codec{}, nil
+	case "env", "dotenv":
+		return dotenv.Codec{}, nil
 	default:
 		return nil, fmt.Errorf("unsupported config style %s", style)
 	}
 }
 
+func getHandler(style string) (handler, error) {
+	codec, err := getCodec(style)
+	if err != nil {
+		return nil, err
+	}
+	switch style {
+	// In json, the document is a single object. In toml, the top level keys
+	// must come before the first table. Neither can be appended to.
+	case "json", "toml":
+		return rewriteHandler{codec: codec}, nil
+	case "yaml":
+		return yamlHandler{codec: codec}, nil
+	default:
+		return appendHandler{codec: codec}, nil
+	}
+}
+
 type handler interface {
 	flags() int
 	unmarshal(bytes []byte, o interface{}) error
@@ -259,17 +494,22 @@ func (y appendHandler) unmarshal(bytes []byte, o interface{}) error {
 }
 
 func (y appendHandler) write(file *os.File, configs []ExportedConfig, confMap map[string]interface{}) error {
-out:
+	if confMap == nil {
+		confMap = make(map[string]interface{})
+	}
 	for i, config := range configs {
-		for k := range config.Data {
-			if _, ok := confMap[k]; ok {
-				continue out
-			}
+		present := topLevelKeys(confMap)
+		missing, err := missingEntries(y.codec, config, confMap)
+		if err != nil {
+			return err
+		}
+		if len(missing) == 0 {
+			continue
 		}
 		if i != 0 {
 			fmt.Fprintln(file, "")
 		}
-		bytes, err := y.codec.Marshal(config.Data)
+		bytes, err := y.codec.Marshal(withDefaults(config, missing, present))
 		if err != nil {
 			return err
 		}
@@ -287,6 +527,78 @@ out:
 	return nil
 }
 
+// yamlHandler merges the missing entries into a yaml file. Entries below
+// existing keys are inserted in place, and new top level keys are appended
+// along with the comment of the config. The rest of the file is left as is.
+type yamlHandler struct {
+	codec contract.Codec
+}
+
+func (y yamlHandler) flags() int {
+	return os.O_CREATE | os.O_RDWR
+}
+
+func (y yamlHandler) unmarshal(bytes []byte, o interface{}) error {
+	return y.codec.Unmarshal(bytes, o)
+}
+
+func (y yamlHandler) write(file *os.File, configs []ExportedConfig, confMap map[string]interface{}) error {
+	if confMap == nil {
+		confMap = make(map[string]interface{})
+	}
+	file.Seek(0, 0)
+	src, err := ioutil.ReadAll(file)
+	if err != nil {
+		return err
+	}
+	for i, config := range configs {
+		present := topLevelKeys(confMap)
+		missing, err := missingEntries(y.codec, config, confMap)
+		if err != nil {
+			return err
+		}
+		var nested, fresh = make(map[string]interface{}), make(map[string]interface{})
+		for k, v := range missing {
+			if present[k] {
+				nested[k] = v
+				continue
+			}
+			fresh[k] = config.Data[k]
+		}
+		if len(nested) > 0 {
+			src, err = insertYAML(src, nested)
+			if err != nil {
+				return err
+			}
+		}
+		if len(fresh) == 0 {
+			continue
+		}
+		var buf = bytes.NewBuffer(src)
+		if len(src) > 0 && src[len(src)-1] != '\n' {
+			buf.WriteString("\n")
+		}
+		if i != 0 {
+			buf.WriteString("\n")
+		}
+		if config.Comment != "" {
+			buf.WriteString("# " + config.Comment + "\n")
+		}
+		data, err := y.codec.Marshal(fresh)
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+		src = buf.Bytes()
+	}
+	if err := file.Truncate(0); err != nil {
+		return err
+	}
+	file.Seek(0, 0)
+	_, err = file.Write(src)
+	return err
+}
+
 type rewriteHandler struct {
 	codec contract.Codec
 }
@@ -297,7 +609,7 @@ func (r rewriteHandler) flags() int {
 
 func (r rewriteHandler) unmarshal(bytes []byte, o interface{}) error {
 	if len(bytes) == 0 {
-		bytes = []byte("{}")
+		return nil
 	}
 	return r.codec.Unmarshal(bytes, o)
 }
@@ -307,21 +619,45 @@ func (r rewriteHandler) write(file *os.File, configs []ExportedConfig, confMap m
 		confMap = make(map[string]interface{})
 	}
 	for _, exportedConfig := range configs {
-		for k := range exportedConfig.Data {
-			if _, ok := confMap[k]; ok {
-				continue
-			}
-			confMap[k] = exportedConfig.Data[k]
+		if _, err := missingEntries(r.codec, exportedConfig, confMap); err != nil {
+			return err
 		}
 	}
-	file.Seek(0, 0)
 	data, err := r.codec.Marshal(confMap)
 	if err != nil {
 		return err
 	}
-	if _, err := file.Write(data); err != nil {
+	// The codec may format the existing entries more compactly, so the old
+	// content has to go.
+	if err := file.Truncate(0); err != nil {
+		return err
+	}
+	file.Seek(0, 0)
+	if _, err := file.Write(bytes.TrimRight(data, "\n")); err != nil {
 		return err
 	}
 	fmt.Fprintln(file)
 	return err
 }
+
+func topLevelKeys(m map[string]interface{}) map[string]bool {
+	keys := make(map[string]bool, len(m))
+	for k := range m {
+		keys[k] = true
+	}
+	return keys
+}
+
+// withDefaults picks the entries to write for the config. The new top level
+// keys are taken from the config as is, and the rest from the missing entries.
+func withDefaults(config ExportedConfig, missing map[string]interface{}, present map[string]bool) map[string]interface{} {
+	out := make(map[string]interface{}, len(missing))
+	for k, v := range missing {
+		if present[k] {
+			out[k] = v
+			continue
+		}
+		out[k] = config.Data[k]
+	}
+	return out
+}
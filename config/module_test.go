@@ -4,6 +4,7 @@ import (
 	"context"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"testing"
@@ -56,8 +57,11 @@ func setup() *cobra.Command {
 func tearDown() {
 	os.Remove("./testdata/module_test.yaml")
 	os.Remove("./testdata/module_test.json")
+	os.Remove("./testdata/module_test.hcl")
 	ioutil.WriteFile("./testdata/module_test_partial.json", []byte("{\n  \"foo\": \"bar\"\n}"), os.ModePerm)
 	ioutil.WriteFile("./testdata/module_test_partial.yaml", []byte("# A mock config\nfoo: bar\n"), os.ModePerm)
+	ioutil.WriteFile("./testdata/module_test_partial.toml", []byte("# A mock config\nfoo = \"baz\"\n"), os.ModePerm)
+	ioutil.WriteFile("./testdata/module_test_partial.env", []byte("# A mock config\nfoo=\"baz\"\n"), os.ModePerm)
 }
 
 func TestModule_ProvideCommand_initCmd(t *testing.T) {
@@ -105,6 +109,24 @@ func TestModule_ProvideCommand_initCmd(t *testing.T) {
 			[]string{"config", "init", "baz", "--outputFile", "./testdata/module_test_partial.yaml"},
 			"./testdata/module_test_partial_expected.yaml",
 		},
+		{
+			"partial toml",
+			"./testdata/module_test_partial.toml",
+			[]string{"config", "init", "--outputFile", "./testdata/module_test_partial.toml", "--style", "toml"},
+			"./testdata/module_test_partial_expected.toml",
+		},
+		{
+			"hcl",
+			"./testdata/module_test.hcl",
+			[]string{"config", "init", "--outputFile", "./testdata/module_test.hcl", "--style", "hcl"},
+			"./testdata/module_test_expected.hcl",
+		},
+		{
+			"partial env",
+			"./testdata/module_test_partial.env",
+			[]string{"config", "init", "--outputFile", "./testdata/module_test_partial.env", "--style", "env"},
+			"./testdata/module_test_partial_expected.env",
+		},
 	}
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
@@ -155,6 +177,16 @@ func TestModule_ProvideCommand_verifyCmd(t *testing.T) {
 			[]string{"config", "verify", "foo", "--targetFile", "./testdata/module_test_gold.yaml"},
 			false,
 		},
+		{
+			"good toml config",
+			[]string{"config", "verify", "--targetFile", "./testdata/module_test_gold.toml", "--style", "toml"},
+			false,
+		},
+		{
+			"bad toml config",
+			[]string{"config", "verify", "--targetFile", "./testdata/module_test_empty.yaml", "--style", "toml"},
+			true,
+		},
 	}
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
@@ -217,3 +249,92 @@ type MockWatcher struct{}
 func (m *MockWatcher) Watch(ctx context.Context, reload func() error) error {
 	return reload()
 }
+
+func TestModule_ProvideCommand_initCmd_nested(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "nested")
+	defer os.RemoveAll(dir)
+
+	var mod = Module{
+		exportedConfigs: []ExportedConfig{
+			{
+				Owner: "writer",
+				Data: map[string]interface{}{"kafka": map[string]interface{}{"writer": map[string]interface{}{
+					"default": map[string]interface{}{"brokers": []string{"127.0.0.1:9092"}, "topic": "test"},
+				}}},
+				Comment: "The kafka writer",
+			},
+			{
+				Owner: "reader",
+				Data: map[string]interface{}{"kafka": map[string]interface{}{"reader": map[string]interface{}{
+					"default": map[string]interface{}{"brokers": []string{"127.0.0.1:9092"}},
+				}}},
+				Comment: "The kafka reader",
+			},
+			{
+				Owner:   "http",
+				Data:    map[string]interface{}{"http": map[string]interface{}{"addr": ":8080"}},
+				Comment: "The http server",
+			},
+		},
+	}
+
+	cases := []struct {
+		name     string
+		style    string
+		original string
+		expected string
+		diff     string
+	}{
+		{
+			"yaml",
+			"yaml",
+			"# Kafka\nkafka:\n  writer:\n    default:\n      brokers:\n        - kafka:9092 # production\n\n# Logging\nlog:\n  level: debug\n",
+			"# Kafka\nkafka:\n  writer:\n    default:\n      brokers:\n        - kafka:9092 # production\n      topic: test\n  reader:\n    default:\n      brokers:\n        - 127.0.0.1:9092\n\n# Logging\nlog:\n  level: debug\n\n# The http server\nhttp:\n    addr: :8080\n",
+			"# The kafka writer\n+ kafka.writer.default.topic: \"test\"\n# The kafka reader\n+ kafka.reader.default.brokers: [\"127.0.0.1:9092\"]\n# The http server\n+ http.addr: \":8080\"\n",
+		},
+		{
+			"json",
+			"json",
+			"{\"kafka\": {\"writer\": {\"default\": {\"brokers\": [\"kafka:9092\"]}}}}",
+			"{\n  \"http\": {\n    \"addr\": \":8080\"\n  },\n  \"kafka\": {\n    \"reader\": {\n      \"default\": {\n        \"brokers\": [\n          \"127.0.0.1:9092\"\n        ]\n      }\n    },\n    \"writer\": {\n      \"default\": {\n        \"brokers\": [\n          \"kafka:9092\"\n        ],\n        \"topic\": \"test\"\n      }\n    }\n  }\n}\n",
+			"# The kafka writer\n+ kafka.writer.default.topic: \"test\"\n# The kafka reader\n+ kafka.reader.default.brokers: [\"127.0.0.1:9092\"]\n# The http server\n+ http.addr: \":8080\"\n",
+		},
+		{
+			"env",
+			"env",
+			"kafka.writer.default.brokers='[\"kafka:9092\"]'\n",
+			"kafka.writer.default.brokers='[\"kafka:9092\"]'\n# The kafka writer\nkafka.writer.default.topic=\"test\"\n\n# The kafka reader\nkafka.reader.default.brokers=\"[\\\"127.0.0.1:9092\\\"]\"\n\n# The http server\nhttp.addr=\":8080\"\n",
+			"# The kafka writer\n+ kafka.writer.default.topic: \"test\"\n# The kafka reader\n+ kafka.reader.default.brokers: [\"127.0.0.1:9092\"]\n# The http server\n+ http.addr: \":8080\"\n",
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			target := filepath.Join(dir, "config."+c.style)
+			ioutil.WriteFile(target, []byte(c.original), os.ModePerm)
+
+			var out strings.Builder
+			rootCmd := &cobra.Command{Use: "root"}
+			mod.ProvideCommand(rootCmd)
+			rootCmd.SetOut(&out)
+			rootCmd.SetArgs([]string{"config", "diff", "--targetFile", target, "--style", c.style})
+			assert.NoError(t, rootCmd.Execute())
+			assert.Equal(t, c.diff, out.String())
+
+			rootCmd = &cobra.Command{Use: "root"}
+			mod.ProvideCommand(rootCmd)
+			rootCmd.SetArgs([]string{"config", "init", "--targetFile", target, "--style", c.style})
+			assert.NoError(t, rootCmd.Execute())
+			bytes, _ := ioutil.ReadFile(target)
+			assert.Equal(t, c.expected, string(bytes))
+
+			out.Reset()
+			rootCmd = &cobra.Command{Use: "root"}
+			mod.ProvideCommand(rootCmd)
+			rootCmd.SetOut(&out)
+			rootCmd.SetArgs([]string{"config", "diff", "--targetFile", target, "--style", c.style})
+			assert.NoError(t, rootCmd.Execute())
+			assert.Empty(t, out.String())
+		})
+	}
+}
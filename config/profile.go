@@ -0,0 +1,55 @@
+package config
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ProfileFiles returns the overlay files that accompany a base config file,
+// ordered from the lowest to the highest priority. For the base file
+// ./config/config.yaml and the production env, they are
+// ./config/config.production.yaml and ./config/config.local.yaml. The local
+// overlay is meant for developer specific overrides and should be ignored by
+// version control. If the env is unknown, only the local overlay is returned.
+// The files are derived from env once. Callers that resolve env from the
+// config itself must call ProfileFiles again to follow a change of env.
+func ProfileFiles(basePath string, env Env) []string {
+	var files []string
+	if env != EnvUnknown && env != EnvLocal {
+		files = append(files, ProfileFile(basePath, env.String()))
+	}
+	return append(files, ProfileFile(basePath, EnvLocal.String()))
+}
+
+// ProfileFile returns the overlay file of a base config file for the given
+// profile name. For example, the profile file of ./config/config.yaml for
+// production is ./config/config.production.yaml.
+func ProfileFile(basePath string, profile string) string {
+	ext := filepath.Ext(basePath)
+	return strings.TrimSuffix(basePath, ext) + "." + profile + ext
+}
+
+// OptionalFile is a koanf.Provider that reads a file like file.Provider, but
+// treats a missing file as an empty one. It is useful for overlays that may or
+// may not exist.
+type OptionalFile struct {
+	Path string
+}
+
+// ReadBytes reads the contents of the file. If the file doesn't exist, nil is
+// returned.
+func (o OptionalFile) ReadBytes() ([]byte, error) {
+	bytes, err := ioutil.ReadFile(o.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return bytes, err
+}
+
+// Read is not supported by OptionalFile.
+func (o OptionalFile) Read() (map[string]interface{}, error) {
+	return nil, errors.New("optional file provider does not support this method")
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DoNewsCode/core/codec/yaml"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProfileFiles(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t,
+		[]string{"./config/config.production.yaml", "./config/config.local.yaml"},
+		ProfileFiles("./config/config.yaml", EnvProduction),
+	)
+	assert.Equal(t, []string{"config.local.yaml"}, ProfileFiles("config.yaml", EnvLocal))
+	assert.Equal(t, []string{"config.local.yaml"}, ProfileFiles("config.yaml", EnvUnknown))
+	assert.Equal(t, "app.testing.json", ProfileFile("app.json", "testing"))
+}
+
+func TestOptionalFile(t *testing.T) {
+	t.Parallel()
+	bytes, err := OptionalFile{Path: "./testdata/not-exist.yaml"}.ReadBytes()
+	assert.NoError(t, err)
+	assert.Empty(t, bytes)
+
+	bytes, err = OptionalFile{Path: "./testdata/mock.yaml"}.ReadBytes()
+	assert.NoError(t, err)
+	assert.NotEmpty(t, bytes)
+
+	_, err = OptionalFile{Path: "./testdata/mock.yaml"}.Read()
+	assert.Error(t, err)
+
+	conf, err := NewConfig(WithProviderLayer(OptionalFile{Path: "./testdata/not-exist.yaml"}, CodecParser{yaml.Codec{}}))
+	assert.NoError(t, err)
+	assert.Empty(t, conf.String("foo"))
+}
+
+func TestModule_ProvideCommand_initProfile(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "profile")
+	defer os.RemoveAll(dir)
+	target := filepath.Join(dir, "config.yaml")
+
+	rootCmd := setup()
+	rootCmd.SetArgs([]string{"config", "init", "--env", "prod", "--targetFile", target})
+	assert.NoError(t, rootCmd.Execute())
+
+	bytes, err := ioutil.ReadFile(filepath.Join(dir, "config.production.yaml"))
+	assert.NoError(t, err)
+	assert.True(t, strings.Contains(string(bytes), "## A mock config\n# foo: bar\n"))
+
+	// The overlay must be a valid yaml with no effective entries.
+	var m map[string]interface{}
+	assert.NoError(t, yaml.Codec{}.Unmarshal(bytes, &m))
+	assert.Empty(t, m)
+
+	// Do not overwrite existing overlays.
+	rootCmd = setup()
+	rootCmd.SetArgs([]string{"config", "init", "--env", "production", "--targetFile", target})
+	assert.Error(t, rootCmd.Execute())
+
+	rootCmd = setup()
+	rootCmd.SetArgs([]string{"config", "init", "--env", "foo", "--targetFile", target})
+	assert.Error(t, rootCmd.Execute())
+}
@@ -0,0 +1,158 @@
+package config
+
+import (
+	"context"
+	"time"
+
+	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/events"
+)
+
+// ReloadPolicy controls how the ReloadCoordinator reacts to change
+// notifications and reload failures.
+type ReloadPolicy struct {
+	// Debounce delays the reload until no notification has been received for
+	// this long, so that a burst of changes only triggers one reload.
+	Debounce time.Duration
+	// InitialBackoff is the delay before the first retry of a failed reload.
+	// The delay doubles after every failed retry.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between retries.
+	MaxBackoff time.Duration
+	// MaxRetries is the number of retries after a failed reload. Once exhausted,
+	// the coordinator waits for the next notification. Negative means no retry.
+	MaxRetries int
+}
+
+// DefaultReloadPolicy is the ReloadPolicy used by *KoanfAdapter unless
+// WithReloadPolicy says otherwise.
+var DefaultReloadPolicy = ReloadPolicy{
+	Debounce:       100 * time.Millisecond,
+	InitialBackoff: time.Second,
+	MaxBackoff:     30 * time.Second,
+	MaxRetries:     5,
+}
+
+// ReloadCoordinator is a contract.ConfigWatcher that sits between a watcher and
+// the reload function. It debounces bursts of notifications, retries failed
+// reloads with exponential backoff and dispatches events.OnReloadFailed for
+// each failure. Unlike the bare watcher, it never stops watching because of a
+// failed reload: the last good configuration simply stays in effect.
+type ReloadCoordinator struct {
+	watcher    contract.ConfigWatcher
+	dispatcher contract.Dispatcher
+	policy     ReloadPolicy
+}
+
+// NewReloadCoordinator creates a *ReloadCoordinator. The dispatcher is optional.
+func NewReloadCoordinator(watcher contract.ConfigWatcher, dispatcher contract.Dispatcher, policy ReloadPolicy) *ReloadCoordinator {
+	return &ReloadCoordinator{
+		watcher:    watcher,
+		dispatcher: dispatcher,
+		policy:     policy,
+	}
+}
+
+// Watch implements contract.ConfigWatcher. It returns when the context is
+// canceled or the underlying watcher returns. In the latter case, a pending
+// reload is carried out before returning.
+func (r *ReloadCoordinator) Watch(ctx context.Context, reload func() error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		notify  = make(chan struct{}, 1)
+		watchCh = make(chan error, 1)
+	)
+
+	go func() {
+		watchCh <- r.watcher.Watch(ctx, func() error {
+			select {
+			case notify <- struct{}{}:
+			default:
+			}
+			return nil
+		})
+	}()
+
+	var (
+		timer   *time.Timer
+		fire    <-chan time.Time
+		attempt int
+		backoff time.Duration
+	)
+	schedule := func(d time.Duration) {
+		if timer != nil {
+			timer.Stop()
+		}
+		timer = time.NewTimer(d)
+		fire = timer.C
+	}
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	for {
+		select {
+		case <-notify:
+			attempt = 0
+			backoff = r.policy.InitialBackoff
+			schedule(r.policy.Debounce)
+		case <-fire:
+			fire = nil
+			err := reload()
+			if err == nil {
+				attempt = 0
+				continue
+			}
+			attempt++
+			willRetry := attempt <= r.policy.MaxRetries
+			r.dispatchFailure(ctx, err, attempt, willRetry)
+			if !willRetry {
+				continue
+			}
+			schedule(backoff)
+			backoff *= 2
+			if r.policy.MaxBackoff > 0 && backoff > r.policy.MaxBackoff {
+				backoff = r.policy.MaxBackoff
+			}
+		case err := <-watchCh:
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err != nil {
+				return err
+			}
+			// The watcher has finished. Flush the pending reload, if any.
+			pending := fire != nil
+			select {
+			case <-notify:
+				pending = true
+			default:
+			}
+			if !pending {
+				return nil
+			}
+			if err := reload(); err != nil {
+				r.dispatchFailure(ctx, err, attempt+1, false)
+				return err
+			}
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (r *ReloadCoordinator) dispatchFailure(ctx context.Context, err error, attempt int, willRetry bool) {
+	if r.dispatcher == nil {
+		return
+	}
+	_ = r.dispatcher.Dispatch(ctx, events.OnReloadFailed, events.OnReloadFailedPayload{
+		Err:       err,
+		Attempt:   attempt,
+		WillRetry: willRetry,
+	})
+}
@@ -0,0 +1,116 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core/events"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/atomic"
+)
+
+type mockWatcher struct {
+	notify chan struct{}
+}
+
+func (m mockWatcher) Watch(ctx context.Context, reload func() error) error {
+	for {
+		select {
+		case <-m.notify:
+			if err := reload(); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func TestReloadCoordinator_debounce(t *testing.T) {
+	t.Parallel()
+	var count atomic.Int32
+	w := mockWatcher{notify: make(chan struct{})}
+	c := NewReloadCoordinator(w, nil, ReloadPolicy{Debounce: 100 * time.Millisecond})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Watch(ctx, func() error {
+		count.Inc()
+		return nil
+	})
+
+	for i := 0; i < 5; i++ {
+		w.notify <- struct{}{}
+	}
+	time.Sleep(300 * time.Millisecond)
+	assert.Equal(t, int32(1), count.Load())
+
+	w.notify <- struct{}{}
+	time.Sleep(300 * time.Millisecond)
+	assert.Equal(t, int32(2), count.Load())
+}
+
+func TestReloadCoordinator_retry(t *testing.T) {
+	t.Parallel()
+	var (
+		count    atomic.Int32
+		mu       sync.Mutex
+		payloads []events.OnReloadFailedPayload
+	)
+	dispatcher := &events.SyncDispatcher{}
+	dispatcher.Subscribe(events.Listen(events.OnReloadFailed, func(ctx context.Context, event interface{}) error {
+		mu.Lock()
+		defer mu.Unlock()
+		payloads = append(payloads, event.(events.OnReloadFailedPayload))
+		return nil
+	}))
+
+	w := mockWatcher{notify: make(chan struct{})}
+	c := NewReloadCoordinator(w, dispatcher, ReloadPolicy{
+		InitialBackoff: 10 * time.Millisecond,
+		MaxBackoff:     20 * time.Millisecond,
+		MaxRetries:     2,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Watch(ctx, func() error {
+		count.Inc()
+		return errors.New("bad config")
+	})
+
+	w.notify <- struct{}{}
+	time.Sleep(200 * time.Millisecond)
+	assert.Equal(t, int32(3), count.Load())
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Len(t, payloads, 3)
+	assert.True(t, payloads[0].WillRetry)
+	assert.Equal(t, 3, payloads[2].Attempt)
+	assert.False(t, payloads[2].WillRetry)
+}
+
+func TestReloadCoordinator_cancel(t *testing.T) {
+	t.Parallel()
+	w := mockWatcher{notify: make(chan struct{})}
+	c := NewReloadCoordinator(w, nil, DefaultReloadPolicy)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Error(t, c.Watch(ctx, func() error { return nil }))
+}
+
+type failingWatcher struct{}
+
+func (f failingWatcher) Watch(ctx context.Context, reload func() error) error {
+	return errors.New("watcher failed")
+}
+
+func TestReloadCoordinator_watcherError(t *testing.T) {
+	t.Parallel()
+	c := NewReloadCoordinator(failingWatcher{}, nil, DefaultReloadPolicy)
+	assert.EqualError(t, c.Watch(context.Background(), func() error { return nil }), "watcher failed")
+}
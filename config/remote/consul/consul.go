@@ -0,0 +1,162 @@
+// Package consul allows the core package to bootstrap its configuration from
+// the consul KV store. It talks to the consul HTTP API directly to avoid
+// pulling in the full consul client.
+package consul
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DoNewsCode/core"
+	"github.com/DoNewsCode/core/config"
+	"github.com/DoNewsCode/core/contract"
+)
+
+// Config is the configuration to access consul.
+type Config struct {
+	// Address is the address of the consul agent, such as http://127.0.0.1:8500.
+	// Defaults to http://127.0.0.1:8500.
+	Address string
+	// Token is the ACL token sent via X-Consul-Token. Optional.
+	Token string
+	// Datacenter to query. Defaults to the datacenter of the agent.
+	Datacenter string
+	// WaitTime is the maximum duration of each blocking query. Defaults to 5 minutes.
+	WaitTime time.Duration
+	// Client is the http client used to talk to consul. Defaults to http.DefaultClient.
+	Client contract.HttpDoer
+}
+
+// Consul is a core.ConfProvider and contract.ConfigWatcher implementation to
+// read and watch remote config key. Changes are detected by consul blocking
+// queries.
+type Consul struct {
+	key    string
+	config Config
+}
+
+// Provider creates a *Consul.
+func Provider(cfg Config, key string) *Consul {
+	if cfg.Address == "" {
+		cfg.Address = "http://127.0.0.1:8500"
+	}
+	if !strings.Contains(cfg.Address, "://") {
+		cfg.Address = "http://" + cfg.Address
+	}
+	if cfg.WaitTime == 0 {
+		cfg.WaitTime = 5 * time.Minute
+	}
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	return &Consul{
+		key:    strings.TrimPrefix(key, "/"),
+		config: cfg,
+	}
+}
+
+// WithKey is a two-in-one coreOption. It uses the remote key on consul as the
+// source of configuration, and watches the change of that key for hot reloading.
+func WithKey(cfg Config, key string, codec contract.Codec) (core.CoreOption, core.CoreOption) {
+	r := Provider(cfg, key)
+	return core.WithConfigStack(r, config.CodecParser{Codec: codec}), core.WithConfigWatcher(r)
+}
+
+// ReadBytes reads the contents of a key from consul and returns the bytes.
+func (r *Consul) ReadBytes() ([]byte, error) {
+	bytes, _, err := r.get(context.Background(), 0)
+	return bytes, err
+}
+
+// Read is not supported by the remote provider.
+func (r *Consul) Read() (map[string]interface{}, error) {
+	return nil, errors.New("remote provider does not support this method")
+}
+
+// Watch watches the change to the remote key from consul. If the key is edited or created, the reload function
+// will be called. note the reload function should not just load the changes made within this key, but rather
+// it should reload the whole config stack. For example, if the flag or env takes precedence over the config
+// key, they should remain to be so after the key changes.
+func (r *Consul) Watch(ctx context.Context, reload func() error) error {
+	_, index, err := r.get(ctx, 0)
+	if err != nil {
+		return err
+	}
+	for {
+		_, newIndex, err := r.get(ctx, index)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			return err
+		}
+		// The index may go backwards, for example after a snapshot restore.
+		// Consul recommends resetting the index in this case.
+		if newIndex < index {
+			index = 0
+			continue
+		}
+		if newIndex == index {
+			continue
+		}
+		index = newIndex
+		// Trigger event.
+		if err := reload(); err != nil {
+			return err
+		}
+	}
+}
+
+// get fetches the raw value of the key. If index is not zero, a blocking query
+// is issued, which returns when the key is modified or the wait time elapses.
+func (r *Consul) get(ctx context.Context, index uint64) ([]byte, uint64, error) {
+	query := url.Values{}
+	query.Set("raw", "")
+	if r.config.Datacenter != "" {
+		query.Set("dc", r.config.Datacenter)
+	}
+	if index > 0 {
+		query.Set("index", strconv.FormatUint(index, 10))
+		query.Set("wait", fmt.Sprintf("%dms", r.config.WaitTime.Milliseconds()))
+	}
+	u := fmt.Sprintf("%s/v1/kv/%s?%s", strings.TrimSuffix(r.config.Address, "/"), r.key, query.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if r.config.Token != "" {
+		req.Header.Set("X-Consul-Token", r.config.Token)
+	}
+	resp, err := r.config.Client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	bytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+	newIndex, _ := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return bytes, newIndex, nil
+	case http.StatusNotFound:
+		if index > 0 {
+			// The key is deleted while watching. Keep watching for the recreation.
+			return nil, newIndex, nil
+		}
+		return nil, newIndex, fmt.Errorf("no such config key: %s", r.key)
+	default:
+		return nil, 0, fmt.Errorf("unexpected status code from consul: %d, body: %s", resp.StatusCode, bytes)
+	}
+}
@@ -0,0 +1,107 @@
+package consul
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeConsul struct {
+	mu      sync.Mutex
+	value   string
+	index   uint64
+	changed chan struct{}
+}
+
+func (f *fakeConsul) put(value string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.value = value
+	f.index++
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+func (f *fakeConsul) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/v1/kv/config.yaml" || r.Header.Get("X-Consul-Token") != "token" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	f.mu.Lock()
+	index, changed := f.index, f.changed
+	f.mu.Unlock()
+
+	if wait, _ := strconv.ParseUint(r.URL.Query().Get("index"), 10, 64); wait == index {
+		select {
+		case <-changed:
+		case <-time.After(100 * time.Millisecond):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w.Header().Set("X-Consul-Index", strconv.FormatUint(f.index, 10))
+	w.Write([]byte(f.value))
+}
+
+func TestConsul(t *testing.T) {
+	fake := &fakeConsul{value: "name: app", index: 1, changed: make(chan struct{})}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	r := Provider(Config{Address: server.URL, Token: "token"}, "/config.yaml")
+
+	_, err := r.Read()
+	assert.Error(t, err)
+
+	bytes, err := r.ReadBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, "name: app", string(bytes))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var ch = make(chan string)
+	go r.Watch(ctx, func() error {
+		bytes, err := r.ReadBytes()
+		if err != nil {
+			return err
+		}
+		ch <- string(bytes)
+		return nil
+	})
+
+	time.Sleep(200 * time.Millisecond)
+	fake.put("name: foo")
+	assert.Equal(t, "name: foo", <-ch)
+
+	fake.put("name: bar")
+	assert.Equal(t, "name: bar", <-ch)
+}
+
+func TestConsul_error(t *testing.T) {
+	fake := &fakeConsul{value: "name: app", index: 1, changed: make(chan struct{})}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	r := Provider(Config{Address: server.URL, Token: "token"}, "not-exist")
+	_, err := r.ReadBytes()
+	assert.Error(t, err)
+
+	err = r.Watch(context.Background(), func() error { return nil })
+	assert.Error(t, err)
+
+	r = Provider(Config{Address: server.URL, Token: "token"}, "config.yaml")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = r.Watch(ctx, func() error { return nil })
+	assert.Error(t, err)
+}
@@ -5,25 +5,122 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
+	"sync"
+	"time"
+
 	"github.com/DoNewsCode/core"
 	"github.com/DoNewsCode/core/config"
 	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/otetcd"
+	"github.com/knadh/koanf/maps"
 	"go.etcd.io/etcd/client/v3"
 )
 
 // ETCD is a core.ConfProvider and contract.ConfigWatcher implementation to read and watch remote config key.
 // The remote client uses etcd.
+//
+// By default, ETCD reads exactly one key holding the whole document. With
+// WithPrefix, it reads every key under the prefix instead. See WithPrefix for
+// how the keys are combined.
 type ETCD struct {
 	key          string
 	clientConfig clientv3.Config
+	maker        otetcd.Maker
+	makerName    string
+	prefix       bool
+	codec        contract.Codec
+	debounce     time.Duration
+
+	mu     sync.Mutex
+	client *clientv3.Client
+}
+
+// Option is the functional option type for *ETCD.
+type Option func(r *ETCD)
+
+// WithPrefix treats the key as a prefix and reads every key under it. Without
+// a codec, keys form a tree: the key path relative to the prefix, split by
+// "/", becomes the config path. For example, under the prefix /app/prod, the
+// key /app/prod/http/addr becomes http.addr. With WithCodec, every key under
+// the prefix holds a document, and the documents are deep-merged in key order,
+// so later keys take precedence.
+//
+// The prefix should normally end with "/", otherwise /app/prod also matches
+// /app/production. Prefix mode only supports Read. Use it with a nil parser in
+// the config stack.
+func WithPrefix() Option {
+	return func(r *ETCD) {
+		r.prefix = true
+	}
 }
 
-// Provider create a *ETCD
-func Provider(clientConfig clientv3.Config, key string) *ETCD {
-	return &ETCD{
+// WithCodec sets the codec used by Read to decode the documents.
+func WithCodec(codec contract.Codec) Option {
+	return func(r *ETCD) {
+		r.codec = codec
+	}
+}
+
+// WithDebounce delays the reload until no change has been observed for the
+// given duration, so that a batch of writes only triggers one reload. It
+// defaults to zero. The config watched by the core is already debounced by a
+// config.ReloadCoordinator, see config.ReloadPolicy, and the two delays add
+// up, so it is only needed when the provider is watched without one.
+func WithDebounce(duration time.Duration) Option {
+	return func(r *ETCD) {
+		r.debounce = duration
+	}
+}
+
+// Provider create a *ETCD. The etcd client is dialed on first use and reused
+// afterwards. Call Close to release it. A *ETCD added to the core with
+// core.WithConfigStack or core.WithConfigWatcher, as WithKey and WithKeyPrefix
+// do, is closed when the core shuts down.
+func Provider(clientConfig clientv3.Config, key string, opts ...Option) *ETCD {
+	r := &ETCD{
 		key:          key,
 		clientConfig: clientConfig,
 	}
+	for _, f := range opts {
+		f(r)
+	}
+	return r
+}
+
+// ProviderWithMaker creates a *ETCD that borrows the client named by name from
+// otetcd.Maker instead of dialing its own. The client is obtained from the
+// maker on every use, so it survives the maker recreating its connections.
+//
+// The maker cannot come from the core being configured, because the config
+// stack is read before any dependency is available. Instead, take it from a
+// bootstrap core holding the etcd configuration, which must outlive the
+// application:
+//
+//  boot := core.New(core.WithYamlFile("bootstrap.yaml"))
+//  boot.ProvideEssentials()
+//  boot.Provide(otetcd.Providers())
+//  defer boot.Shutdown()
+//
+//  boot.Invoke(func(maker otetcd.Maker) {
+//    r := etcd.ProviderWithMaker(maker, "default", "config.yaml")
+//    c := core.New(
+//      core.WithConfigStack(r, config.CodecParser{Codec: yaml.Codec{}}),
+//      core.WithConfigWatcher(r),
+//    )
+//    defer c.Shutdown()
+//    // ...
+//  })
+func ProviderWithMaker(maker otetcd.Maker, name string, key string, opts ...Option) *ETCD {
+	r := &ETCD{
+		key:       key,
+		maker:     maker,
+		makerName: name,
+	}
+	for _, f := range opts {
+		f(r)
+	}
+	return r
 }
 
 // WithKey is a two-in-one coreOption. It uses the remote key on etcd as the
@@ -33,13 +130,24 @@ func WithKey(cfg clientv3.Config, key string, codec contract.Codec) (core.CoreOp
 	return core.WithConfigStack(r, config.CodecParser{Codec: codec}), core.WithConfigWatcher(r)
 }
 
-// ReadBytes reads the contents of a key from etcd and returns the bytes.
+// WithKeyPrefix is a two-in-one coreOption. It uses all keys under the prefix
+// on etcd as the source of configuration, and watches the change of any key
+// under the prefix for hot reloading. See WithPrefix for details.
+func WithKeyPrefix(cfg clientv3.Config, prefix string, opts ...Option) (core.CoreOption, core.CoreOption) {
+	r := Provider(cfg, prefix, append([]Option{WithPrefix()}, opts...)...)
+	return core.WithConfigStack(r, nil), core.WithConfigWatcher(r)
+}
+
+// ReadBytes reads the contents of a key from etcd and returns the bytes. It is
+// not supported in prefix mode.
 func (r *ETCD) ReadBytes() ([]byte, error) {
-	client, err := clientv3.New(r.clientConfig)
+	if r.prefix {
+		return nil, errors.New("prefix mode does not support this method, use Read instead")
+	}
+	client, err := r.getClient()
 	if err != nil {
 		return nil, err
 	}
-	defer client.Close()
 
 	resp, err := client.Get(context.Background(), r.key)
 	if err != nil {
@@ -52,9 +160,51 @@ func (r *ETCD) ReadBytes() ([]byte, error) {
 	return resp.Kvs[0].Value, nil
 }
 
-// Read is not supported by the remote provider.
+// Read reads the config from etcd and returns a nested map. In single key
+// mode, it requires a codec set by WithCodec.
 func (r *ETCD) Read() (map[string]interface{}, error) {
-	return nil, errors.New("remote provider does not support this method")
+	if !r.prefix {
+		if r.codec == nil {
+			return nil, errors.New("remote provider does not support this method without a codec")
+		}
+		bytes, err := r.ReadBytes()
+		if err != nil {
+			return nil, err
+		}
+		return r.decode(bytes)
+	}
+
+	client, err := r.getClient()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Get(
+		context.Background(),
+		r.key,
+		clientv3.WithPrefix(),
+		clientv3.WithSort(clientv3.SortByKey, clientv3.SortAscend),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var out = make(map[string]interface{})
+	for _, kv := range resp.Kvs {
+		if r.codec != nil {
+			doc, err := r.decode(kv.Value)
+			if err != nil {
+				return nil, fmt.Errorf("unable to decode key %s: %w", kv.Key, err)
+			}
+			maps.Merge(doc, out)
+			continue
+		}
+		path := splitPath(strings.TrimPrefix(string(kv.Key), r.key))
+		if len(path) == 0 {
+			continue
+		}
+		setPath(out, path, string(kv.Value))
+	}
+	return out, nil
 }
 
 // Watch watches the change to the remote key from etcd. If the key is edited or created, the reload function
@@ -62,19 +212,46 @@ func (r *ETCD) Read() (map[string]interface{}, error) {
 // it should reload the whole config stack. For example, if the flag or env takes precedence over the config
 // key, they should remain to be so after the key changes.
 func (r *ETCD) Watch(ctx context.Context, reload func() error) error {
-	client, err := clientv3.New(r.clientConfig)
+	client, err := r.getClient()
 	if err != nil {
 		return err
 	}
-	defer client.Close()
 
-	rch := client.Watch(ctx, r.key)
+	var opts []clientv3.OpOption
+	if r.prefix {
+		opts = append(opts, clientv3.WithPrefix())
+	}
+
+	return r.watch(ctx, client.Watch(ctx, r.key, opts...), reload)
+}
+
+// watch calls reload on the changes received from rch, until rch or ctx is
+// done.
+func (r *ETCD) watch(ctx context.Context, rch clientv3.WatchChan, reload func() error) error {
+	var debounced <-chan time.Time
 	for {
 		select {
-		case resp := <-rch:
+		case resp, ok := <-rch:
+			if !ok {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				// The channel also closes when the client is closed.
+				return errors.New("etcd watch channel closed")
+			}
 			if resp.Err() != nil {
 				return resp.Err()
 			}
+			if r.debounce > 0 {
+				debounced = time.After(r.debounce)
+				continue
+			}
+			// Trigger event.
+			if err := reload(); err != nil {
+				return err
+			}
+		case <-debounced:
+			debounced = nil
 			// Trigger event.
 			if err := reload(); err != nil {
 				return err
@@ -84,3 +261,68 @@ func (r *ETCD) Watch(ctx context.Context, reload func() error) error {
 		}
 	}
 }
+
+// Close closes the etcd client dialed by the provider. Clients borrowed from
+// otetcd.Maker are left to the maker.
+func (r *ETCD) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.client == nil {
+		return nil
+	}
+	err := r.client.Close()
+	r.client = nil
+	return err
+}
+
+func (r *ETCD) getClient() (*clientv3.Client, error) {
+	if r.maker != nil {
+		return r.maker.Make(r.makerName)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.client != nil {
+		return r.client, nil
+	}
+	client, err := clientv3.New(r.clientConfig)
+	if err != nil {
+		return nil, err
+	}
+	r.client = client
+	return client, nil
+}
+
+func (r *ETCD) decode(bytes []byte) (map[string]interface{}, error) {
+	var m = make(map[string]interface{})
+	if err := r.codec.Unmarshal(bytes, &m); err != nil {
+		return nil, err
+	}
+	maps.IntfaceKeysToStrings(m)
+	return m, nil
+}
+
+func splitPath(key string) []string {
+	var path []string
+	for _, segment := range strings.Split(key, "/") {
+		if segment != "" {
+			path = append(path, segment)
+		}
+	}
+	return path
+}
+
+// setPath sets the value at the path, replacing any non-map value on the way.
+func setPath(m map[string]interface{}, path []string, value interface{}) {
+	for _, segment := range path[:len(path)-1] {
+		next, ok := m[segment].(map[string]interface{})
+		if !ok {
+			next = make(map[string]interface{})
+			m[segment] = next
+		}
+		m = next
+	}
+	m[path[len(path)-1]] = value
+}
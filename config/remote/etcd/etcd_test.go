@@ -9,6 +9,10 @@ import (
 	"testing"
 	"time"
 
+	"github.com/DoNewsCode/core"
+	"github.com/DoNewsCode/core/codec/yaml"
+	"github.com/DoNewsCode/core/config"
+	"github.com/DoNewsCode/core/otetcd"
 	"github.com/stretchr/testify/assert"
 	"go.etcd.io/etcd/client/v3"
 )
@@ -142,3 +146,153 @@ func put(r *ETCD, val string) error {
 	}
 	return nil
 }
+
+func TestProviderWithMaker(t *testing.T) {
+	if os.Getenv("ETCD_ADDR") == "" {
+		t.Skip("set ETCD_ADDR to run TestProviderWithMaker")
+		return
+	}
+	addrs := strings.Split(os.Getenv("ETCD_ADDR"), ",")
+	cfg := clientv3.Config{
+		Endpoints:   addrs,
+		DialTimeout: 2 * time.Second,
+	}
+	r := Provider(cfg, "config.yaml")
+	defer r.Close()
+	assert.NoError(t, put(r, "name: maker"))
+
+	// The maker comes from a bootstrap core, before the application core is built.
+	boot := core.New(core.WithInline("etcd.default.endpoints", addrs))
+	boot.ProvideEssentials()
+	boot.Provide(otetcd.Providers())
+	defer boot.Shutdown()
+
+	boot.Invoke(func(maker otetcd.Maker) {
+		r := ProviderWithMaker(maker, "default", "config.yaml")
+		c := core.New(core.WithConfigStack(r, config.CodecParser{Codec: yaml.Codec{}}), core.WithConfigWatcher(r))
+		assert.Equal(t, "maker", c.String("name"))
+
+		// Shutting down the application core must not close the borrowed client.
+		c.Shutdown()
+		client, err := maker.Make("default")
+		assert.NoError(t, err)
+		_, err = client.Get(context.Background(), "config.yaml")
+		assert.NoError(t, err)
+	})
+}
+
+func TestWatch_closed(t *testing.T) {
+	t.Parallel()
+	var reloaded int
+	rch := make(chan clientv3.WatchResponse, 1)
+	rch <- clientv3.WatchResponse{}
+	close(rch)
+
+	r := Provider(clientv3.Config{}, "config.yaml")
+	err := r.watch(context.Background(), rch, func() error {
+		reloaded++
+		return nil
+	})
+	assert.EqualError(t, err, "etcd watch channel closed")
+	assert.Equal(t, 1, reloaded)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = r.watch(ctx, make(chan clientv3.WatchResponse), func() error { return nil })
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestWatch_prefix(t *testing.T) {
+	t.Parallel()
+	var reloaded int
+	rch := make(chan clientv3.WatchResponse, 2)
+	rch <- clientv3.WatchResponse{}
+	rch <- clientv3.WatchResponse{}
+	close(rch)
+
+	// The reloads are not delayed by default, as the core debounces them.
+	r := Provider(clientv3.Config{}, "/app/", WithPrefix())
+	err := r.watch(context.Background(), rch, func() error {
+		reloaded++
+		return nil
+	})
+	assert.EqualError(t, err, "etcd watch channel closed")
+	assert.Equal(t, 2, reloaded)
+}
+
+func TestSetPath(t *testing.T) {
+	t.Parallel()
+	out := make(map[string]interface{})
+	setPath(out, splitPath("/http/addr"), ":8080")
+	setPath(out, splitPath("http//disable"), "false")
+	setPath(out, splitPath("grpc"), "foo")
+	setPath(out, splitPath("grpc/addr/"), ":9090")
+	assert.Equal(t, map[string]interface{}{
+		"http": map[string]interface{}{"addr": ":8080", "disable": "false"},
+		"grpc": map[string]interface{}{"addr": ":9090"},
+	}, out)
+	assert.Empty(t, splitPath("/"))
+}
+
+func TestPrefix(t *testing.T) {
+	if os.Getenv("ETCD_ADDR") == "" {
+		t.Skip("set ETCD_ADDR to run TestPrefix")
+		return
+	}
+	cfg := clientv3.Config{
+		Endpoints:   strings.Split(os.Getenv("ETCD_ADDR"), ","),
+		DialTimeout: 2 * time.Second,
+	}
+	client, err := clientv3.New(cfg)
+	assert.NoError(t, err)
+	defer client.Close()
+	ctx := context.Background()
+	client.Delete(ctx, "/test-prefix/", clientv3.WithPrefix())
+
+	client.Put(ctx, "/test-prefix/tree/http/addr", ":8080")
+	client.Put(ctx, "/test-prefix/tree/name", "app")
+	client.Put(ctx, "/test-prefix/docs/00-base.yaml", "http:\n  addr: :8080\n  disable: false\nname: app")
+	client.Put(ctx, "/test-prefix/docs/10-prod.yaml", "http:\n  addr: :80")
+
+	t.Run("tree", func(t *testing.T) {
+		r := Provider(cfg, "/test-prefix/tree/", WithPrefix())
+		defer r.Close()
+		_, err := r.ReadBytes()
+		assert.Error(t, err)
+		m, err := r.Read()
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{
+			"http": map[string]interface{}{"addr": ":8080"},
+			"name": "app",
+		}, m)
+	})
+
+	t.Run("documents", func(t *testing.T) {
+		r := Provider(cfg, "/test-prefix/docs/", WithPrefix(), WithCodec(yaml.Codec{}))
+		defer r.Close()
+		m, err := r.Read()
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{
+			"http": map[string]interface{}{"addr": ":80", "disable": false},
+			"name": "app",
+		}, m)
+	})
+
+	t.Run("watch", func(t *testing.T) {
+		r := Provider(cfg, "/test-prefix/tree/", WithPrefix(), WithDebounce(500*time.Millisecond))
+		defer r.Close()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		var reloaded = make(chan struct{}, 10)
+		go r.Watch(ctx, func() error {
+			reloaded <- struct{}{}
+			return nil
+		})
+		time.Sleep(time.Second)
+		client.Put(ctx, "/test-prefix/tree/http/addr", ":8081")
+		client.Put(ctx, "/test-prefix/tree/http/disable", "true")
+		<-reloaded
+		time.Sleep(time.Second)
+		assert.Len(t, reloaded, 0)
+	})
+}
@@ -0,0 +1,148 @@
+// Package redis allows the core package to bootstrap its configuration from a redis server.
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/DoNewsCode/core"
+	"github.com/DoNewsCode/core/config"
+	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/otredis"
+	"github.com/go-redis/redis/v8"
+)
+
+// Redis is a core.ConfProvider and contract.ConfigWatcher implementation to
+// read and watch remote config key. The remote client uses redis.
+//
+// By default, changes are detected through keyspace notifications, which
+// requires the server to have notify-keyspace-events configured (at least
+// "K$g"). Alternatively, use WithChannel to listen on a pub/sub channel where
+// the publisher announces the changes.
+type Redis struct {
+	key       string
+	channel   string
+	client    redis.UniversalClient
+	maker     otredis.Maker
+	makerName string
+}
+
+// Option is the functional option type for *Redis.
+type Option func(r *Redis)
+
+// WithChannel makes the provider watch a pub/sub channel instead of keyspace
+// notifications. Any message published to the channel triggers a reload.
+func WithChannel(channel string) Option {
+	return func(r *Redis) {
+		r.channel = channel
+	}
+}
+
+// Provider creates a *Redis from a redis client.
+func Provider(client redis.UniversalClient, key string, opts ...Option) *Redis {
+	r := &Redis{
+		key:    key,
+		client: client,
+	}
+	for _, f := range opts {
+		f(r)
+	}
+	return r
+}
+
+// ProviderWithMaker creates a *Redis that borrows the client named by name
+// from otredis.Maker. The client is obtained from the maker on every use, so it
+// survives the maker recreating its connections.
+//
+// The maker cannot come from the core being configured, because the config
+// stack is read before any dependency is available. Take it from a bootstrap
+// core that provides otredis.Providers and outlives the application, then
+// build the application core inside its Invoke.
+func ProviderWithMaker(maker otredis.Maker, name string, key string, opts ...Option) *Redis {
+	r := &Redis{
+		key:       key,
+		maker:     maker,
+		makerName: name,
+	}
+	for _, f := range opts {
+		f(r)
+	}
+	return r
+}
+
+// WithKey is a two-in-one coreOption. It uses the remote key on redis as the
+// source of configuration, and watches the change of that key for hot reloading.
+func WithKey(client redis.UniversalClient, key string, codec contract.Codec, opts ...Option) (core.CoreOption, core.CoreOption) {
+	r := Provider(client, key, opts...)
+	return core.WithConfigStack(r, config.CodecParser{Codec: codec}), core.WithConfigWatcher(r)
+}
+
+// ReadBytes reads the contents of a key from redis and returns the bytes.
+func (r *Redis) ReadBytes() ([]byte, error) {
+	client, err := r.getClient()
+	if err != nil {
+		return nil, err
+	}
+
+	bytes, err := client.Get(context.Background(), r.key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("no such config key: %s", r.key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return bytes, nil
+}
+
+// Read is not supported by the remote provider.
+func (r *Redis) Read() (map[string]interface{}, error) {
+	return nil, errors.New("remote provider does not support this method")
+}
+
+// Watch watches the change to the remote key from redis. If the key is edited or created, the reload function
+// will be called. note the reload function should not just load the changes made within this key, but rather
+// it should reload the whole config stack. For example, if the flag or env takes precedence over the config
+// key, they should remain to be so after the key changes.
+func (r *Redis) Watch(ctx context.Context, reload func() error) error {
+	client, err := r.getClient()
+	if err != nil {
+		return err
+	}
+
+	var pubSub *redis.PubSub
+	if r.channel != "" {
+		pubSub = client.Subscribe(ctx, r.channel)
+	} else {
+		pubSub = client.PSubscribe(ctx, fmt.Sprintf("__keyspace@*__:%s", r.key))
+	}
+	defer pubSub.Close()
+
+	// Wait for the subscription to be confirmed.
+	if _, err := pubSub.Receive(ctx); err != nil {
+		return err
+	}
+
+	ch := pubSub.Channel()
+	for {
+		select {
+		case _, ok := <-ch:
+			if !ok {
+				return errors.New("redis subscription closed")
+			}
+			// Trigger event.
+			if err := reload(); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (r *Redis) getClient() (redis.UniversalClient, error) {
+	if r.maker != nil {
+		return r.maker.Make(r.makerName)
+	}
+	return r.client, nil
+}
@@ -0,0 +1,114 @@
+package redis
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemote(t *testing.T) {
+	if os.Getenv("REDIS_ADDR") == "" {
+		t.Skip("set REDIS_ADDR to run TestRemote")
+		return
+	}
+	client := redis.NewUniversalClient(&redis.UniversalOptions{
+		Addrs: strings.Split(os.Getenv("REDIS_ADDR"), ","),
+	})
+	defer client.Close()
+
+	const testVal = "name: app"
+	assert.NoError(t, client.Set(context.Background(), "config.yaml", testVal, 0).Err())
+
+	r := Provider(client, "config.yaml", WithChannel("config.yaml.changed"))
+
+	_, err := r.Read()
+	assert.Error(t, err)
+
+	bytes, err := r.ReadBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, testVal, string(bytes))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var ch = make(chan string)
+	go r.Watch(ctx, func() error {
+		bytes, err := r.ReadBytes()
+		if err != nil {
+			return err
+		}
+		ch <- string(bytes)
+		return nil
+	})
+
+	time.Sleep(time.Second)
+	assert.NoError(t, client.Set(context.Background(), "config.yaml", "name: foo", 0).Err())
+	assert.NoError(t, client.Publish(context.Background(), "config.yaml.changed", "").Err())
+	assert.Equal(t, "name: foo", <-ch)
+}
+
+func TestRemote_keyspace(t *testing.T) {
+	if os.Getenv("REDIS_ADDR") == "" {
+		t.Skip("set REDIS_ADDR to run TestRemote_keyspace")
+		return
+	}
+	client := redis.NewUniversalClient(&redis.UniversalOptions{
+		Addrs: strings.Split(os.Getenv("REDIS_ADDR"), ","),
+	})
+	defer client.Close()
+
+	notify, err := client.ConfigGet(context.Background(), "notify-keyspace-events").Result()
+	assert.NoError(t, err)
+	assert.NoError(t, client.ConfigSet(context.Background(), "notify-keyspace-events", "K$g").Err())
+	defer client.ConfigSet(context.Background(), "notify-keyspace-events", notify[1].(string))
+
+	assert.NoError(t, client.Set(context.Background(), "config-keyspace.yaml", "name: app", 0).Err())
+	r := Provider(client, "config-keyspace.yaml")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var ch = make(chan string)
+	go r.Watch(ctx, func() error {
+		bytes, err := r.ReadBytes()
+		if err != nil {
+			return err
+		}
+		ch <- string(bytes)
+		return nil
+	})
+
+	time.Sleep(time.Second)
+	assert.NoError(t, client.Set(context.Background(), "config-keyspace.yaml", "name: foo", 0).Err())
+	select {
+	case val := <-ch:
+		assert.Equal(t, "name: foo", val)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reload after the key changed")
+	}
+}
+
+func TestError(t *testing.T) {
+	if os.Getenv("REDIS_ADDR") == "" {
+		t.Skip("set REDIS_ADDR to run TestError")
+		return
+	}
+	client := redis.NewUniversalClient(&redis.UniversalOptions{
+		Addrs: strings.Split(os.Getenv("REDIS_ADDR"), ","),
+	})
+	defer client.Close()
+
+	r := Provider(client, "config-test-not-exist")
+	_, err := r.ReadBytes()
+	assert.Error(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = r.Watch(ctx, func() error { return nil })
+	assert.Error(t, err)
+}
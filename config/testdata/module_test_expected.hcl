@@ -0,0 +1,5 @@
+# A mock config
+foo = "bar"
+
+# Other mock config
+baz = "qux"
@@ -2,7 +2,7 @@ package watcher
 
 import (
 	"context"
-	"fmt"
+	"os"
 	"path/filepath"
 	"time"
 
@@ -20,13 +20,24 @@ type File struct {
 }
 
 // Watch watches the change to the file. If the file is edited or created, the reload function will be called.
+// If the file is removed or does not exist yet, Watch waits for it to be created rather than returning an error.
 // note the reload function should not just load the changes made within this file, but rather it should reload
 // the whole config stack. For example, if the flag or env takes precedence over the config file, they should remain
 // to be so after the file changes.
 func (f File) Watch(ctx context.Context, reload func() error) error {
+	var (
+		lastEvent     string
+		lastEventTime time.Time
+		missing       bool
+	)
+
 	// Resolve symlinks and save the original path so that changes to symlinks
-	// can be detected.
+	// can be detected. The file may not exist yet, in which case we wait for
+	// its creation.
 	realPath, err := filepath.EvalSymlinks(f.Path)
+	if os.IsNotExist(err) {
+		realPath, missing, err = f.Path, true, nil
+	}
 	if err != nil {
 		return err
 	}
@@ -42,11 +53,6 @@ func (f File) Watch(ctx context.Context, reload func() error) error {
 	}
 	defer w.Close()
 
-	var (
-		lastEvent     string
-		lastEventTime time.Time
-	)
-
 	err = w.Add(fDir)
 	if err != nil {
 		return errors.Wrap(err, "unable to add watch dir")
@@ -69,29 +75,34 @@ func (f File) Watch(ctx context.Context, reload func() error) error {
 
 			evFile := filepath.Clean(event.Name)
 
-			// Since the event is triggered on a directory, is this
-			// one on the file being watched?
-			if evFile != realPath && evFile != f.Path {
-				continue
-			}
-
-			// The file was removed.
-			if event.Op&fsnotify.Remove != 0 {
-				return fmt.Errorf("file %s was removed", event.Name)
-			}
-
 			// Resolve symlink to get the real path, in case the symlink's
-			// target has changed.
+			// target has changed. If the file can not be resolved, it is
+			// removed. This is often transient, for example when a ConfigMap
+			// swaps its symlinks atomically or an editor renames the file into
+			// place, so keep watching until the file shows up again.
 			curPath, err := filepath.EvalSymlinks(f.Path)
 			if err != nil {
+				if os.IsNotExist(err) {
+					missing = true
+					continue
+				}
 				return err
 			}
-			realPath = filepath.Clean(curPath)
-
-			// Finally, we only care about create and write.
-			if event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+			curPath = filepath.Clean(curPath)
+
+			switch {
+			// The file reappeared after being removed.
+			case missing:
+				missing = false
+			// The symlink now points to another file.
+			case curPath != realPath:
+			// Since the event is triggered on a directory, is this
+			// one on the file being watched? We only care about create and write.
+			case (evFile == realPath || evFile == filepath.Clean(f.Path)) && event.Op&(fsnotify.Write|fsnotify.Create) != 0:
+			default:
 				continue
 			}
+			realPath = curPath
 
 			// Trigger event.
 			if err = reload(); err != nil {
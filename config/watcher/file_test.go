@@ -5,6 +5,7 @@ import (
 	"errors"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -37,11 +38,12 @@ func TestWatch(t *testing.T) {
 	t.Run("delete", func(t *testing.T) {
 		t.Parallel()
 		var (
-			ch     chan struct{}
-			called atomic.Bool
+			ch       chan struct{}
+			returned atomic.Bool
 		)
 		ch = make(chan struct{})
 		f, _ := ioutil.TempFile(".", "*")
+		defer os.Remove(f.Name())
 
 		ioutil.WriteFile(f.Name(), []byte(`foo`), os.ModePerm)
 
@@ -51,16 +53,48 @@ func TestWatch(t *testing.T) {
 
 		go func() {
 			w.Watch(ctx, func() error {
-				called.Store(true)
 				ch <- struct{}{}
 				return nil
 			})
-			ch <- struct{}{}
+			returned.Store(true)
 		}()
 		time.Sleep(time.Second)
 		os.Remove(f.Name())
+		time.Sleep(100 * time.Millisecond)
+		assert.False(t, returned.Load())
+
+		ioutil.WriteFile(f.Name(), []byte(`bar`), os.ModePerm)
+		<-ch
+		assert.False(t, returned.Load())
+	})
+
+	t.Run("symlink swap", func(t *testing.T) {
+		t.Parallel()
+		ch := make(chan struct{}, 2)
+		dir, _ := ioutil.TempDir(".", "*")
+		defer os.RemoveAll(dir)
+
+		os.Mkdir(filepath.Join(dir, "v1"), os.ModePerm)
+		os.Mkdir(filepath.Join(dir, "v2"), os.ModePerm)
+		ioutil.WriteFile(filepath.Join(dir, "v1", "config.yaml"), []byte(`foo`), os.ModePerm)
+		ioutil.WriteFile(filepath.Join(dir, "v2", "config.yaml"), []byte(`bar`), os.ModePerm)
+		os.Symlink("v1", filepath.Join(dir, "data"))
+		os.Symlink(filepath.Join("data", "config.yaml"), filepath.Join(dir, "config.yaml"))
+
+		w := File{filepath.Join(dir, "config.yaml")}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		go w.Watch(ctx, func() error {
+			ch <- struct{}{}
+			return nil
+		})
+		time.Sleep(time.Second)
+
+		// Swap the data symlink atomically, like a kubernetes ConfigMap does.
+		os.Symlink("v2", filepath.Join(dir, "data_tmp"))
+		os.Rename(filepath.Join(dir, "data_tmp"), filepath.Join(dir, "data"))
 		<-ch
-		assert.False(t, called.Load())
 	})
 
 	t.Run("reload failed", func(t *testing.T) {
@@ -92,3 +126,22 @@ func TestWatch(t *testing.T) {
 		assert.True(t, called.Load())
 	})
 }
+
+func TestWatch_notExist(t *testing.T) {
+	t.Parallel()
+	ch := make(chan struct{})
+	dir, _ := ioutil.TempDir(".", "*")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.local.yaml")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go Multi{File{filepath.Join(dir, "config.yaml")}, File{path}}.Watch(ctx, func() error {
+		ch <- struct{}{}
+		return nil
+	})
+	time.Sleep(time.Second)
+	ioutil.WriteFile(path, []byte(`foo`), os.ModePerm)
+	<-ch
+}
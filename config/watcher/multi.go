@@ -0,0 +1,25 @@
+package watcher
+
+import (
+	"context"
+
+	"github.com/DoNewsCode/core/contract"
+	"golang.org/x/sync/errgroup"
+)
+
+// Multi combines several watchers into one. The reload function is called
+// whenever any of them fires. Watch returns when the context is canceled or
+// any of the watchers fails, in which case the others are stopped.
+type Multi []contract.ConfigWatcher
+
+// Watch runs all the watchers concurrently.
+func (m Multi) Watch(ctx context.Context, reload func() error) error {
+	group, ctx := errgroup.WithContext(ctx)
+	for _, w := range m {
+		w := w
+		group.Go(func() error {
+			return w.Watch(ctx, reload)
+		})
+	}
+	return group.Wait()
+}
@@ -0,0 +1,28 @@
+/*
+Package coretest provides a harness to boot a core.C in integration tests.
+
+The harness builds the core from a map config, provides the essentials,
+replaces or decorates any provider, starts the serve run group on ephemeral ports
+and tears everything down when the test ends:
+
+	func TestModule(t *testing.T) {
+		h := coretest.New(t, map[string]interface{}{
+			"gorm.default.database": "sqlite",
+			"gorm.default.dsn":      ":memory:",
+		},
+			coretest.WithProviders(otgorm.Providers()),
+			coretest.WithOverrides(di.Deps{func() contract.Env { return config.EnvTesting }}),
+			coretest.WithModuleFuncs(mypackage.NewModule),
+		)
+		h.Serve()
+
+		resp, err := http.Get("http://" + h.HTTPAddr() + "/foo")
+		...
+	}
+
+The overrides take the place of the providers of the same types, in the
+providers given with WithProviders as well as in the modules. The core
+dependencies, such as the logger or the dispatcher, are replaced with core
+options instead, for example core.SetLoggerProvider.
+*/
+package coretest
@@ -0,0 +1,256 @@
+package coretest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core"
+	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/di"
+	"github.com/DoNewsCode/core/events"
+	"github.com/knadh/koanf/providers/confmap"
+)
+
+type options struct {
+	coreOptions  []core.CoreOption
+	providers    di.Deps
+	overrides    di.Deps
+	decorators   []interface{}
+	modules      []interface{}
+	moduleFuncs  []interface{}
+	startTimeout time.Duration
+}
+
+// Option configures the Harness.
+type Option func(*options)
+
+// WithCoreOptions is an Option that passes core options to core.New. The map
+// config given to New has a higher priority than the config layers added here.
+func WithCoreOptions(opts ...core.CoreOption) Option {
+	return func(o *options) {
+		o.coreOptions = append(o.coreOptions, opts...)
+	}
+}
+
+// WithProviders is an Option that adds dependency providers to the core, as
+// core.C.Provide does.
+func WithProviders(deps di.Deps) Option {
+	return func(o *options) {
+		o.providers = append(o.providers, deps...)
+	}
+}
+
+// WithOverrides is an Option that replaces the providers of the same types with
+// the given ones, typically fakes. See di.Graph.Scope for the details.
+func WithOverrides(deps di.Deps) Option {
+	return func(o *options) {
+		o.overrides = append(o.overrides, deps...)
+	}
+}
+
+// WithDecorators is an Option that wraps dependencies after their construction,
+// as core.C.Decorate does. The decorators apply to the overrides as well.
+func WithDecorators(decorators ...interface{}) Option {
+	return func(o *options) {
+		o.decorators = append(o.decorators, decorators...)
+	}
+}
+
+// WithModules is an Option that adds modules to the core, as core.C.AddModule
+// does.
+func WithModules(modules ...interface{}) Option {
+	return func(o *options) {
+		o.modules = append(o.modules, modules...)
+	}
+}
+
+// WithModuleFuncs is an Option that adds modules to the core after invoking
+// their constructors, as core.C.AddModuleFunc does. The constructors see the
+// overrides.
+func WithModuleFuncs(constructors ...interface{}) Option {
+	return func(o *options) {
+		o.moduleFuncs = append(o.moduleFuncs, constructors...)
+	}
+}
+
+// WithStartTimeout is an Option that sets how long Serve waits for the servers
+// to start. The default is 5 seconds.
+func WithStartTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.startTimeout = timeout
+	}
+}
+
+// Harness is a core.C built for tests. It embeds the core, so the dependencies
+// can be invoked directly.
+type Harness struct {
+	*core.C
+
+	t            testing.TB
+	startTimeout time.Duration
+
+	subscribe   sync.Once
+	mu          sync.Mutex
+	httpAddr    net.Addr
+	grpcAddr    net.Addr
+	httpStarted chan struct{}
+	grpcStarted chan struct{}
+	stop        func()
+}
+
+// New creates a Harness from the map config. The keys may be nested maps or
+// dotted paths, such as "http.addr". Unless set otherwise, the HTTP and gRPC
+// servers listen on ephemeral ports of 127.0.0.1 and the logs are discarded.
+//
+// The core is shut down when the test and its subtests complete.
+func New(t testing.TB, conf map[string]interface{}, opts ...Option) *Harness {
+	t.Helper()
+
+	o := options{startTimeout: 5 * time.Second}
+	for _, f := range opts {
+		f(&o)
+	}
+
+	coreOptions := []core.CoreOption{core.WithConfigStack(confmap.Provider(conf, "."), nil)}
+	coreOptions = append(coreOptions, o.coreOptions...)
+	coreOptions = append(coreOptions, core.WithConfigStack(confmap.Provider(map[string]interface{}{
+		"http.addr": "127.0.0.1:0",
+		"grpc.addr": "127.0.0.1:0",
+		"log.level": "none",
+	}, "."), nil))
+
+	c := core.New(coreOptions...)
+	t.Cleanup(c.Shutdown)
+	c.ProvideEssentials()
+	c.Provide(o.providers)
+	for _, decorator := range o.decorators {
+		c.Decorate(decorator)
+	}
+	if len(o.overrides) > 0 {
+		scoped, err := c.Scope(o.overrides)
+		if err != nil {
+			t.Fatalf("failed to override providers: %s", err)
+		}
+		c = scoped
+	}
+	c.AddModule(o.modules...)
+	for _, constructor := range o.moduleFuncs {
+		c.AddModuleFunc(constructor)
+	}
+
+	return &Harness{C: c, t: t, startTimeout: o.startTimeout}
+}
+
+// Serve starts the serve run group in the background and waits for the HTTP
+// and gRPC servers to listen, unless they are disabled. The run group is
+// stopped when the test and its subtests complete. It fails the test if the
+// servers can't be started in time.
+func (h *Harness) Serve() {
+	h.t.Helper()
+
+	// The listeners outlive a run, as the dispatcher doesn't support
+	// unsubscribing. They are subscribed once, and signal the run started last.
+	h.subscribe.Do(func() {
+		h.Invoke(func(dispatcher contract.Dispatcher) {
+			dispatcher.Subscribe(events.Listen(core.OnHTTPServerStart, func(ctx context.Context, event interface{}) error {
+				h.started(&h.httpAddr, &h.httpStarted, event.(core.OnHTTPServerStartPayload).Listener.Addr())
+				return nil
+			}))
+			dispatcher.Subscribe(events.Listen(core.OnGRPCServerStart, func(ctx context.Context, event interface{}) error {
+				h.started(&h.grpcAddr, &h.grpcStarted, event.(core.OnGRPCServerStartPayload).Listener.Addr())
+				return nil
+			}))
+		})
+	})
+	httpStarted, grpcStarted := make(chan struct{}), make(chan struct{})
+	h.mu.Lock()
+	h.httpStarted, h.grpcStarted = httpStarted, grpcStarted
+	h.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var (
+		serveErr error
+		done     = make(chan struct{})
+		once     sync.Once
+	)
+	go func() {
+		serveErr = h.C.Serve(ctx)
+		close(done)
+	}()
+	h.stop = func() {
+		once.Do(func() {
+			cancel()
+			<-done
+			if serveErr != nil {
+				h.t.Errorf("failed to serve: %s", serveErr)
+			}
+		})
+	}
+	h.t.Cleanup(h.stop)
+
+	timeout := time.After(h.startTimeout)
+	for _, server := range []struct {
+		name    string
+		started chan struct{}
+	}{
+		{"http", httpStarted},
+		{"grpc", grpcStarted},
+	} {
+		if h.Bool(server.name + ".disable") {
+			continue
+		}
+		select {
+		case <-server.started:
+		case <-done:
+			h.stop()
+			h.t.Fatalf("%s server did not start", server.name)
+		case <-timeout:
+			h.t.Fatalf("%s server did not start within %s", server.name, h.startTimeout)
+		}
+	}
+}
+
+// started records the address of a server and signals its start, once per run.
+func (h *Harness) started(addr *net.Addr, started *chan struct{}, listening net.Addr) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	*addr = listening
+	if *started != nil {
+		close(*started)
+		*started = nil
+	}
+}
+
+// Stop stops the run group started by Serve and waits for it to return. It is
+// called automatically when the test completes, but may be used to test the
+// shutdown.
+func (h *Harness) Stop() {
+	if h.stop != nil {
+		h.stop()
+	}
+}
+
+// HTTPAddr returns the address the HTTP server listens on, such as
+// "127.0.0.1:34567". It is empty until Serve is called.
+func (h *Harness) HTTPAddr() string {
+	return h.addr(&h.httpAddr)
+}
+
+// GRPCAddr returns the address the gRPC server listens on, such as
+// "127.0.0.1:34567". It is empty until Serve is called.
+func (h *Harness) GRPCAddr() string {
+	return h.addr(&h.grpcAddr)
+}
+
+func (h *Harness) addr(addr *net.Addr) string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if *addr == nil {
+		return ""
+	}
+	return fmt.Sprint(*addr)
+}
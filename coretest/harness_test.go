@@ -0,0 +1,88 @@
+package coretest
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/DoNewsCode/core/di"
+	"github.com/DoNewsCode/core/srvgrpc"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+type greeting string
+
+type greetingModule struct {
+	greeting greeting
+}
+
+func newGreetingModule(greeting greeting) greetingModule {
+	return greetingModule{greeting: greeting}
+}
+
+func (m greetingModule) ProvideHTTP(router *mux.Router) {
+	router.HandleFunc("/greet", func(writer http.ResponseWriter, request *http.Request) {
+		writer.Write([]byte(m.greeting))
+	})
+}
+
+func TestHarness(t *testing.T) {
+	h := New(t, map[string]interface{}{"name": "test"},
+		WithProviders(di.Deps{func() greeting { return "hello" }}),
+		WithOverrides(di.Deps{func() greeting { return "fake" }}),
+		WithDecorators(func(g greeting) greeting { return g + "!" }),
+		WithModuleFuncs(newGreetingModule),
+		WithModules(srvgrpc.HealthCheckModule{}),
+	)
+	assert.Equal(t, "test", h.String("name"))
+	assert.Empty(t, h.HTTPAddr())
+	h.Serve()
+
+	resp, err := http.Get("http://" + h.HTTPAddr() + "/greet")
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	assert.Equal(t, "fake!", string(body))
+
+	conn, err := grpc.Dial(h.GRPCAddr(), grpc.WithInsecure())
+	assert.NoError(t, err)
+	defer conn.Close()
+	health, err := grpc_health_v1.NewHealthClient(conn).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, health.Status)
+
+	h.Stop()
+	_, err = http.Get("http://" + h.HTTPAddr() + "/greet")
+	assert.Error(t, err)
+}
+
+func TestHarness_disabled(t *testing.T) {
+	h := New(t, map[string]interface{}{
+		"http": map[string]interface{}{"disable": true},
+		"grpc": map[string]interface{}{"disable": true},
+		"cron": map[string]interface{}{"disable": true},
+	})
+	h.Serve()
+	assert.Empty(t, h.HTTPAddr())
+	assert.Empty(t, h.GRPCAddr())
+}
+
+func TestHarness_restart(t *testing.T) {
+	h := New(t, map[string]interface{}{"cron": map[string]interface{}{"disable": true}},
+		WithModules(srvgrpc.HealthCheckModule{}),
+	)
+	h.Serve()
+	h.Stop()
+	h.Serve()
+
+	conn, err := grpc.Dial(h.GRPCAddr(), grpc.WithInsecure())
+	assert.NoError(t, err)
+	defer conn.Close()
+	health, err := grpc_health_v1.NewHealthClient(conn).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, health.Status)
+}
@@ -3,7 +3,7 @@ package core
 import (
 	"fmt"
 	stdlog "log"
-	"net"
+	"runtime"
 
 	"github.com/DoNewsCode/core/config"
 	"github.com/DoNewsCode/core/contract"
@@ -22,14 +22,75 @@ env: local
 http:
   addr: :8080
   disable: false
+  tls:
+    certFile: ""
+    keyFile: ""
+    clientCAFile: ""
+    minVersion: "1.2"
+    cipherSuites: []
+  middleware:
+    realIP:
+      enable: false
+      trustedProxies: []
+    requestID:
+      enable: false
+      header: X-Request-Id
+    recovery:
+      enable: false
+    cors:
+      enable: false
+      allowedOrigins: []
+      allowedMethods: []
+      allowedHeaders: []
+      exposedHeaders: []
+      allowCredentials: false
+      maxAge: 0
+    bodyLimit:
+      enable: false
+      maxBytes: 4194304
+    timeout:
+      enable: false
+      duration: 30s
+    compression:
+      enable: false
 grpc:
   addr: :9090
   disable: false
+  multiplex: false
+  tls:
+    certFile: ""
+    keyFile: ""
+    clientCAFile: ""
+    minVersion: "1.2"
+    cipherSuites: []
+  interceptor:
+    ctxmeta:
+      enable: false
+      keys: [x-request-id]
+    logging:
+      enable: false
+    recovery:
+      enable: false
+    unierr:
+      enable: false
+    deadline:
+      enable: false
+      max: 30s
+    validation:
+      enable: false
 cron:
   disable: false
+restart:
+  enable: false
+  timeout: 30s
 log:
   level: debug
   format: logfmt
+startup:
+  eager: []
+modules:
+  enabled: []
+  disabled: []
 redis:
   default:
     addrs:
@@ -41,7 +102,9 @@ gorm:
     dsn: ":memory:"
 `
 
-// ProvideConfig is the default ConfigProvider for package Core.
+// ProvideConfig is the default ConfigProvider for package Core. If an encryption
+// key is found in the environment (see config.LoadKey), values in the
+// ENC[AES256_GCM,...] form are decrypted on every reload.
 func ProvideConfig(configStack []config.ProviderSet, configWatcher contract.ConfigWatcher) contract.ConfigUnmarshaler {
 	var (
 		stack []config.Option
@@ -56,6 +119,17 @@ func ProvideConfig(configStack []config.ProviderSet, configWatcher contract.Conf
 	if configWatcher != nil {
 		stack = append(stack, config.WithWatcher(configWatcher))
 	}
+	key, err := config.LoadKey()
+	if err != nil {
+		stdlog.Fatal(err)
+	}
+	if key != nil {
+		cipher, err := config.NewAESGCM(key)
+		if err != nil {
+			stdlog.Fatal(err)
+		}
+		stack = append(stack, config.WithCipher(cipher))
+	}
 
 	cfg, err = config.NewConfig(stack...)
 	if err != nil {
@@ -150,9 +224,49 @@ func provideDefaultConfig() []config.ExportedConfig {
 				"http": map[string]interface{}{
 					"addr":    ":8080",
 					"disable": false,
+					"tls": map[string]interface{}{
+						"certFile":     "",
+						"keyFile":      "",
+						"clientCAFile": "",
+						"minVersion":   "1.2",
+						"cipherSuites": []string{},
+					},
+					"middleware": map[string]interface{}{
+						"realIP": map[string]interface{}{
+							"enable":         false,
+							"trustedProxies": []string{},
+						},
+						"requestID": map[string]interface{}{
+							"enable": false,
+							"header": "X-Request-Id",
+						},
+						"recovery": map[string]interface{}{
+							"enable": false,
+						},
+						"cors": map[string]interface{}{
+							"enable":           false,
+							"allowedOrigins":   []string{},
+							"allowedMethods":   []string{},
+							"allowedHeaders":   []string{},
+							"exposedHeaders":   []string{},
+							"allowCredentials": false,
+							"maxAge":           0,
+						},
+						"bodyLimit": map[string]interface{}{
+							"enable":   false,
+							"maxBytes": 4194304,
+						},
+						"timeout": map[string]interface{}{
+							"enable":   false,
+							"duration": "30s",
+						},
+						"compression": map[string]interface{}{
+							"enable": false,
+						},
+					},
 				},
 			},
-			Comment: "The http address",
+			Comment: "The http address, either TCP, unix:///path or systemd://name, the TLS certificate to serve https with if certFile is set, and the middlewares wrapping every request",
 			Validate: func(data map[string]interface{}) error {
 				disable, err := getBool(data, "http", "disable")
 				if err != nil {
@@ -165,21 +279,54 @@ func provideDefaultConfig() []config.ExportedConfig {
 				if err != nil {
 					return fmt.Errorf("the http.addr field is not valid: %w", err)
 				}
-				if _, err := net.ResolveTCPAddr("tcp", str); err != nil {
-					return fmt.Errorf("the http.addr field must be an valid address like :8080, got %s", str)
+				if err := validateAddr(str); err != nil {
+					return fmt.Errorf("the http.addr field must be an valid address like :8080, unix:///run/app/http.sock or systemd://http, got %s: %w", str, err)
 				}
-				return nil
+				if err := validateTLS(data, "http"); err != nil {
+					return err
+				}
+				return validateHTTPMiddleware(data)
 			},
 		},
 		{
 			Owner: "core",
 			Data: map[string]interface{}{
 				"grpc": map[string]interface{}{
-					"addr":    ":9090",
-					"disable": false,
+					"addr":      ":9090",
+					"disable":   false,
+					"multiplex": false,
+					"tls": map[string]interface{}{
+						"certFile":     "",
+						"keyFile":      "",
+						"clientCAFile": "",
+						"minVersion":   "1.2",
+						"cipherSuites": []string{},
+					},
+					"interceptor": map[string]interface{}{
+						"ctxmeta": map[string]interface{}{
+							"enable": false,
+							"keys":   []string{"x-request-id"},
+						},
+						"logging": map[string]interface{}{
+							"enable": false,
+						},
+						"recovery": map[string]interface{}{
+							"enable": false,
+						},
+						"unierr": map[string]interface{}{
+							"enable": false,
+						},
+						"deadline": map[string]interface{}{
+							"enable": false,
+							"max":    "30s",
+						},
+						"validation": map[string]interface{}{
+							"enable": false,
+						},
+					},
 				},
 			},
-			Comment: "The gRPC address",
+			Comment: "The gRPC address, either TCP, unix:///path or systemd://name, the TLS certificate to serve with if certFile is set, and the interceptors of every call. With multiplex, gRPC is served on http.addr instead",
 			Validate: func(data map[string]interface{}) error {
 				disable, err := getBool(data, "grpc", "disable")
 				if err != nil {
@@ -188,14 +335,22 @@ func provideDefaultConfig() []config.ExportedConfig {
 				if disable {
 					return nil
 				}
+				if values, _ := data["grpc"].(map[string]interface{}); values["multiplex"] != nil {
+					if _, err := getBool(data, "grpc", "multiplex"); err != nil {
+						return fmt.Errorf("the grpc.multiplex field is not valid: %w", err)
+					}
+				}
 				str, err := getString(data, "grpc", "addr")
 				if err != nil {
 					return fmt.Errorf("the grpc.addr field is not valid: %w", err)
 				}
-				if _, err := net.ResolveTCPAddr("tcp", str); err != nil {
-					return fmt.Errorf("the grpc.addr field must be an valid address like :9090, got %s", str)
+				if err := validateAddr(str); err != nil {
+					return fmt.Errorf("the grpc.addr field must be an valid address like :9090, unix:///run/app/grpc.sock or systemd://grpc, got %s: %w", str, err)
 				}
-				return nil
+				if err := validateTLS(data, "grpc"); err != nil {
+					return err
+				}
+				return validateGRPCInterceptor(data)
 			},
 		},
 		{
@@ -214,6 +369,33 @@ func provideDefaultConfig() []config.ExportedConfig {
 				return nil
 			},
 		},
+		{
+			Owner: "core",
+			Data: map[string]interface{}{
+				"restart": map[string]interface{}{
+					"enable":  false,
+					"timeout": "30s",
+				},
+			},
+			Comment: "Restart gracefully on SIGHUP or SIGUSR2, handing the listeners off to the new process, which must get ready within the timeout. Not supported on Windows",
+			Validate: func(data map[string]interface{}) error {
+				if _, ok := data["restart"]; !ok {
+					return nil
+				}
+				enable, err := getBool(data, "restart", "enable")
+				if err != nil {
+					return fmt.Errorf("the restart.enable field is not valid: %w", err)
+				}
+				if enable && len(restartSignals) == 0 {
+					return fmt.Errorf("the restart.enable field is not valid: graceful restarts are not supported on %s", runtime.GOOS)
+				}
+				var conf restartConf
+				if err := config.MapAdapter(data).Unmarshal("restart", &conf); err != nil {
+					return fmt.Errorf("the restart field is not valid: %w", err)
+				}
+				return nil
+			},
+		},
 		{
 			Owner: "core",
 			Data: map[string]interface{}{
@@ -238,5 +420,47 @@ func provideDefaultConfig() []config.ExportedConfig {
 				return nil
 			},
 		},
+		{
+			Owner: "core",
+			Data: map[string]interface{}{
+				"startup": map[string]interface{}{"eager": []string{}},
+			},
+			Comment: "The config kinds whose connections are made at startup rather than on first use, such as gorm or kafka.writer, or \"*\" for all",
+			Validate: func(data map[string]interface{}) error {
+				if _, ok := data["startup"]; !ok {
+					return nil
+				}
+				_, err := getStrings(data, "startup", "eager")
+				if err != nil {
+					return fmt.Errorf("the startup.eager field is not valid: %w", err)
+				}
+				return nil
+			},
+		},
+		{
+			Owner: "core",
+			Data: map[string]interface{}{
+				"modules": map[string]interface{}{"enabled": []string{}, "disabled": []string{}},
+			},
+			Comment: "The registered modules to run, all of them if enabled is empty, minus the disabled ones",
+			Validate: func(data map[string]interface{}) error {
+				if _, ok := data["modules"]; !ok {
+					return nil
+				}
+				modules, ok := data["modules"].(map[string]interface{})
+				if !ok {
+					return fmt.Errorf("the modules field is not valid: modules is not a map")
+				}
+				for _, key := range []string{"enabled", "disabled"} {
+					if _, ok := modules[key]; !ok {
+						continue
+					}
+					if _, err := getStrings(data, "modules", key); err != nil {
+						return fmt.Errorf("the modules.%s field is not valid: %w", key, err)
+					}
+				}
+				return nil
+			},
+		},
 	}
 }
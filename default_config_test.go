@@ -3,6 +3,7 @@ package core
 import (
 	"testing"
 
+	"github.com/DoNewsCode/core/config"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -16,22 +17,70 @@ func TestDefaultConfig(t *testing.T) {
 	}
 }
 
+// optionalConfigs are the sections that may be left out of older config files.
+var optionalConfigs = map[string]bool{"restart": true, "startup": true, "modules": true}
+
+func isOptionalConfig(c config.ExportedConfig) bool {
+	for key := range c.Data {
+		if optionalConfigs[key] {
+			return true
+		}
+	}
+	return false
+}
+
 func TestDefaultConfig_invalid(t *testing.T) {
 	conf := provideDefaultConfig()
 
 	t.Run("empty", func(t *testing.T) {
 		for _, c := range conf {
-			if c.Validate != nil {
-				err := c.Validate(map[string]interface{}{})
+			if c.Validate == nil {
+				continue
+			}
+			err := c.Validate(map[string]interface{}{})
+			if isOptionalConfig(c) {
+				assert.NoError(t, err)
+			} else {
 				assert.Error(t, err)
 			}
 		}
 	})
 
+	t.Run("unsupported restart", func(t *testing.T) {
+		signals := restartSignals
+		restartSignals = nil
+		defer func() { restartSignals = signals }()
+
+		data := map[string]interface{}{
+			"restart": map[string]interface{}{"enable": true, "timeout": "30s"},
+		}
+		for _, c := range provideDefaultConfig() {
+			if _, ok := c.Data["restart"]; ok {
+				assert.Error(t, c.Validate(data))
+				restartSignals = signals
+				assert.NoError(t, c.Validate(data))
+			}
+		}
+	})
+
+	t.Run("partial modules", func(t *testing.T) {
+		for _, c := range provideDefaultConfig() {
+			if _, ok := c.Data["modules"]; !ok {
+				continue
+			}
+			assert.NoError(t, c.Validate(map[string]interface{}{
+				"modules": map[string]interface{}{"disabled": []interface{}{"cron"}},
+			}))
+			assert.Error(t, c.Validate(map[string]interface{}{
+				"modules": map[string]interface{}{"enabled": "cron"},
+			}))
+		}
+	})
+
 	t.Run("invalid http addr", func(t *testing.T) {
 		conf := provideDefaultConfig()
 		for _, c := range conf {
-			if c.Validate != nil {
+			if c.Validate != nil && !isOptionalConfig(c) {
 				err := c.Validate(map[string]interface{}{
 					"http": map[string]interface{}{
 						"addr":    "aaa",
@@ -43,10 +92,89 @@ func TestDefaultConfig_invalid(t *testing.T) {
 		}
 	})
 
+	t.Run("invalid http tls", func(t *testing.T) {
+		conf := provideDefaultConfig()
+		for _, c := range conf {
+			if c.Validate != nil && !isOptionalConfig(c) {
+				err := c.Validate(map[string]interface{}{
+					"http": map[string]interface{}{
+						"addr":    ":8080",
+						"disable": false,
+						"tls": map[string]interface{}{
+							"certFile": "/not/exist.crt",
+							"keyFile":  "/not/exist.key",
+						},
+					},
+				})
+				assert.Error(t, err)
+			}
+		}
+	})
+
+	t.Run("invalid grpc tls", func(t *testing.T) {
+		conf := provideDefaultConfig()
+		for _, c := range conf {
+			if c.Validate != nil && !isOptionalConfig(c) {
+				err := c.Validate(map[string]interface{}{
+					"grpc": map[string]interface{}{
+						"addr":    ":9090",
+						"disable": false,
+						"tls": map[string]interface{}{
+							"minVersion": "1.4",
+						},
+					},
+				})
+				assert.Error(t, err)
+			}
+		}
+	})
+
+	t.Run("invalid http middleware", func(t *testing.T) {
+		conf := provideDefaultConfig()
+		for _, c := range conf {
+			if c.Validate != nil && !isOptionalConfig(c) {
+				err := c.Validate(map[string]interface{}{
+					"http": map[string]interface{}{
+						"addr":    ":8080",
+						"disable": false,
+						"middleware": map[string]interface{}{
+							"realIP": map[string]interface{}{
+								"enable":         true,
+								"trustedProxies": []string{"10.0.0.0/33"},
+							},
+						},
+					},
+				})
+				assert.Error(t, err)
+			}
+		}
+	})
+
+	t.Run("invalid grpc interceptor", func(t *testing.T) {
+		conf := provideDefaultConfig()
+		for _, c := range conf {
+			if c.Validate != nil && !isOptionalConfig(c) {
+				err := c.Validate(map[string]interface{}{
+					"grpc": map[string]interface{}{
+						"addr":    ":9090",
+						"disable": false,
+						"interceptor": map[string]interface{}{
+							"deadline": map[string]interface{}{
+								"enable": true,
+								"max":    "0s",
+							},
+						},
+					},
+				})
+				assert.Error(t, err)
+			}
+		}
+	})
+
 	t.Run("invalid grpc addr", func(t *testing.T) {
 		conf := provideDefaultConfig()
 		for _, c := range conf {
-			if c.Validate != nil {
+			if c.Validate != nil && !isOptionalConfig(c) {
 				err := c.Validate(map[string]interface{}{
 					"grpc": map[string]interface{}{
 						"addr":    "aaa",
@@ -61,7 +189,7 @@ func TestDefaultConfig_invalid(t *testing.T) {
 	t.Run("disabled transport http", func(t *testing.T) {
 		conf := provideDefaultConfig()
 		for _, c := range conf {
-			if c.Validate != nil {
+			if c.Validate != nil && !isOptionalConfig(c) {
 				err := c.Validate(map[string]interface{}{
 					"http": map[string]interface{}{
 						"addr":    "aaa",
@@ -79,7 +207,7 @@ func TestDefaultConfig_invalid(t *testing.T) {
 	t.Run("disabled transport grpc", func(t *testing.T) {
 		conf := provideDefaultConfig()
 		for _, c := range conf {
-			if c.Validate != nil {
+			if c.Validate != nil && !isOptionalConfig(c) {
 				err := c.Validate(map[string]interface{}{
 					"grpc": map[string]interface{}{
 						"addr":    "aaa",
@@ -97,7 +225,7 @@ func TestDefaultConfig_invalid(t *testing.T) {
 	t.Run("transport not map", func(t *testing.T) {
 		conf := provideDefaultConfig()
 		for _, c := range conf {
-			if c.Validate != nil {
+			if c.Validate != nil && !isOptionalConfig(c) {
 				err := c.Validate(map[string]interface{}{
 					"grpc": ":8080",
 				})
@@ -109,7 +237,7 @@ func TestDefaultConfig_invalid(t *testing.T) {
 	t.Run("wrong type", func(t *testing.T) {
 		conf := provideDefaultConfig()
 		for _, c := range conf {
-			if c.Validate != nil {
+			if c.Validate != nil && !isOptionalConfig(c) {
 				err := c.Validate(map[string]interface{}{
 					"grpc": map[string]interface{}{
 						"disable": "",
@@ -123,7 +251,7 @@ func TestDefaultConfig_invalid(t *testing.T) {
 	t.Run("wrong env", func(t *testing.T) {
 		conf := provideDefaultConfig()
 		for _, c := range conf {
-			if c.Validate != nil {
+			if c.Validate != nil && !isOptionalConfig(c) {
 				err := c.Validate(map[string]interface{}{
 					"env": "bar",
 				})
@@ -135,7 +263,7 @@ func TestDefaultConfig_invalid(t *testing.T) {
 	t.Run("wrong app", func(t *testing.T) {
 		conf := provideDefaultConfig()
 		for _, c := range conf {
-			if c.Validate != nil {
+			if c.Validate != nil && !isOptionalConfig(c) {
 				err := c.Validate(map[string]interface{}{
 					"app": 1,
 				})
@@ -147,7 +275,7 @@ func TestDefaultConfig_invalid(t *testing.T) {
 	t.Run("wrong log level", func(t *testing.T) {
 		conf := provideDefaultConfig()
 		for _, c := range conf {
-			if c.Validate != nil {
+			if c.Validate != nil && !isOptionalConfig(c) {
 				err := c.Validate(map[string]interface{}{
 					"level": map[string]interface{}{
 						"format": "json",
@@ -162,7 +290,7 @@ func TestDefaultConfig_invalid(t *testing.T) {
 	t.Run("wrong log format", func(t *testing.T) {
 		conf := provideDefaultConfig()
 		for _, c := range conf {
-			if c.Validate != nil {
+			if c.Validate != nil && !isOptionalConfig(c) {
 				err := c.Validate(map[string]interface{}{
 					"level": map[string]interface{}{
 						"format": "foo",
@@ -178,7 +306,7 @@ func TestDefaultConfig_invalid(t *testing.T) {
 func TestDefaultConfig_network(t *testing.T) {
 	conf := provideDefaultConfig()
 	for _, c := range conf {
-		if c.Validate != nil {
+		if c.Validate != nil && !isOptionalConfig(c) {
 			err := c.Validate(map[string]interface{}{
 				"http": map[string]interface{}{
 					"addr":    "aaa",
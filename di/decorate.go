@@ -0,0 +1,227 @@
+package di
+
+import (
+	"fmt"
+	"reflect"
+
+	"go.uber.org/dig"
+)
+
+// decorator is a function wrapping the value of a type after construction.
+type decorator struct {
+	fn   interface{}
+	key  string
+	desc Constructor
+}
+
+// Decorate registers a decorator, a function in the form of
+//
+//  func(value T, deps...) T
+//  func(value T, deps...) (T, error)
+//
+// The decorator receives the value of type T built by its constructor, and
+// returns the value handed to the consumers of T instead. The other parameters
+// are resolved from the graph, like the parameters of a constructor. The other
+// results of the decorated constructor are handed to the decorator directly,
+// so it may depend on them without introducing a cycle.
+//
+// Decorators of the same type are applied in the order they are registered,
+// before the value is handed to any consumer. So the constructor of T must not
+// be used before decorating it: Decorate returns an error if Invoke has been
+// called since T was provided. Only unnamed values can be decorated, and the
+// values shared by a parent graph can only be decorated in the parent.
+func (g *Graph) Decorate(fn interface{}) error {
+	ftype := reflect.TypeOf(fn)
+	if ftype == nil || ftype.Kind() != reflect.Func || ftype.IsVariadic() || ftype.NumIn() == 0 {
+		return fmt.Errorf("must provide decorator function in the form of func(T, deps...) T, got %v (type %v)", fn, ftype)
+	}
+	t := ftype.In(0)
+	if dig.IsIn(t) || ftype.NumOut() == 0 || ftype.NumOut() > 2 || ftype.Out(0) != t ||
+		(ftype.NumOut() == 2 && ftype.Out(1) != _errType) {
+		return fmt.Errorf("must provide decorator function in the form of func(T, deps...) T, got %v", ftype)
+	}
+
+	key := valueKey(t, "", "")
+	for i, e := range g.entries {
+		if !overlaps(e.desc, map[string]bool{key: true}) {
+			continue
+		}
+		if e.shared != nil {
+			return fmt.Errorf("cannot decorate %v: it is shared with the parent graph", t)
+		}
+		if i < g.flushed {
+			return fmt.Errorf("cannot decorate %v: its constructor %s may be in use already, decorate it before invoking", t, e.desc.Name)
+		}
+	}
+	desc, _ := Describe(fn)
+	g.decorators = append(g.decorators, decorator{fn: fn, key: key, desc: desc})
+	return nil
+}
+
+// decorationTarget locates a decorated value in the results of a constructor.
+// field is -1 if the value is a result itself, rather than a field of a di.Out
+// struct.
+type decorationTarget struct {
+	decorator decorator
+	result    int
+	field     int
+}
+
+// decorationSource locates a dependency of a decorator, either in the
+// arguments of the decorated constructor, or in its results if arg is -1.
+type decorationSource struct {
+	arg    int
+	result int
+	field  int
+}
+
+// decorate wraps the constructor of the entry so that its results are passed
+// through the matching decorators. The constructor is returned as is if there
+// is none, so that dig reports errors with the name of the constructor.
+func (g *Graph) decorate(e entry) interface{} {
+	if len(g.decorators) == 0 {
+		return e.constructor
+	}
+	ftype := reflect.TypeOf(e.constructor)
+
+	var targets []decorationTarget
+	for _, d := range g.decorators {
+		for i := 0; i < ftype.NumOut(); i++ {
+			out := ftype.Out(i)
+			if !dig.IsOut(out) {
+				if valueKey(out, "", "") == d.key {
+					targets = append(targets, decorationTarget{d, i, -1})
+				}
+				continue
+			}
+			for j := 0; j < out.NumField(); j++ {
+				f := out.Field(j)
+				if f.PkgPath != "" || f.Tag.Get("name") != "" || f.Tag.Get("group") != "" {
+					continue
+				}
+				if valueKey(f.Type, "", "") == d.key {
+					targets = append(targets, decorationTarget{d, i, j})
+				}
+			}
+		}
+	}
+	if len(targets) == 0 {
+		return e.constructor
+	}
+
+	// ownResult locates an unnamed value among the results of the constructor,
+	// so that a decorator can depend on the other results of the constructor
+	// it decorates without introducing a cycle.
+	ownResult := func(t reflect.Type) (result, field int, ok bool) {
+		for i := 0; i < ftype.NumOut(); i++ {
+			out := ftype.Out(i)
+			if !dig.IsOut(out) {
+				if out == t {
+					return i, -1, true
+				}
+				continue
+			}
+			for j := 0; j < out.NumField(); j++ {
+				f := out.Field(j)
+				if f.PkgPath == "" && f.Tag.Get("name") == "" && f.Tag.Get("group") == "" && f.Type == t {
+					return i, j, true
+				}
+			}
+		}
+		return 0, 0, false
+	}
+
+	// The decorated constructor takes the parameters of the constructor, then
+	// the dependencies of each decorator that are not results of the
+	// constructor. Like dig, it passes nothing to the variadic parameter.
+	var (
+		inTypes  []reflect.Type
+		outTypes []reflect.Type
+		numIn    = ftype.NumIn()
+		hasErr   = ftype.NumOut() > 0 && ftype.Out(ftype.NumOut()-1) == _errType
+	)
+	if ftype.IsVariadic() {
+		numIn--
+	}
+	for i := 0; i < numIn; i++ {
+		inTypes = append(inTypes, ftype.In(i))
+	}
+	sources := make([][]decorationSource, len(targets))
+	for i, target := range targets {
+		dtype := reflect.TypeOf(target.decorator.fn)
+		for j := 1; j < dtype.NumIn(); j++ {
+			if result, field, ok := ownResult(dtype.In(j)); ok {
+				sources[i] = append(sources[i], decorationSource{arg: -1, result: result, field: field})
+				continue
+			}
+			sources[i] = append(sources[i], decorationSource{arg: len(inTypes)})
+			inTypes = append(inTypes, dtype.In(j))
+		}
+	}
+	for i := 0; i < ftype.NumOut(); i++ {
+		outTypes = append(outTypes, ftype.Out(i))
+	}
+	if !hasErr {
+		outTypes = append(outTypes, _errType)
+	}
+
+	fnType := reflect.FuncOf(inTypes, outTypes, false)
+	fn := reflect.MakeFunc(fnType, func(args []reflect.Value) []reflect.Value {
+		fail := func(err reflect.Value) []reflect.Value {
+			results := make([]reflect.Value, len(outTypes))
+			for i, t := range outTypes[:len(outTypes)-1] {
+				results[i] = reflect.Zero(t)
+			}
+			results[len(results)-1] = err
+			return results
+		}
+
+		constructorArgs := args[:numIn:numIn]
+		if ftype.IsVariadic() {
+			constructorArgs = append(constructorArgs, reflect.Zero(ftype.In(numIn)))
+		}
+		results := call(reflect.ValueOf(e.constructor), constructorArgs)
+		if hasErr && !results[len(results)-1].IsNil() {
+			return results
+		}
+		if !hasErr {
+			results = append(results, reflect.Zero(_errType))
+		}
+
+		for i, target := range targets {
+			value := results[target.result]
+			if target.field >= 0 {
+				copied := reflect.New(value.Type()).Elem()
+				copied.Set(value)
+				results[target.result] = copied
+				value = copied.Field(target.field)
+			}
+			dargs := []reflect.Value{value}
+			for _, source := range sources[i] {
+				switch {
+				case source.arg >= 0:
+					dargs = append(dargs, args[source.arg])
+				case source.field >= 0:
+					dargs = append(dargs, results[source.result].Field(source.field))
+				default:
+					dargs = append(dargs, results[source.result])
+				}
+			}
+			dresults := reflect.ValueOf(target.decorator.fn).Call(dargs)
+			if len(dresults) == 2 && !dresults[1].IsNil() {
+				// dig reports the error as coming from reflect.makeFuncStub,
+				// so name the decorator in the error instead.
+				desc := target.decorator.desc
+				err := fmt.Errorf("%s (%s): %w", desc.Name, desc.Location, dresults[1].Interface().(error))
+				return fail(reflect.ValueOf(&err).Elem())
+			}
+			if target.field >= 0 {
+				value.Set(dresults[0])
+				continue
+			}
+			results[target.result] = dresults[0]
+		}
+		return results
+	})
+	return fn.Interface()
+}
@@ -0,0 +1,136 @@
+package di
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type decorated struct {
+	trace []string
+}
+
+type decoratedOut struct {
+	Out
+
+	Value *decorated
+	Named *decorated `name:"named"`
+}
+
+func TestGraph_Decorate(t *testing.T) {
+	g := NewGraph()
+	// Decorators can be registered before the value is provided.
+	assert.NoError(t, g.Decorate(func(d *decorated) *decorated {
+		d.trace = append(d.trace, "first")
+		return d
+	}))
+	assert.NoError(t, g.Provide(func() string { return "second" }))
+	assert.NoError(t, g.Provide(func() (*decorated, func()) {
+		return &decorated{trace: []string{"constructor"}}, func() {}
+	}))
+	assert.NoError(t, g.Decorate(func(d *decorated, s string) (*decorated, error) {
+		d.trace = append(d.trace, s)
+		return d, nil
+	}))
+
+	var calls int
+	assert.NoError(t, g.Invoke(func(d *decorated) {
+		calls++
+		assert.Equal(t, []string{"constructor", "first", "second"}, d.trace)
+	}))
+	assert.NoError(t, g.Invoke(func(d *decorated) {
+		calls++
+		assert.Len(t, d.trace, 3)
+	}))
+	assert.Equal(t, 2, calls)
+
+	err := g.Decorate(func(d *decorated) *decorated { return d })
+	assert.Error(t, err)
+}
+
+func TestGraph_Decorate_out(t *testing.T) {
+	g := NewGraph()
+	assert.NoError(t, g.Provide(func() decoratedOut {
+		return decoratedOut{Value: &decorated{}, Named: &decorated{}}
+	}))
+	assert.NoError(t, g.Decorate(func(d *decorated) *decorated {
+		return &decorated{trace: []string{"decorated"}}
+	}))
+	assert.NoError(t, g.Invoke(func(in struct {
+		In
+
+		Value *decorated
+		Named *decorated `name:"named"`
+	}) {
+		assert.Equal(t, []string{"decorated"}, in.Value.trace)
+		assert.Empty(t, in.Named.trace)
+	}))
+}
+
+func TestGraph_Decorate_sibling(t *testing.T) {
+	type siblingOut struct {
+		Out
+
+		Value *decorated
+		Label string
+	}
+	g := NewGraph()
+	assert.NoError(t, g.Provide(func() siblingOut {
+		return siblingOut{Value: &decorated{}, Label: "sibling"}
+	}))
+	// The decorator depends on another result of the same constructor.
+	assert.NoError(t, g.Decorate(func(d *decorated, label string) *decorated {
+		return &decorated{trace: []string{label}}
+	}))
+	assert.NoError(t, g.Invoke(func(d *decorated) {
+		assert.Equal(t, []string{"sibling"}, d.trace)
+	}))
+}
+
+func TestGraph_Decorate_error(t *testing.T) {
+	g := NewGraph()
+	assert.NoError(t, g.Provide(func() *decorated { return &decorated{} }))
+	assert.NoError(t, g.Decorate(func(d *decorated) (*decorated, error) {
+		return nil, errors.New("decorator failed")
+	}))
+	err := g.Invoke(func(d *decorated) {})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "decorator failed")
+}
+
+func TestGraph_Decorate_invalid(t *testing.T) {
+	g := NewGraph()
+	for _, fn := range []interface{}{
+		"foo",
+		func() *decorated { return nil },
+		func(d *decorated) string { return "" },
+		func(d *decorated) (*decorated, string) { return nil, "" },
+		func(d *decorated, opts ...string) *decorated { return nil },
+	} {
+		assert.Error(t, g.Decorate(fn))
+	}
+}
+
+func TestGraph_Decorate_scope(t *testing.T) {
+	g := NewGraph()
+	assert.NoError(t, g.Provide(func() string { return "parent" }))
+	assert.NoError(t, g.Provide(func(s string) *decorated { return &decorated{trace: []string{s}} }))
+	assert.NoError(t, g.Decorate(func(d *decorated) *decorated {
+		d.trace = append(d.trace, "decorated")
+		return d
+	}))
+
+	child, err := g.Scope(func() string { return "child" })
+	assert.NoError(t, err)
+	assert.NoError(t, child.Invoke(func(d *decorated) {
+		assert.Equal(t, []string{"child", "decorated"}, d.trace)
+	}))
+	assert.NoError(t, g.Invoke(func(d *decorated) {
+		assert.Equal(t, []string{"parent", "decorated"}, d.trace)
+	}))
+
+	child, err = g.Scope()
+	assert.NoError(t, err)
+	assert.Error(t, child.Decorate(func(d *decorated) *decorated { return d }))
+}
@@ -11,11 +11,18 @@ import (
 // Graph is a wrapper around dig.
 type Graph struct {
 	dig *dig.Container
+	// shadow validates the constructors as they are provided, while the
+	// constructors are only handed to dig before the next Invoke, so that they
+	// can be decorated in the meantime.
+	shadow     *dig.Container
+	entries    []entry
+	flushed    int
+	decorators []decorator
 }
 
 // NewGraph creates a graph
 func NewGraph() *Graph {
-	return &Graph{dig: dig.New()}
+	return &Graph{dig: dig.New(), shadow: dig.New()}
 }
 
 // Provide teaches the container how to build values of one or more types and
@@ -32,7 +39,36 @@ func NewGraph() *Graph {
 // that specify dependencies as di.In structs and/or specify results as di.Out
 // structs.
 func (g *Graph) Provide(constructor interface{}) error {
-	return g.dig.Provide(constructor)
+	desc, _ := Describe(constructor)
+	return g.add(entry{constructor: constructor, desc: desc})
+}
+
+func (g *Graph) add(e entry) error {
+	provided := e.constructor
+	if e.shared != nil {
+		provided = e.shared
+	}
+	if err := g.shadow.Provide(provided); err != nil {
+		return err
+	}
+	g.entries = append(g.entries, e)
+	return nil
+}
+
+// flush hands the pending constructors to dig, decorated.
+func (g *Graph) flush() error {
+	for ; g.flushed < len(g.entries); g.flushed++ {
+		e := g.entries[g.flushed]
+		provided := e.shared
+		if provided == nil {
+			provided = g.decorate(e)
+		}
+		if err := g.dig.Provide(provided); err != nil {
+			g.flushed++
+			return err
+		}
+	}
+	return nil
 }
 
 // Invoke runs the given function after instantiating its dependencies. Any
@@ -41,10 +77,14 @@ func (g *Graph) Provide(constructor interface{}) error {
 // dependencies that they might have. The function may return an error to
 // indicate failure. The error will be returned to the caller as-is.
 func (g *Graph) Invoke(function interface{}) error {
+	if err := g.flush(); err != nil {
+		return err
+	}
 	return g.dig.Invoke(function)
 }
 
 // String representation of the entire Container
 func (g *Graph) String() string {
+	_ = g.flush()
 	return g.dig.String()
 }
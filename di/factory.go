@@ -2,6 +2,8 @@ package di
 
 import (
 	"context"
+	"fmt"
+	"sort"
 	"sync"
 
 	"golang.org/x/sync/singleflight"
@@ -22,13 +24,31 @@ type Factory struct {
 	cache       sync.Map
 	constructor func(name string) (Pair, error)
 	reloadOnce  sync.Once
+	configKind  string
+}
+
+// FactoryOption is the functional option type for NewFactory.
+type FactoryOption func(f *Factory)
+
+// WithConfigKind tells the factory that the connection under name is
+// configured at "<kind>.<name>", for example "gorm.default". With the kind
+// known, the factory only closes the connections whose configuration has
+// actually changed on reload, instead of closing all of them.
+func WithConfigKind(kind string) FactoryOption {
+	return func(f *Factory) {
+		f.configKind = kind
+	}
 }
 
 // NewFactory creates a new factory.
-func NewFactory(constructor func(name string) (Pair, error)) *Factory {
-	return &Factory{
+func NewFactory(constructor func(name string) (Pair, error), opts ...FactoryOption) *Factory {
+	f := &Factory{
 		constructor: constructor,
 	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
 }
 
 // Make creates an instance under the provided name. It an instance is already
@@ -53,15 +73,77 @@ func (f *Factory) Make(name string) (interface{}, error) {
 	return conn, nil
 }
 
+// Preload makes the connections of every name configured under the kind of the
+// factory, if the startup policy of conf says so. Otherwise, connections are
+// made lazily on the first call to Make, which is the default. The policy is a
+// list of kinds at "startup.eager", where "*" stands for all kinds:
+//
+//  startup:
+//    eager: [gorm, kafka.writer]
+//
+// Preload returns the first error, so that a broken connection fails the
+// startup rather than the first request. It does nothing if the factory is
+// created without WithConfigKind. Package core calls Preload on the factories
+// provided to the "factories" group when the serve command starts, even if
+// nothing has injected them yet:
+//
+//  type factoryOut struct {
+//    di.Out
+//    Factories []*di.Factory `group:"factories,flatten"`
+//  }
+func (f *Factory) Preload(conf contract.ConfigUnmarshaler) error {
+	if f.configKind == "" || !IsEager(conf, f.configKind) {
+		return nil
+	}
+	var configs map[string]interface{}
+	if err := conf.Unmarshal(f.configKind, &configs); err != nil {
+		return fmt.Errorf("failed to preload %s: %w", f.configKind, err)
+	}
+	names := make([]string, 0, len(configs))
+	for name := range configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, err := f.Make(name); err != nil {
+			return fmt.Errorf("failed to preload %s.%s: %w", f.configKind, name, err)
+		}
+	}
+	return nil
+}
+
+// IsEager reports whether the startup policy of conf, at "startup.eager", asks
+// for the kind to be constructed eagerly. See Factory.Preload.
+func IsEager(conf contract.ConfigUnmarshaler, kind string) bool {
+	var eager []string
+	if err := conf.Unmarshal("startup.eager", &eager); err != nil {
+		return false
+	}
+	for _, k := range eager {
+		if k == kind || k == "*" {
+			return true
+		}
+	}
+	return false
+}
+
 // SubscribeReloadEventFrom subscribes to the reload events from dispatcher and then notifies the di
-// factory to clear its cache and shutdown all connections gracefully.
+// factory to clear its cache and shutdown connections gracefully. If the factory
+// is created with WithConfigKind and the event carries a diff, only the
+// connections with changed configuration are closed. Otherwise, all
+// connections are closed.
 func (f *Factory) SubscribeReloadEventFrom(dispatcher contract.Dispatcher) {
 	if dispatcher == nil {
 		return
 	}
 	f.reloadOnce.Do(func() {
 		dispatcher.Subscribe(events.Listen(events.OnReload, func(ctx context.Context, event interface{}) error {
-			f.Close()
+			payload, ok := event.(events.OnReloadPayload)
+			if !ok || payload.Diff == nil || f.configKind == "" {
+				f.Close()
+				return nil
+			}
+			f.closeChanged(payload.Diff)
 			return nil
 		}))
 	})
@@ -97,6 +179,28 @@ func (f *Factory) Close() {
 	wg.Wait()
 }
 
+// closeChanged closes the connections whose configuration has changed
+// according to the diff. Connections are closed concurrently.
+func (f *Factory) closeChanged(diff *events.ConfigDiff) {
+	var wg sync.WaitGroup
+	f.cache.Range(func(key, value interface{}) bool {
+		if !diff.HasChanged(f.configKind + "." + key.(string)) {
+			return true
+		}
+		f.cache.Delete(key)
+		if value.(Pair).Closer == nil {
+			return true
+		}
+		wg.Add(1)
+		go func(value Pair) {
+			value.Closer()
+			wg.Done()
+		}(value.(Pair))
+		return true
+	})
+	wg.Wait()
+}
+
 // CloseConn closes a specific connection in the factory.
 func (f *Factory) CloseConn(name string) {
 	if value, loaded := f.cache.LoadAndDelete(name); loaded {
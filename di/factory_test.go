@@ -141,3 +141,80 @@ func randomString(l uint) string {
 	}
 	return string(s)
 }
+
+func TestFactory_WatchWithDiff(t *testing.T) {
+	t.Parallel()
+
+	var closed []string
+	f := NewFactory(func(name string) (Pair, error) {
+		return Pair{
+			Conn: name,
+			Closer: func() {
+				closed = append(closed, name)
+			},
+		}, nil
+	}, WithConfigKind("gorm"))
+	dispatcher := events.SyncDispatcher{}
+	f.SubscribeReloadEventFrom(&dispatcher)
+
+	f.Make("default")
+	f.Make("other")
+
+	_ = dispatcher.Dispatch(context.Background(), events.OnReload, events.OnReloadPayload{
+		Diff: &events.ConfigDiff{Modified: []string{"gorm.default.dsn", "redis.other.addrs"}},
+	})
+	assert.Equal(t, []string{"default"}, closed)
+	assert.Len(t, f.List(), 1)
+
+	_ = dispatcher.Dispatch(context.Background(), events.OnReload, events.OnReloadPayload{})
+	assert.ElementsMatch(t, []string{"default", "other"}, closed)
+	assert.Len(t, f.List(), 0)
+}
+
+type mapConf map[string]interface{}
+
+func (m mapConf) Unmarshal(path string, o interface{}) error {
+	v, ok := m[path]
+	if !ok {
+		return nil
+	}
+	switch o := o.(type) {
+	case *[]string:
+		*o = v.([]string)
+	case *map[string]interface{}:
+		*o = v.(map[string]interface{})
+	}
+	return nil
+}
+
+func TestFactory_Preload(t *testing.T) {
+	t.Parallel()
+	var made []string
+	newFactory := func() *Factory {
+		return NewFactory(func(name string) (Pair, error) {
+			if name == "broken" {
+				return Pair{}, errors.New("broken")
+			}
+			made = append(made, name)
+			return Pair{Conn: name}, nil
+		}, WithConfigKind("db"))
+	}
+	conf := mapConf{"db": map[string]interface{}{"foo": nil, "bar": nil}}
+
+	assert.NoError(t, newFactory().Preload(conf))
+	assert.Empty(t, made)
+
+	conf["startup.eager"] = []string{"db"}
+	f := newFactory()
+	assert.NoError(t, f.Preload(conf))
+	assert.Equal(t, []string{"bar", "foo"}, made)
+	assert.Len(t, f.List(), 2)
+
+	conf["startup.eager"] = []string{"*"}
+	conf["db"] = map[string]interface{}{"broken": nil}
+	assert.Error(t, newFactory().Preload(conf))
+
+	assert.NoError(t, NewFactory(func(name string) (Pair, error) {
+		return Pair{}, errors.New("not preloaded")
+	}).Preload(conf))
+}
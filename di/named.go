@@ -0,0 +1,139 @@
+package di
+
+import (
+	"fmt"
+	"reflect"
+
+	"go.uber.org/dig"
+)
+
+var _stringType = reflect.TypeOf("")
+
+// Name wraps the constructor so that its results are provided under the given
+// name, as if they were fields tagged with `name:"<name>"` in a di.Out struct.
+// This allows several instances of the same type to coexist:
+//
+//  c.Provide(di.Deps{
+//    di.Name("reporting", newReportingDB),
+//  })
+//
+//  type repositoryIn struct {
+//    di.In
+//    DB *gorm.DB `name:"reporting"`
+//  }
+//
+// Results of type error and func() are kept as is. The constructor must not
+// return di.Out structs.
+func Name(name string, constructor interface{}) interface{} {
+	ftype := reflect.TypeOf(constructor)
+	if ftype == nil || ftype.Kind() != reflect.Func {
+		panic(fmt.Sprintf("must provide constructor function, got %v (type %v)", constructor, ftype))
+	}
+
+	var (
+		fields   = []reflect.StructField{{Name: "Out", Type: _outType, Anonymous: true}}
+		indexes  []int
+		outTypes []reflect.Type
+		passed   []int
+	)
+	for i := 0; i < ftype.NumOut(); i++ {
+		out := ftype.Out(i)
+		if out == _errType || out == _cleanupType {
+			passed = append(passed, i)
+			continue
+		}
+		if dig.IsOut(out) {
+			panic(fmt.Sprintf("can't name the di.Out struct %v, tag its fields instead", out))
+		}
+		indexes = append(indexes, i)
+		fields = append(fields, reflect.StructField{
+			Name: fmt.Sprintf("Value%d", i),
+			Type: out,
+			Tag:  reflect.StructTag(fmt.Sprintf(`name:"%s"`, name)),
+		})
+	}
+	outType := reflect.StructOf(fields)
+	outTypes = append(outTypes, outType)
+	for _, i := range passed {
+		outTypes = append(outTypes, ftype.Out(i))
+	}
+
+	inTypes := make([]reflect.Type, ftype.NumIn())
+	for i := range inTypes {
+		inTypes[i] = ftype.In(i)
+	}
+
+	fnType := reflect.FuncOf(inTypes, outTypes, ftype.IsVariadic())
+	fn := reflect.MakeFunc(fnType, func(args []reflect.Value) []reflect.Value {
+		results := call(reflect.ValueOf(constructor), args)
+		out := reflect.New(outType).Elem()
+		for j, i := range indexes {
+			out.Field(j + 1).Set(results[i])
+		}
+		values := []reflect.Value{out}
+		for _, i := range passed {
+			values = append(values, results[i])
+		}
+		return values
+	})
+	return fn.Interface()
+}
+
+// NamedInstances turns a function in the form of
+//
+//  func(deps..., name string) (T, error)
+//
+// into constructors that provide one T for each name, under that name. It is
+// typically used to publish the connections of a Maker:
+//
+//  c.Provide(di.NamedInstances(func(maker otredis.Maker, name string) (redis.UniversalClient, error) {
+//    return maker.Make(name)
+//  }, "default", "cache"))
+//
+// There is one constructor per name, so an instance is only created when it is
+// needed, and a broken one doesn't fail the others. The names are fixed when
+// the constructors are provided.
+func NamedInstances(fn interface{}, names ...string) Deps {
+	ftype := reflect.TypeOf(fn)
+	if ftype == nil || ftype.Kind() != reflect.Func || ftype.IsVariadic() ||
+		ftype.NumIn() == 0 || ftype.In(ftype.NumIn()-1) != _stringType ||
+		ftype.NumOut() != 2 || ftype.Out(1) != _errType {
+		panic(fmt.Sprintf("must provide a function in the form of func(deps..., name string) (T, error), got %v", ftype))
+	}
+	if len(names) == 0 {
+		panic("at least one name is required")
+	}
+
+	inTypes := make([]reflect.Type, ftype.NumIn()-1)
+	for i := range inTypes {
+		inTypes[i] = ftype.In(i)
+	}
+
+	deps := make(Deps, 0, len(names))
+	for _, name := range names {
+		name := name
+		outType := reflect.StructOf([]reflect.StructField{
+			{Name: "Out", Type: _outType, Anonymous: true},
+			{Name: "Value", Type: ftype.Out(0), Tag: reflect.StructTag(fmt.Sprintf(`name:"%s"`, name))},
+		})
+		fnType := reflect.FuncOf(inTypes, []reflect.Type{outType, _errType}, false)
+		constructor := reflect.MakeFunc(fnType, func(args []reflect.Value) []reflect.Value {
+			out := reflect.New(outType).Elem()
+			results := reflect.ValueOf(fn).Call(append(args[:len(args):len(args)], reflect.ValueOf(name)))
+			if !results[1].IsNil() {
+				return []reflect.Value{out, results[1]}
+			}
+			out.Field(1).Set(results[0])
+			return []reflect.Value{out, reflect.Zero(_errType)}
+		})
+		deps = append(deps, constructor.Interface())
+	}
+	return deps
+}
+
+func call(fn reflect.Value, args []reflect.Value) []reflect.Value {
+	if fn.Type().IsVariadic() {
+		return fn.CallSlice(args)
+	}
+	return fn.Call(args)
+}
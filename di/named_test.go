@@ -0,0 +1,95 @@
+package di
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type namedDB struct {
+	name string
+}
+
+func TestName(t *testing.T) {
+	var cleaned bool
+	g := NewGraph()
+	assert.NoError(t, g.Provide(func() *namedDB { return &namedDB{name: "default"} }))
+	assert.NoError(t, g.Provide(Name("reporting", func() (*namedDB, func(), error) {
+		return &namedDB{name: "reporting"}, func() { cleaned = true }, nil
+	})))
+	assert.NoError(t, g.Provide(Name("broken", func() (string, error) {
+		return "", errors.New("broken")
+	})))
+
+	err := g.Invoke(func(in struct {
+		In
+
+		Default   *namedDB
+		Reporting *namedDB `name:"reporting"`
+	}) {
+		assert.Equal(t, "default", in.Default.name)
+		assert.Equal(t, "reporting", in.Reporting.name)
+	})
+	assert.NoError(t, err)
+	assert.False(t, cleaned)
+
+	err = g.Invoke(func(in struct {
+		In
+
+		Broken string `name:"broken"`
+	}) {
+	})
+	assert.Error(t, err)
+
+	assert.Panics(t, func() { Name("foo", "bar") })
+	assert.Panics(t, func() { Name("foo", func() planOut { return planOut{} }) })
+}
+
+func TestNamedInstances(t *testing.T) {
+	var calls []string
+	g := NewGraph()
+	assert.NoError(t, g.Provide(func() string { return "prefix:" }))
+	for _, constructor := range NamedInstances(func(prefix string, name string) (*namedDB, error) {
+		calls = append(calls, name)
+		if name == "broken" {
+			return nil, errors.New("no such database")
+		}
+		return &namedDB{name: prefix + name}, nil
+	}, "default", "reporting", "broken") {
+		assert.NoError(t, g.Provide(constructor))
+	}
+
+	// Only the requested instance is created, and the broken one doesn't
+	// affect it.
+	err := g.Invoke(func(in struct {
+		In
+
+		Reporting *namedDB `name:"reporting"`
+	}) {
+		assert.Equal(t, "prefix:reporting", in.Reporting.name)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"reporting"}, calls)
+
+	err = g.Invoke(func(in struct {
+		In
+
+		Default *namedDB `name:"default"`
+	}) {
+		assert.Equal(t, "prefix:default", in.Default.name)
+	})
+	assert.NoError(t, err)
+
+	err = g.Invoke(func(in struct {
+		In
+
+		Broken *namedDB `name:"broken"`
+	}) {
+	})
+	assert.Error(t, err)
+	assert.Equal(t, []string{"reporting", "default", "broken"}, calls)
+
+	assert.Panics(t, func() { NamedInstances(func() (*namedDB, error) { return nil, nil }, "default") })
+	assert.Panics(t, func() { NamedInstances(func(name string) (*namedDB, error) { return nil, nil }) })
+}
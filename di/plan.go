@@ -0,0 +1,522 @@
+package di
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"go.uber.org/dig"
+)
+
+// Dependency is an input or an output of a constructor, as dig sees it.
+type Dependency struct {
+	// Type is the declared type. For group inputs, it is the slice type.
+	Type reflect.Type
+	// Name is the value of the name tag, if any.
+	Name string
+	// Group is the value of the group tag without the flatten flag, if any.
+	Group string
+	// Optional is true for the inputs tagged optional, and for variadic params.
+	Optional bool
+	// Flatten is true for group outputs tagged flatten.
+	Flatten bool
+}
+
+// String returns the type along with its tags, such as `*redis.Client name:"default"`.
+func (d Dependency) String() string {
+	var b strings.Builder
+	b.WriteString(d.Type.String())
+	if d.Name != "" {
+		fmt.Fprintf(&b, " name:%q", d.Name)
+	}
+	if d.Group != "" {
+		group := d.Group
+		if d.Flatten {
+			group += ",flatten"
+		}
+		fmt.Fprintf(&b, " group:%q", group)
+	}
+	if d.Optional {
+		b.WriteString(` optional:"true"`)
+	}
+	return b.String()
+}
+
+// inputKey and outputKey identify the value in the container, so that the
+// inputs and outputs referring to the same value share the same key.
+func inputKey(d Dependency) string {
+	if d.Group != "" && d.Type.Kind() == reflect.Slice {
+		return valueKey(d.Type.Elem(), "", d.Group)
+	}
+	return valueKey(d.Type, d.Name, d.Group)
+}
+
+func outputKey(d Dependency) string {
+	if d.Group != "" && d.Flatten && d.Type.Kind() == reflect.Slice {
+		return valueKey(d.Type.Elem(), "", d.Group)
+	}
+	return valueKey(d.Type, d.Name, d.Group)
+}
+
+func valueKey(t reflect.Type, name, group string) string {
+	switch {
+	case group != "":
+		return fmt.Sprintf("%s group:%q", t, group)
+	case name != "":
+		return fmt.Sprintf("%s name:%q", t, name)
+	default:
+		return t.String()
+	}
+}
+
+// Constructor describes a function in the graph.
+type Constructor struct {
+	// Name is the package qualified name of the function.
+	Name string
+	// Location is the file and line where the function is defined.
+	Location string
+	// Invoke is true if the function is invoked rather than provided.
+	Invoke bool
+	// Decorate is true if the function decorates a value rather than providing
+	// it. The decorated value is the first input.
+	Decorate bool
+	Inputs   []Dependency
+	Outputs  []Dependency
+}
+
+// MissingDependency is a required input that no constructor provides.
+type MissingDependency struct {
+	Dependency  Dependency
+	Constructor string
+}
+
+var (
+	_errType     = reflect.TypeOf((*error)(nil)).Elem()
+	_digInType   = reflect.TypeOf(dig.In{})
+	_digOutType  = reflect.TypeOf(dig.Out{})
+	_inType      = reflect.TypeOf(In{})
+	_outType     = reflect.TypeOf(Out{})
+	_cleanupType = reflect.TypeOf(func() {})
+)
+
+// Describe inspects the constructor without calling it. Results of type error
+// and func(), which package core treats as clean up functions, are not outputs.
+func Describe(constructor interface{}) (Constructor, error) {
+	ftype := reflect.TypeOf(constructor)
+	if ftype == nil || ftype.Kind() != reflect.Func {
+		return Constructor{}, fmt.Errorf("must provide constructor function, got %v (type %v)", constructor, ftype)
+	}
+	c := Constructor{}
+	if fn := runtime.FuncForPC(reflect.ValueOf(constructor).Pointer()); fn != nil {
+		c.Name = shortName(fn.Name())
+		file, line := fn.FileLine(fn.Entry())
+		c.Location = file + ":" + strconv.Itoa(line)
+	}
+	for i := 0; i < ftype.NumIn(); i++ {
+		optional := ftype.IsVariadic() && i == ftype.NumIn()-1
+		c.Inputs = append(c.Inputs, inputs(ftype.In(i), optional)...)
+	}
+	for i := 0; i < ftype.NumOut(); i++ {
+		out := ftype.Out(i)
+		if out == _errType || out == _cleanupType {
+			continue
+		}
+		c.Outputs = append(c.Outputs, outputs(out)...)
+	}
+	return c, nil
+}
+
+func inputs(t reflect.Type, optional bool) []Dependency {
+	if !dig.IsIn(t) {
+		return []Dependency{{Type: t, Optional: optional}}
+	}
+	var deps []Dependency
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Type == _digInType || f.Type == _inType || f.PkgPath != "" {
+			continue
+		}
+		if dig.IsIn(f.Type) {
+			deps = append(deps, inputs(f.Type, false)...)
+			continue
+		}
+		opt, _ := strconv.ParseBool(f.Tag.Get("optional"))
+		deps = append(deps, Dependency{
+			Type:     f.Type,
+			Name:     f.Tag.Get("name"),
+			Group:    f.Tag.Get("group"),
+			Optional: opt,
+		})
+	}
+	return deps
+}
+
+func outputs(t reflect.Type) []Dependency {
+	if !dig.IsOut(t) {
+		return []Dependency{{Type: t}}
+	}
+	var deps []Dependency
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Type == _digOutType || f.Type == _outType || f.PkgPath != "" {
+			continue
+		}
+		if dig.IsOut(f.Type) {
+			deps = append(deps, outputs(f.Type)...)
+			continue
+		}
+		group := strings.Split(f.Tag.Get("group"), ",")
+		deps = append(deps, Dependency{
+			Type:    f.Type,
+			Name:    f.Tag.Get("name"),
+			Group:   group[0],
+			Flatten: len(group) > 1 && group[1] == "flatten",
+		})
+	}
+	return deps
+}
+
+func shortName(name string) string {
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
+
+// Plan is a dry run of the dependency graph. It records the constructors and
+// their dependencies without calling them, so that the wiring can be reviewed
+// and checked before anything is built.
+type Plan struct {
+	constructors []Constructor
+}
+
+// NewPlan creates an empty *Plan.
+func NewPlan() *Plan {
+	return &Plan{}
+}
+
+// Provide records a constructor.
+func (p *Plan) Provide(constructor interface{}) error {
+	c, err := Describe(constructor)
+	if err != nil {
+		return err
+	}
+	p.constructors = append(p.constructors, c)
+	return nil
+}
+
+// Invoke records a function that is invoked. It only has inputs. A function
+// defined at the same location as a recorded one is only recorded once, so
+// that invoking in a loop doesn't grow the plan.
+func (p *Plan) Invoke(function interface{}) error {
+	c, err := Describe(function)
+	if err != nil {
+		return err
+	}
+	for _, recorded := range p.constructors {
+		if recorded.Invoke && recorded.Name == c.Name && recorded.Location == c.Location {
+			return nil
+		}
+	}
+	c.Invoke = true
+	c.Outputs = nil
+	p.constructors = append(p.constructors, c)
+	return nil
+}
+
+// Decorate records a decorator, see Graph.Decorate. It has no outputs, but its
+// dependencies count as dependencies of the constructor of the decorated value
+// when looking for cycles.
+func (p *Plan) Decorate(decorator interface{}) error {
+	c, err := Describe(decorator)
+	if err != nil {
+		return err
+	}
+	if len(c.Inputs) == 0 {
+		return fmt.Errorf("must provide decorator function in the form of func(T, deps...) T, got %T", decorator)
+	}
+	c.Decorate = true
+	c.Outputs = nil
+	p.constructors = append(p.constructors, c)
+	return nil
+}
+
+// Clone returns a copy of the plan.
+func (p *Plan) Clone() *Plan {
+	return &Plan{constructors: append([]Constructor(nil), p.constructors...)}
+}
+
+// Constructors returns the recorded constructors in order.
+func (p *Plan) Constructors() []Constructor {
+	return p.constructors
+}
+
+// providers maps the key of every value to the indexes of its constructors.
+func (p *Plan) providers() map[string][]int {
+	m := make(map[string][]int)
+	for i, c := range p.constructors {
+		for _, out := range c.Outputs {
+			key := outputKey(out)
+			m[key] = append(m[key], i)
+		}
+	}
+	return m
+}
+
+// Missing returns the required inputs that no constructor provides. Groups
+// are never missing, as an empty group is valid.
+func (p *Plan) Missing() []MissingDependency {
+	providers := p.providers()
+	var missing []MissingDependency
+	for _, c := range p.constructors {
+		for _, in := range c.Inputs {
+			if in.Optional || in.Group != "" {
+				continue
+			}
+			if _, ok := providers[inputKey(in)]; ok {
+				continue
+			}
+			missing = append(missing, MissingDependency{Dependency: in, Constructor: c.Name})
+		}
+	}
+	return missing
+}
+
+// Cycles returns the dependency cycles, each as the list of the constructors
+// involved, the first one repeated at the end.
+func (p *Plan) Cycles() [][]string {
+	providers := p.providers()
+	decorations := make(map[string][]Dependency)
+	for _, c := range p.constructors {
+		if c.Decorate {
+			key := inputKey(c.Inputs[0])
+			decorations[key] = append(decorations[key], c.Inputs[1:]...)
+		}
+	}
+	edges := make([][]int, len(p.constructors))
+	for i, c := range p.constructors {
+		seen := make(map[int]bool)
+		ins := c.Inputs
+		for _, out := range c.Outputs {
+			ins = append(ins[:len(ins):len(ins)], decorations[outputKey(out)]...)
+		}
+		for _, in := range ins {
+			for _, j := range providers[inputKey(in)] {
+				if !seen[j] {
+					seen[j] = true
+					edges[i] = append(edges[i], j)
+				}
+			}
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	var (
+		state  = make([]int, len(p.constructors))
+		stack  []int
+		cycles [][]string
+		visit  func(i int)
+	)
+	visit = func(i int) {
+		state[i] = visiting
+		stack = append(stack, i)
+		for _, j := range edges[i] {
+			switch state[j] {
+			case unvisited:
+				visit(j)
+			case visiting:
+				var cycle []string
+				for k := len(stack) - 1; k >= 0; k-- {
+					cycle = append([]string{p.constructors[stack[k]].Name}, cycle...)
+					if stack[k] == j {
+						break
+					}
+				}
+				cycles = append(cycles, append(cycle, p.constructors[j].Name))
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[i] = visited
+	}
+	for i := range p.constructors {
+		if state[i] == unvisited {
+			visit(i)
+		}
+	}
+	return cycles
+}
+
+// WriteText writes a human readable report of the plan, including the missing
+// dependencies and the cycles.
+func (p *Plan) WriteText(w io.Writer) error {
+	ew := &errWriter{w: w}
+	for _, c := range p.constructors {
+		kind := "provide"
+		if c.Invoke {
+			kind = "invoke"
+		}
+		if c.Decorate {
+			kind = "decorate"
+		}
+		ew.printf("%s %s\n", kind, c.Name)
+		if c.Location != "" {
+			ew.printf("  at %s\n", c.Location)
+		}
+		for _, in := range c.Inputs {
+			ew.printf("  in:  %s\n", in)
+		}
+		for _, out := range c.Outputs {
+			ew.printf("  out: %s\n", out)
+		}
+	}
+	if missing := p.Missing(); len(missing) > 0 {
+		ew.printf("\nmissing dependencies:\n")
+		for _, m := range missing {
+			ew.printf("  %s, required by %s\n", m.Dependency, m.Constructor)
+		}
+	}
+	if cycles := p.Cycles(); len(cycles) > 0 {
+		ew.printf("\ncycles:\n")
+		for _, cycle := range cycles {
+			ew.printf("  %s\n", strings.Join(cycle, " -> "))
+		}
+	}
+	return ew.err
+}
+
+// WriteDOT writes the plan in the DOT language of graphviz. Constructors are
+// boxes, values are ellipses, and missing values are red. Optional inputs are
+// dashed.
+func (p *Plan) WriteDOT(w io.Writer) error {
+	ew := &errWriter{w: w}
+	g := p.layout()
+	ew.printf("digraph {\n\trankdir=LR;\n")
+	for i, c := range p.constructors {
+		ew.printf("\tc%d [shape=box, label=%s];\n", i, strconv.Quote(c.Name))
+	}
+	for i, v := range g.values {
+		attrs := ""
+		if v.missing {
+			attrs = ", color=red, fontcolor=red"
+		}
+		ew.printf("\tv%d [shape=ellipse, label=%s%s];\n", i, strconv.Quote(v.label), attrs)
+	}
+	for _, e := range g.edges {
+		if e.output {
+			ew.printf("\tc%d -> v%d;\n", e.constructor, e.value)
+			continue
+		}
+		style := ""
+		if e.optional {
+			style = " [style=dashed]"
+		}
+		ew.printf("\tv%d -> c%d%s;\n", e.value, e.constructor, style)
+	}
+	ew.printf("}\n")
+	return ew.err
+}
+
+// WriteMermaid writes the plan as a mermaid flowchart, which renders inline in
+// pull requests. The conventions follow WriteDOT.
+func (p *Plan) WriteMermaid(w io.Writer) error {
+	ew := &errWriter{w: w}
+	g := p.layout()
+	ew.printf("graph LR\n")
+	for i, c := range p.constructors {
+		ew.printf("\tc%d[\"%s\"]\n", i, mermaidEscape(c.Name))
+	}
+	for i, v := range g.values {
+		ew.printf("\tv%d([\"%s\"])\n", i, mermaidEscape(v.label))
+	}
+	for _, e := range g.edges {
+		switch {
+		case e.output:
+			ew.printf("\tc%d --> v%d\n", e.constructor, e.value)
+		case e.optional:
+			ew.printf("\tv%d -.-> c%d\n", e.value, e.constructor)
+		default:
+			ew.printf("\tv%d --> c%d\n", e.value, e.constructor)
+		}
+	}
+	for i, v := range g.values {
+		if v.missing {
+			ew.printf("\tstyle v%d stroke:#f00,color:#f00\n", i)
+		}
+	}
+	return ew.err
+}
+
+type graphValue struct {
+	label   string
+	missing bool
+}
+
+type graphEdge struct {
+	constructor int
+	value       int
+	output      bool
+	optional    bool
+}
+
+type graphLayout struct {
+	values []graphValue
+	edges  []graphEdge
+}
+
+func (p *Plan) layout() graphLayout {
+	var (
+		g         graphLayout
+		index     = make(map[string]int)
+		providers = p.providers()
+	)
+	value := func(key string) int {
+		if i, ok := index[key]; ok {
+			return i
+		}
+		index[key] = len(g.values)
+		g.values = append(g.values, graphValue{label: key})
+		return index[key]
+	}
+	for i, c := range p.constructors {
+		for _, out := range c.Outputs {
+			g.edges = append(g.edges, graphEdge{constructor: i, value: value(outputKey(out)), output: true})
+		}
+		if c.Decorate {
+			g.edges = append(g.edges, graphEdge{constructor: i, value: value(inputKey(c.Inputs[0])), output: true})
+		}
+	}
+	for i, c := range p.constructors {
+		for _, in := range c.Inputs {
+			key := inputKey(in)
+			v := value(key)
+			if _, ok := providers[key]; !ok && !in.Optional && in.Group == "" {
+				g.values[v].missing = true
+			}
+			g.edges = append(g.edges, graphEdge{constructor: i, value: v, optional: in.Optional})
+		}
+	}
+	return g
+}
+
+func mermaidEscape(s string) string {
+	return strings.ReplaceAll(s, `"`, "#quot;")
+}
+
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (e *errWriter) printf(format string, args ...interface{}) {
+	if e.err != nil {
+		return
+	}
+	_, e.err = fmt.Fprintf(e.w, format, args...)
+}
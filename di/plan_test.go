@@ -0,0 +1,133 @@
+package di
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type planA struct{}
+type planB struct{}
+type planC struct{}
+
+type planIn struct {
+	In
+
+	A      planA   `name:"foo"`
+	B      *planB  `optional:"true"`
+	Groups []planC `group:"c"`
+}
+
+type planOut struct {
+	Out
+
+	A  planA   `name:"foo"`
+	Cs []planC `group:"c,flatten"`
+	C  planC   `group:"c"`
+}
+
+func TestDescribe(t *testing.T) {
+	c, err := Describe(func(in planIn, opts ...string) (planOut, func(), error) { return planOut{}, nil, nil })
+	assert.NoError(t, err)
+	assert.Contains(t, c.Name, "di.TestDescribe")
+	assert.Contains(t, c.Location, "plan_test.go")
+
+	var inputs, outputs []string
+	for _, in := range c.Inputs {
+		inputs = append(inputs, in.String())
+	}
+	for _, out := range c.Outputs {
+		outputs = append(outputs, out.String())
+	}
+	assert.Equal(t, []string{
+		`di.planA name:"foo"`,
+		`*di.planB optional:"true"`,
+		`[]di.planC group:"c"`,
+		`[]string optional:"true"`,
+	}, inputs)
+	assert.Equal(t, []string{
+		`di.planA name:"foo"`,
+		`[]di.planC group:"c,flatten"`,
+		`di.planC group:"c"`,
+	}, outputs)
+
+	_, err = Describe(planA{})
+	assert.Error(t, err)
+}
+
+func TestPlan_invokeOnce(t *testing.T) {
+	p := NewPlan()
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, p.Invoke(func(a planA) {}))
+	}
+	assert.NoError(t, p.Invoke(func(b planB) {}))
+	assert.Len(t, p.Constructors(), 2)
+}
+
+func TestPlan(t *testing.T) {
+	p := NewPlan()
+	assert.NoError(t, p.Provide(func(in planIn) (planOut, error) { return planOut{}, errors.New("not called") }))
+	assert.NoError(t, p.Invoke(func(a planA, b planB) {}))
+
+	missing := p.Missing()
+	assert.Len(t, missing, 2)
+	assert.Equal(t, `di.planA`, missing[0].Dependency.String())
+	assert.Equal(t, `di.planB`, missing[1].Dependency.String())
+
+	// planOut provides a named planA, which planIn consumes.
+	assert.Len(t, p.Cycles(), 1)
+
+	var buf bytes.Buffer
+	assert.NoError(t, p.WriteText(&buf))
+	assert.Contains(t, buf.String(), "missing dependencies:\n  di.planA, required by")
+	assert.Contains(t, buf.String(), "cycles:\n")
+
+	buf.Reset()
+	assert.NoError(t, p.WriteDOT(&buf))
+	assert.Contains(t, buf.String(), "digraph {")
+	assert.Contains(t, buf.String(), `label="di.planB", color=red`)
+	assert.Contains(t, buf.String(), "[style=dashed]")
+
+	buf.Reset()
+	assert.NoError(t, p.WriteMermaid(&buf))
+	assert.Contains(t, buf.String(), "graph LR\n")
+	assert.Contains(t, buf.String(), `di.planA name:#quot;foo#quot;`)
+	assert.Contains(t, buf.String(), "-.->")
+}
+
+func TestPlan_Cycles(t *testing.T) {
+	p := NewPlan()
+	p.Provide(func(planA) planB { return planB{} })
+	p.Provide(func(planB) planC { return planC{} })
+	p.Provide(func(planC) planA { return planA{} })
+	p.Provide(func() string { return "" })
+
+	cycles := p.Cycles()
+	assert.Len(t, cycles, 1)
+	assert.Len(t, cycles[0], 4)
+	assert.Equal(t, cycles[0][0], cycles[0][3])
+	assert.Empty(t, p.Missing())
+}
+
+func TestPlan_Decorate(t *testing.T) {
+	p := NewPlan()
+	p.Provide(func() planA { return planA{} })
+	p.Provide(func(planA) planB { return planB{} })
+	p.Decorate(func(a planA, c planC) planA { return a })
+
+	missing := p.Missing()
+	assert.Len(t, missing, 1)
+	assert.Equal(t, "di.planC", missing[0].Dependency.String())
+	assert.Empty(t, p.Cycles())
+
+	var buf bytes.Buffer
+	assert.NoError(t, p.WriteText(&buf))
+	assert.Contains(t, buf.String(), "decorate di.TestPlan_Decorate")
+
+	// The decorator depends on planB, which depends on the decorated planA.
+	p.Decorate(func(a planA, b planB) planA { return a })
+	assert.Len(t, p.Cycles(), 1)
+	assert.Error(t, p.Decorate(func() {}))
+}
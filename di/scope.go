@@ -0,0 +1,275 @@
+package di
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go.uber.org/dig"
+)
+
+// entry is a constructor provided to a Graph.
+type entry struct {
+	constructor interface{}
+	desc        Constructor
+	// shared, if set, is provided instead of the constructor. It fetches the
+	// values from the parent graph, where they are already decorated.
+	shared interface{}
+}
+
+// Scope creates a child graph, for example to swap a dependency in tests or to
+// wire a tenant differently. The child sees everything provided to g, except
+// that the overrides take the place of the constructors providing the same
+// values.
+//
+// Values are shared with g, unless they depend on an override, directly or
+// not, in which case they are constructed again in the child. Values provided
+// to the child afterwards are only visible to the child.
+//
+// If a constructor provides several values and only some of them are
+// overridden, the child keeps it for the others. Value groups are never
+// replaced, the overrides add to them, unless every other value of the
+// constructor is overridden, in which case the constructor is dropped along
+// with its contributions to the groups.
+func (g *Graph) Scope(overrides ...interface{}) (*Graph, error) {
+	child := NewGraph()
+	child.decorators = append(child.decorators, g.decorators...)
+
+	replaced := make(map[string]bool)
+	tainted := make(map[string]bool)
+	for _, override := range overrides {
+		if err := child.Provide(override); err != nil {
+			return nil, err
+		}
+		for _, out := range child.entries[len(child.entries)-1].desc.Outputs {
+			if out.Group == "" {
+				replaced[outputKey(out)] = true
+			}
+			tainted[outputKey(out)] = true
+		}
+	}
+
+	// Find the constructors that are replaced by the overrides, then the ones
+	// that depend on the overrides.
+	var (
+		kept   []entry
+		redone = make(map[int]bool)
+	)
+	for _, e := range g.entries {
+		if !overlaps(e.desc, replaced) {
+			kept = append(kept, e)
+			continue
+		}
+		if restricted, ok := restrict(e, replaced); ok {
+			kept = append(kept, restricted)
+		}
+	}
+	for changed := true; changed; {
+		changed = false
+		for i, e := range kept {
+			if redone[i] || !dependsOn(e.desc, tainted) {
+				continue
+			}
+			redone[i] = true
+			changed = true
+			for _, out := range e.desc.Outputs {
+				tainted[outputKey(out)] = true
+			}
+		}
+	}
+
+	for i, e := range kept {
+		e.shared = nil
+		if forwarder, ok := g.forwarder(e); ok && !redone[i] {
+			e.shared = forwarder
+		}
+		if err := child.add(e); err != nil {
+			return nil, err
+		}
+	}
+	return child, nil
+}
+
+// restrict returns an entry whose constructor calls the one of e, but only
+// provides the values not in dropped. It returns false if no value other than
+// the value groups is left.
+func restrict(e entry, dropped map[string]bool) (entry, bool) {
+	ftype := reflect.TypeOf(e.constructor)
+
+	var (
+		fields   = []reflect.StructField{{Name: "Out", Type: _outType, Anonymous: true}}
+		paths    [][]int
+		passed   []int
+		hasValue bool
+	)
+	var collect func(t reflect.Type, path []int, tag reflect.StructTag)
+	collect = func(t reflect.Type, path []int, tag reflect.StructTag) {
+		if !dig.IsOut(t) {
+			group := strings.Split(tag.Get("group"), ",")
+			dep := Dependency{Type: t, Name: tag.Get("name"), Group: group[0], Flatten: len(group) > 1 && group[1] == "flatten"}
+			if dep.Group == "" {
+				if dropped[outputKey(dep)] {
+					return
+				}
+				hasValue = true
+			}
+			fields = append(fields, reflect.StructField{Name: fmt.Sprintf("Value%d", len(fields)), Type: t, Tag: tag})
+			paths = append(paths, path)
+			return
+		}
+		for j := 0; j < t.NumField(); j++ {
+			f := t.Field(j)
+			if f.Type == _outType || f.Type == _digOutType || f.PkgPath != "" {
+				continue
+			}
+			collect(f.Type, append(path[:len(path):len(path)], j), f.Tag)
+		}
+	}
+	for i := 0; i < ftype.NumOut(); i++ {
+		out := ftype.Out(i)
+		if out == _errType || out == _cleanupType {
+			passed = append(passed, i)
+			continue
+		}
+		collect(out, []int{i}, "")
+	}
+	if !hasValue {
+		return entry{}, false
+	}
+
+	outType := reflect.StructOf(fields)
+	outTypes := []reflect.Type{outType}
+	for _, i := range passed {
+		outTypes = append(outTypes, ftype.Out(i))
+	}
+	inTypes := make([]reflect.Type, ftype.NumIn())
+	for i := range inTypes {
+		inTypes[i] = ftype.In(i)
+	}
+
+	fnType := reflect.FuncOf(inTypes, outTypes, ftype.IsVariadic())
+	fn := reflect.MakeFunc(fnType, func(args []reflect.Value) []reflect.Value {
+		results := call(reflect.ValueOf(e.constructor), args)
+		out := reflect.New(outType).Elem()
+		for j, path := range paths {
+			out.Field(j + 1).Set(results[path[0]].FieldByIndex(path[1:]))
+		}
+		values := []reflect.Value{out}
+		for _, i := range passed {
+			values = append(values, results[i])
+		}
+		return values
+	}).Interface()
+
+	desc, _ := Describe(fn)
+	desc.Name, desc.Location = e.desc.Name, e.desc.Location
+	return entry{constructor: fn, desc: desc}, true
+}
+
+func overlaps(c Constructor, keys map[string]bool) bool {
+	for _, out := range c.Outputs {
+		if keys[outputKey(out)] {
+			return true
+		}
+	}
+	return false
+}
+
+func dependsOn(c Constructor, keys map[string]bool) bool {
+	for _, in := range c.Inputs {
+		if keys[inputKey(in)] {
+			return true
+		}
+	}
+	return false
+}
+
+// forwarder returns a constructor that fetches the results of e from g, so that
+// the child shares the instances of g. Constructors contributing to value
+// groups can't be forwarded, as g only hands out whole groups.
+func (g *Graph) forwarder(e entry) (interface{}, bool) {
+	ftype := reflect.TypeOf(e.constructor)
+
+	var (
+		fields   = []reflect.StructField{{Name: "In", Type: _inType, Anonymous: true}}
+		outTypes []reflect.Type
+		targets  [][]int
+	)
+	addField := func(t reflect.Type, name string) int {
+		tag := ""
+		if name != "" {
+			tag = fmt.Sprintf(`name:"%s"`, name)
+		}
+		fields = append(fields, reflect.StructField{
+			Name: fmt.Sprintf("Value%d", len(fields)),
+			Type: t,
+			Tag:  reflect.StructTag(tag),
+		})
+		return len(fields) - 1
+	}
+
+	for i := 0; i < ftype.NumOut(); i++ {
+		out := ftype.Out(i)
+		if out == _errType || out == _cleanupType {
+			continue
+		}
+		outTypes = append(outTypes, out)
+		if !dig.IsOut(out) {
+			targets = append(targets, []int{addField(out, "")})
+			continue
+		}
+		// For a di.Out struct, the n-th entry of the target is the field of
+		// the In struct holding the n-th field of the Out struct, or -1.
+		var fieldTargets []int
+		for j := 0; j < out.NumField(); j++ {
+			f := out.Field(j)
+			if f.Type == _outType || f.Type == _digOutType {
+				fieldTargets = append(fieldTargets, -1)
+				continue
+			}
+			if f.PkgPath != "" || f.Tag.Get("group") != "" || dig.IsOut(f.Type) {
+				return nil, false
+			}
+			fieldTargets = append(fieldTargets, addField(f.Type, f.Tag.Get("name")))
+		}
+		targets = append(targets, fieldTargets)
+	}
+	outTypes = append(outTypes, _errType)
+	inType := reflect.StructOf(fields)
+
+	fnType := reflect.FuncOf(nil, outTypes, false)
+	fn := reflect.MakeFunc(fnType, func([]reflect.Value) []reflect.Value {
+		var in reflect.Value
+		capture := reflect.MakeFunc(
+			reflect.FuncOf([]reflect.Type{inType}, nil, false),
+			func(args []reflect.Value) []reflect.Value {
+				in = args[0]
+				return nil
+			},
+		)
+		results := make([]reflect.Value, len(outTypes))
+		for i, t := range outTypes[:len(outTypes)-1] {
+			results[i] = reflect.Zero(t)
+		}
+		if err := g.Invoke(capture.Interface()); err != nil {
+			results[len(results)-1] = reflect.ValueOf(&err).Elem()
+			return results
+		}
+		for i, t := range outTypes[:len(outTypes)-1] {
+			if !dig.IsOut(t) {
+				results[i] = in.Field(targets[i][0])
+				continue
+			}
+			out := reflect.New(t).Elem()
+			for j, field := range targets[i] {
+				if field >= 0 {
+					out.Field(j).Set(in.Field(field))
+				}
+			}
+			results[i] = out
+		}
+		results[len(results)-1] = reflect.Zero(_errType)
+		return results
+	})
+	return fn.Interface(), true
+}
@@ -0,0 +1,139 @@
+package di
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type scopeConf struct {
+	tenant string
+}
+
+type scopeClient struct {
+	conf scopeConf
+}
+
+type scopeLogger struct{}
+
+type scopeService struct {
+	client *scopeClient
+	logger *scopeLogger
+}
+
+type scopeOut struct {
+	Out
+
+	Logger *scopeLogger
+	Name   string `group:"names"`
+}
+
+func newScopeGraph(t *testing.T) *Graph {
+	g := NewGraph()
+	assert.NoError(t, g.Provide(func() scopeConf { return scopeConf{tenant: "default"} }))
+	assert.NoError(t, g.Provide(func(conf scopeConf) *scopeClient { return &scopeClient{conf: conf} }))
+	assert.NoError(t, g.Provide(func() *scopeLogger { return &scopeLogger{} }))
+	assert.NoError(t, g.Provide(func(client *scopeClient, logger *scopeLogger) *scopeService {
+		return &scopeService{client: client, logger: logger}
+	}))
+	return g
+}
+
+func TestGraph_Scope(t *testing.T) {
+	g := newScopeGraph(t)
+
+	var parent *scopeService
+	assert.NoError(t, g.Invoke(func(s *scopeService) { parent = s }))
+
+	child, err := g.Scope(func() scopeConf { return scopeConf{tenant: "foo"} })
+	assert.NoError(t, err)
+
+	var scoped *scopeService
+	assert.NoError(t, child.Invoke(func(s *scopeService) { scoped = s }))
+	assert.Equal(t, "foo", scoped.client.conf.tenant)
+	assert.NotSame(t, parent, scoped)
+	assert.NotSame(t, parent.client, scoped.client)
+	assert.Same(t, parent.logger, scoped.logger)
+
+	assert.NoError(t, g.Invoke(func(s *scopeService) {
+		assert.Same(t, parent, s)
+		assert.Equal(t, "default", s.client.conf.tenant)
+	}))
+}
+
+func TestGraph_Scope_noOverride(t *testing.T) {
+	g := newScopeGraph(t)
+	child, err := g.Scope()
+	assert.NoError(t, err)
+
+	assert.NoError(t, child.Invoke(func(s *scopeService) {
+		assert.NoError(t, g.Invoke(func(p *scopeService) {
+			assert.Same(t, p, s)
+		}))
+	}))
+
+	// Values provided to the child are not visible to the parent.
+	assert.NoError(t, child.Provide(func() string { return "child" }))
+	assert.NoError(t, child.Invoke(func(s string) { assert.Equal(t, "child", s) }))
+	assert.Error(t, g.Invoke(func(s string) {}))
+}
+
+func TestGraph_Scope_groups(t *testing.T) {
+	g := NewGraph()
+	assert.NoError(t, g.Provide(func() scopeOut {
+		return scopeOut{Logger: &scopeLogger{}, Name: "parent"}
+	}))
+
+	child, err := g.Scope(func() scopeOut {
+		return scopeOut{Logger: &scopeLogger{}, Name: "child"}
+	})
+	assert.NoError(t, err)
+
+	assert.NoError(t, child.Invoke(func(in struct {
+		In
+
+		Names []string `group:"names"`
+	}) {
+		assert.Equal(t, []string{"child"}, in.Names)
+	}))
+	assert.NoError(t, g.Invoke(func(in struct {
+		In
+
+		Names []string `group:"names"`
+	}) {
+		assert.Equal(t, []string{"parent"}, in.Names)
+	}))
+}
+
+type scopeMultiOut struct {
+	Out
+
+	Conf   scopeConf
+	Logger *scopeLogger
+	Name   string `group:"names"`
+}
+
+func TestGraph_Scope_partial(t *testing.T) {
+	g := NewGraph()
+	assert.NoError(t, g.Provide(func() scopeMultiOut {
+		return scopeMultiOut{Conf: scopeConf{tenant: "default"}, Logger: &scopeLogger{}, Name: "parent"}
+	}))
+
+	child, err := g.Scope(func() scopeConf { return scopeConf{tenant: "foo"} })
+	assert.NoError(t, err)
+
+	assert.NoError(t, child.Invoke(func(in struct {
+		In
+
+		Conf   scopeConf
+		Logger *scopeLogger
+		Names  []string `group:"names"`
+	}) {
+		assert.Equal(t, "foo", in.Conf.tenant)
+		assert.NotNil(t, in.Logger)
+		assert.Equal(t, []string{"parent"}, in.Names)
+	}))
+	assert.NoError(t, g.Invoke(func(conf scopeConf) {
+		assert.Equal(t, "default", conf.tenant)
+	}))
+}
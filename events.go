@@ -31,6 +31,11 @@ const (
 	// database and other infrastructures are not closed yet. This event is useful
 	// to unregister service to service discovery.
 	OnGRPCServerShutdown event = "onGRPCServerShutdown"
+
+	// OnStartupReport is an event triggered by the serve command before the
+	// servers start. It carries the execution time of the constructors called
+	// so far. This event is useful to diagnose slow boots.
+	OnStartupReport event = "onStartupReport"
 )
 
 // OnHTTPServerStartPayload is the payload of OnHTTPServerStart
@@ -56,3 +61,8 @@ type OnGRPCServerShutdownPayload struct {
 	GRPCServer *grpc.Server
 	Listener   net.Listener
 }
+
+// OnStartupReportPayload is the payload of OnStartupReport
+type OnStartupReportPayload struct {
+	Report *StartupReport
+}
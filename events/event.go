@@ -1,6 +1,8 @@
 package events
 
 import (
+	"strings"
+
 	"github.com/DoNewsCode/core/contract"
 )
 
@@ -9,8 +11,58 @@ type event string
 // OnReload is an event that triggers the configuration reloads. The event payload is OnReloadPayload.
 const OnReload event = "onReload"
 
+// OnReloadFailed is an event that triggers when the configuration fails to
+// reload. The previous configuration stays in effect. The event payload is
+// OnReloadFailedPayload.
+const OnReloadFailed event = "onReloadFailed"
+
 // OnReload is an event that triggers the configuration reloads
 type OnReloadPayload struct {
 	// NewConf is the latest configuration after the reload.
 	NewConf contract.ConfigAccessor
+	// Diff is the key level difference between the previous configuration and
+	// NewConf. It is nil if the difference is unknown, in which case listeners
+	// should assume everything has changed.
+	Diff *ConfigDiff
+}
+
+// ConfigDiff is the key level difference between two configurations. Keys are
+// the full paths to the leaf values, delimited by ".", such as "gorm.default.dsn".
+type ConfigDiff struct {
+	Added    []string
+	Removed  []string
+	Modified []string
+}
+
+// HasChanged reports whether the value at the path, or any value under it,
+// has been added, removed or modified. For example, if "gorm.default.dsn" is
+// modified, both HasChanged("gorm.default.dsn") and HasChanged("gorm.default")
+// are true. A nil *ConfigDiff reports every path as changed.
+func (d *ConfigDiff) HasChanged(path string) bool {
+	if d == nil {
+		return true
+	}
+	for _, keys := range [][]string{d.Added, d.Removed, d.Modified} {
+		for _, key := range keys {
+			if path == "" || key == path || strings.HasPrefix(key, path+".") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsEmpty reports whether nothing has changed.
+func (d *ConfigDiff) IsEmpty() bool {
+	return d != nil && len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Modified) == 0
+}
+
+// OnReloadFailedPayload is the payload of OnReloadFailed.
+type OnReloadFailedPayload struct {
+	// Err is the reason of the failure.
+	Err error
+	// Attempt is the number of consecutive failed attempts, starting from 1.
+	Attempt int
+	// WillRetry reports whether another attempt is scheduled.
+	WillRetry bool
 }
@@ -0,0 +1,67 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/DoNewsCode/core/container"
+	"github.com/DoNewsCode/core/di"
+	"github.com/spf13/cobra"
+)
+
+type graphIn struct {
+	di.In
+
+	Plan *di.Plan
+}
+
+// NewGraphModule creates a module that provides the graph command. The command
+// prints every constructor added to the core via Provide, along with its inputs
+// and outputs, without calling any of them. Missing and cyclic dependencies are
+// reported, and the command fails if there is any, so it can be used in CI.
+//
+// Without WithPlanOnly, the functions passed to AddModuleFunc and Invoke are
+// called before the command runs, and a broken dependency panics there. Use
+// WithPlanOnly, which adds the command by itself, to check the whole graph.
+//
+//  go run main.go graph
+//  go run main.go graph --format mermaid
+func NewGraphModule(in graphIn) graphModule {
+	return graphModule{plan: in.Plan}
+}
+
+var _ container.CommandProvider = (*graphModule)(nil)
+
+type graphModule struct {
+	plan *di.Plan
+}
+
+func (g graphModule) ProvideCommand(command *cobra.Command) {
+	var format string
+	graphCmd := &cobra.Command{
+		Use:   "graph",
+		Short: "print the dependency graph.",
+		Long:  "print the providers of the dependency graph with their inputs and outputs, and check for missing and cyclic dependencies.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var err error
+			switch format {
+			case "text":
+				err = g.plan.WriteText(cmd.OutOrStdout())
+			case "dot":
+				err = g.plan.WriteDOT(cmd.OutOrStdout())
+			case "mermaid":
+				err = g.plan.WriteMermaid(cmd.OutOrStdout())
+			default:
+				return fmt.Errorf("unsupported format %s", format)
+			}
+			if err != nil {
+				return err
+			}
+			if missing, cycles := len(g.plan.Missing()), len(g.plan.Cycles()); missing > 0 || cycles > 0 {
+				return fmt.Errorf("found %d missing dependencies and %d cycles", missing, cycles)
+			}
+			return nil
+		},
+	}
+	graphCmd.Flags().StringVarP(&format, "format", "f", "text", "The output format: text, dot or mermaid")
+	command.AddCommand(graphCmd)
+}
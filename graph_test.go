@@ -0,0 +1,57 @@
+package core
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/DoNewsCode/core/di"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGraphModule(t *testing.T) {
+	c := Default()
+	c.AddModuleFunc(NewGraphModule)
+
+	var out strings.Builder
+	rootCommand := &cobra.Command{}
+	rootCommand.SetOut(&out)
+	c.ApplyRootCommand(rootCommand)
+	rootCommand.SetArgs([]string{"graph"})
+	assert.NoError(t, rootCommand.Execute())
+	assert.Contains(t, out.String(), "provide core.NewGraphModule")
+	assert.Contains(t, out.String(), "  in:  *di.Plan")
+
+	c.Provide(di.Deps{mockConstructor})
+	for _, format := range []string{"text", "dot", "mermaid"} {
+		out.Reset()
+		rootCommand.SetArgs([]string{"graph", "--format", format})
+		assert.Error(t, rootCommand.Execute())
+		assert.Contains(t, out.String(), "core.b")
+	}
+	assert.Contains(t, out.String(), "graph LR")
+}
+
+type graphMissing struct{}
+
+type graphModuleWithMissing struct{}
+
+func TestGraphModule_planOnly(t *testing.T) {
+	c := Default(WithPlanOnly())
+	// The constructor is never called, so the missing dependency doesn't panic.
+	c.AddModuleFunc(func(graphMissing) graphModuleWithMissing {
+		t.Fatal("the constructor should not be called")
+		return graphModuleWithMissing{}
+	})
+	c.Invoke(func(graphMissing) {
+		t.Fatal("the function should not be called")
+	})
+
+	var out strings.Builder
+	rootCommand := &cobra.Command{}
+	rootCommand.SetOut(&out)
+	c.ApplyRootCommand(rootCommand)
+	rootCommand.SetArgs([]string{"graph"})
+	assert.Error(t, rootCommand.Execute())
+	assert.Contains(t, out.String(), "missing dependencies:\n  core.graphMissing, required by")
+}
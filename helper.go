@@ -4,6 +4,9 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+
+	"github.com/DoNewsCode/core/config"
+	"github.com/go-kit/kit/log"
 )
 
 func getString(data map[string]interface{}, key ...string) (string, error) {
@@ -77,3 +80,95 @@ func isValidFormat(format string) bool {
 	}
 	return false
 }
+
+func getStrings(data map[string]interface{}, key ...string) ([]string, error) {
+	if len(key) <= 0 {
+		panic("key must be provided at least once")
+	}
+	for i := 0; i < len(key)-1; i++ {
+		value, ok := data[key[i]]
+		if !ok {
+			return nil, fmt.Errorf("%s doesn't exist", strings.Join(key[0:i+1], "."))
+		}
+		data, ok = value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s is not a map", strings.Join(key[0:i+1], "."))
+		}
+	}
+	value, ok := data[key[len(key)-1]]
+	if !ok {
+		return nil, fmt.Errorf("%s doesn't exist", strings.Join(key, "."))
+	}
+	switch list := value.(type) {
+	case []string:
+		return list, nil
+	case []interface{}:
+		strs := make([]string, len(list))
+		for i := range list {
+			if strs[i], ok = list[i].(string); !ok {
+				return nil, errors.New("must be a list of strings")
+			}
+		}
+		return strs, nil
+	default:
+		return nil, errors.New("must be a list of strings")
+	}
+}
+
+// validateTLS validates the optional TLS config of the server. The files must
+// exist if TLS is enabled.
+func validateTLS(data map[string]interface{}, server string) error {
+	if values, ok := data[server].(map[string]interface{}); !ok || values["tls"] == nil {
+		return nil
+	}
+	var conf TLSConfig
+	if err := config.MapAdapter(data).Unmarshal(server+".tls", &conf); err != nil {
+		return fmt.Errorf("the %s.tls field is not valid: %w", server, err)
+	}
+	if !conf.Enabled() {
+		if conf.KeyFile != "" || conf.ClientCAFile != "" {
+			return fmt.Errorf("the %s.tls.certFile field must be set to enable TLS", server)
+		}
+		if _, err := tlsVersion(conf.MinVersion); err != nil {
+			return fmt.Errorf("the %s.tls.minVersion field is not valid: %w", server, err)
+		}
+		if _, err := tlsCipherSuites(conf.CipherSuites); err != nil {
+			return fmt.Errorf("the %s.tls.cipherSuites field is not valid: %w", server, err)
+		}
+		return nil
+	}
+	if _, err := conf.Build(); err != nil {
+		return fmt.Errorf("the %s.tls field is not valid: %w", server, err)
+	}
+	return nil
+}
+
+// validateHTTPMiddleware validates the optional http.middleware config.
+func validateHTTPMiddleware(data map[string]interface{}) error {
+	if values, ok := data["http"].(map[string]interface{}); !ok || values["middleware"] == nil {
+		return nil
+	}
+	var conf httpMiddlewareConf
+	if err := config.MapAdapter(data).Unmarshal("http.middleware", &conf); err != nil {
+		return fmt.Errorf("the http.middleware field is not valid: %w", err)
+	}
+	if _, err := conf.middlewares(log.NewNopLogger()); err != nil {
+		return fmt.Errorf("the http.middleware field is not valid: %w", err)
+	}
+	return nil
+}
+
+// validateGRPCInterceptor validates the optional grpc.interceptor config.
+func validateGRPCInterceptor(data map[string]interface{}) error {
+	if values, ok := data["grpc"].(map[string]interface{}); !ok || values["interceptor"] == nil {
+		return nil
+	}
+	var conf grpcInterceptorConf
+	if err := config.MapAdapter(data).Unmarshal("grpc.interceptor", &conf); err != nil {
+		return fmt.Errorf("the grpc.interceptor field is not valid: %w", err)
+	}
+	if _, _, err := conf.interceptors(log.NewNopLogger()); err != nil {
+		return fmt.Errorf("the grpc.interceptor field is not valid: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/DoNewsCode/core/config"
+	"github.com/DoNewsCode/core/srvgrpc"
+	"github.com/go-kit/kit/log"
+	"google.golang.org/grpc"
+)
+
+// grpcInterceptorConf is the config of the interceptors of the gRPC server:
+//
+//  grpc:
+//    interceptor:
+//      ctxmeta:
+//        enable: true
+//        keys: [x-request-id]
+//      logging:
+//        enable: true
+//      recovery:
+//        enable: true
+//      unierr:
+//        enable: true
+//      deadline:
+//        enable: true
+//        max: 30s
+//      validation:
+//        enable: true
+//
+// The interceptors apply in the order above, with ctxmeta the outermost, to
+// both unary and streaming calls. They only apply to the gRPC server created
+// by core, not to a *grpc.Server provided in the dependency graph. Every
+// interceptor is off by default, so that upgrading leaves the behavior of
+// existing servers as is.
+type grpcInterceptorConf struct {
+	Ctxmeta struct {
+		Enable bool     `json:"enable" yaml:"enable"`
+		Keys   []string `json:"keys" yaml:"keys"`
+	} `json:"ctxmeta" yaml:"ctxmeta"`
+	Logging struct {
+		Enable bool `json:"enable" yaml:"enable"`
+	} `json:"logging" yaml:"logging"`
+	Recovery struct {
+		Enable bool `json:"enable" yaml:"enable"`
+	} `json:"recovery" yaml:"recovery"`
+	Unierr struct {
+		Enable bool `json:"enable" yaml:"enable"`
+	} `json:"unierr" yaml:"unierr"`
+	Deadline struct {
+		Enable bool            `json:"enable" yaml:"enable"`
+		Max    config.Duration `json:"max" yaml:"max"`
+	} `json:"deadline" yaml:"deadline"`
+	Validation struct {
+		Enable bool `json:"enable" yaml:"enable"`
+	} `json:"validation" yaml:"validation"`
+}
+
+// interceptors returns the enabled interceptors, from the outermost.
+func (c grpcInterceptorConf) interceptors(logger log.Logger) ([]grpc.UnaryServerInterceptor, []grpc.StreamServerInterceptor, error) {
+	var (
+		unary  []grpc.UnaryServerInterceptor
+		stream []grpc.StreamServerInterceptor
+	)
+	if c.Ctxmeta.Enable {
+		unary = append(unary, srvgrpc.Baggage(c.Ctxmeta.Keys))
+		stream = append(stream, srvgrpc.BaggageStream(c.Ctxmeta.Keys))
+	}
+	if c.Logging.Enable {
+		unary = append(unary, srvgrpc.Log(logger))
+		stream = append(stream, srvgrpc.LogStream(logger))
+	}
+	if c.Recovery.Enable {
+		unary = append(unary, srvgrpc.Recover(logger))
+		stream = append(stream, srvgrpc.RecoverStream(logger))
+	}
+	if c.Unierr.Enable {
+		unary = append(unary, srvgrpc.Unierr())
+		stream = append(stream, srvgrpc.UnierrStream())
+	}
+	if c.Deadline.Enable {
+		if c.Deadline.Max.Duration <= 0 {
+			return nil, nil, fmt.Errorf("the max deadline must be positive, got %s", c.Deadline.Max.Duration)
+		}
+		unary = append(unary, srvgrpc.Deadline(c.Deadline.Max.Duration))
+		stream = append(stream, srvgrpc.DeadlineStream(c.Deadline.Max.Duration))
+	}
+	if c.Validation.Enable {
+		unary = append(unary, srvgrpc.Validate())
+		stream = append(stream, srvgrpc.ValidateStream())
+	}
+	return unary, stream, nil
+}
+
+// grpcServerOptions returns the options of the gRPC server created by core,
+// with the interceptors configured under grpc.interceptor.
+func (s serveIn) grpcServerOptions() ([]grpc.ServerOption, error) {
+	var conf grpcInterceptorConf
+	if err := s.Config.Unmarshal("grpc.interceptor", &conf); err != nil {
+		return nil, fmt.Errorf("invalid grpc.interceptor: %w", err)
+	}
+	unary, stream, err := conf.interceptors(s.Logger)
+	if err != nil {
+		return nil, fmt.Errorf("invalid grpc.interceptor: %w", err)
+	}
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.ChainStreamInterceptor(stream...),
+	}, nil
+}
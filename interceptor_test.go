@@ -0,0 +1,68 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/events"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+type panicHealthServer struct {
+	grpc_health_v1.UnimplementedHealthServer
+}
+
+func (p panicHealthServer) Check(ctx context.Context, request *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
+	panic("boom")
+}
+
+type panicHealthModule struct{}
+
+func (p panicHealthModule) ProvideGRPC(server *grpc.Server) {
+	grpc_health_v1.RegisterHealthServer(server, panicHealthServer{})
+}
+
+func TestC_Serve_grpcInterceptor(t *testing.T) {
+	c := New(
+		WithInline("http.disable", true),
+		WithInline("grpc.addr", "127.0.0.1:0"),
+		WithInline("grpc.interceptor.logging.enable", true),
+		WithInline("grpc.interceptor.recovery.enable", true),
+		WithInline("grpc.interceptor.unierr.enable", true),
+		WithInline("cron.disable", true),
+		WithInline("log.level", "none"),
+	)
+	c.ProvideEssentials()
+	c.AddModule(panicHealthModule{})
+
+	started := make(chan string, 1)
+	c.Invoke(func(dispatcher contract.Dispatcher) {
+		dispatcher.Subscribe(events.Listen(OnGRPCServerStart, func(ctx context.Context, start interface{}) error {
+			started <- start.(OnGRPCServerStartPayload).Listener.Addr().String()
+			return nil
+		}))
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Serve(ctx)
+
+	var addr string
+	select {
+	case addr = <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the grpc server did not start")
+	}
+
+	conn, err := grpc.Dial(addr, grpc.WithInsecure())
+	assert.NoError(t, err)
+	defer conn.Close()
+	_, err = grpc_health_v1.NewHealthClient(conn).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	assert.Equal(t, codes.Internal, status.Code(err))
+	assert.Equal(t, "internal server error", status.Convert(err).Message())
+}
@@ -0,0 +1,139 @@
+package core
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// listen creates the listener of a server address. Besides TCP addresses such
+// as ":8080", it accepts unix domain sockets and systemd socket activation:
+//
+//  http:
+//    addr: unix:///run/app/http.sock?mode=0660
+//  grpc:
+//    addr: systemd://grpc
+//
+// A stale socket file left by a previous process is removed before listening,
+// and the socket file is removed again when the listener is closed. The mode
+// query sets the permission of the socket file.
+//
+// The systemd scheme adopts a file descriptor passed by systemd, as
+// configured by the ListenStream directives of the socket unit. The host
+// selects the descriptor either by its FileDescriptorName or by its index,
+// starting from 0. "systemd://" adopts the first one.
+func listen(addr string) (net.Listener, error) {
+	switch {
+	case strings.HasPrefix(addr, "unix://"):
+		return listenUnix(addr)
+	case strings.HasPrefix(addr, "systemd://"):
+		return listenSystemd(strings.TrimPrefix(addr, "systemd://"))
+	}
+	return net.Listen("tcp", addr)
+}
+
+func listenUnix(addr string) (net.Listener, error) {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid unix socket address %s: %w", addr, err)
+	}
+	path := u.Host + u.Path
+	if path == "" {
+		return nil, fmt.Errorf("invalid unix socket address %s: missing path", addr)
+	}
+	var mode os.FileMode
+	if m := u.Query().Get("mode"); m != "" {
+		parsed, err := strconv.ParseUint(m, 8, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid unix socket address %s: mode must be octal, got %s", addr, m)
+		}
+		mode = os.FileMode(parsed)
+	}
+
+	// Only remove sockets, never a regular file configured by mistake.
+	if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("unable to remove the stale socket %s: %w", path, err)
+		}
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if mode != 0 {
+		if err := os.Chmod(path, mode); err != nil {
+			_ = ln.Close()
+			return nil, fmt.Errorf("unable to change the mode of %s: %w", path, err)
+		}
+	}
+	return ln, nil
+}
+
+// listenFdsStart is the first file descriptor passed by systemd.
+const listenFdsStart = 3
+
+func listenSystemd(name string) (net.Listener, error) {
+	if pid, err := strconv.Atoi(os.Getenv("LISTEN_PID")); err != nil || pid != os.Getpid() {
+		return nil, fmt.Errorf("no file descriptor is passed by systemd to this process")
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count <= 0 {
+		return nil, fmt.Errorf("no file descriptor is passed by systemd to this process")
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	index := -1
+	for i := 0; i < count && i < len(names); i++ {
+		if names[i] == name {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		if name == "" {
+			index = 0
+		} else if i, err := strconv.Atoi(name); err == nil {
+			index = i
+		}
+	}
+	if index < 0 || index >= count {
+		return nil, fmt.Errorf("systemd passed no file descriptor named %s, got %d: [%s]", name, count, strings.Join(names, ", "))
+	}
+
+	f := os.NewFile(uintptr(listenFdsStart+index), fmt.Sprintf("systemd:%d", index))
+	// FileListener duplicates the descriptor, so the original can be closed.
+	// The second attempt to adopt the same descriptor fails.
+	defer f.Close()
+	ln, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("unable to adopt the file descriptor %d from systemd: %w", listenFdsStart+index, err)
+	}
+	return ln, nil
+}
+
+// validateAddr validates the server addresses accepted by listen.
+func validateAddr(addr string) error {
+	switch {
+	case strings.HasPrefix(addr, "unix://"):
+		u, err := url.Parse(addr)
+		if err != nil {
+			return err
+		}
+		if u.Host+u.Path == "" {
+			return fmt.Errorf("missing socket path")
+		}
+		if m := u.Query().Get("mode"); m != "" {
+			if _, err := strconv.ParseUint(m, 8, 32); err != nil {
+				return fmt.Errorf("mode must be octal, got %s", m)
+			}
+		}
+		return nil
+	case strings.HasPrefix(addr, "systemd://"):
+		return nil
+	}
+	_, err := net.ResolveTCPAddr("tcp", addr)
+	return err
+}
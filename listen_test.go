@@ -0,0 +1,191 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/events"
+	"github.com/DoNewsCode/core/srvhttp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListen_unix(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix sockets permissions are not supported on windows")
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "http.sock")
+
+	// A stale socket left by a crashed process.
+	stale, err := net.Listen("unix", path)
+	assert.NoError(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	ln, err := listen(fmt.Sprintf("unix://%s?mode=0600", path))
+	assert.NoError(t, err)
+	assert.Equal(t, "unix", ln.Addr().Network())
+	assert.Equal(t, path, ln.Addr().String())
+	fi, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			conn.Write([]byte("hello"))
+			conn.Close()
+		}
+	}()
+	conn, err := net.Dial("unix", path)
+	assert.NoError(t, err)
+	b, _ := ioutil.ReadAll(conn)
+	assert.Equal(t, "hello", string(b))
+
+	assert.NoError(t, ln.Close())
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+
+	t.Run("regular file", func(t *testing.T) {
+		path := filepath.Join(dir, "file")
+		assert.NoError(t, ioutil.WriteFile(path, []byte("data"), 0600))
+		_, err := listen("unix://" + path)
+		assert.Error(t, err)
+		b, _ := ioutil.ReadFile(path)
+		assert.Equal(t, "data", string(b))
+	})
+
+	t.Run("invalid mode", func(t *testing.T) {
+		_, err := listen("unix://" + filepath.Join(dir, "mode.sock") + "?mode=rw")
+		assert.Error(t, err)
+	})
+}
+
+func TestListen_systemd(t *testing.T) {
+	if name := os.Getenv("CORE_TEST_SYSTEMD"); name != "" {
+		// Running as the child process started below.
+		os.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+		ln, err := listen("systemd://" + name)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		conn, err := ln.Accept()
+		if err != nil {
+			os.Exit(1)
+		}
+		conn.Write([]byte(ln.Addr().String()))
+		conn.Close()
+		os.Exit(0)
+	}
+	if runtime.GOOS == "windows" {
+		t.Skip("socket activation is not supported on windows")
+	}
+
+	for _, name := range []string{"grpc", "1"} {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			var files []*os.File
+			var addrs []string
+			for i := 0; i < 2; i++ {
+				ln, err := net.Listen("tcp", "127.0.0.1:0")
+				assert.NoError(t, err)
+				defer ln.Close()
+				f, err := ln.(*net.TCPListener).File()
+				assert.NoError(t, err)
+				defer f.Close()
+				files = append(files, f)
+				addrs = append(addrs, ln.Addr().String())
+			}
+
+			cmd := exec.Command(os.Args[0], "-test.run=^TestListen_systemd$")
+			cmd.Env = append(os.Environ(), "CORE_TEST_SYSTEMD="+name, "LISTEN_FDS=2", "LISTEN_FDNAMES=http:grpc")
+			cmd.ExtraFiles = files
+			assert.NoError(t, cmd.Start())
+
+			conn, err := net.Dial("tcp", addrs[1])
+			assert.NoError(t, err)
+			b, _ := ioutil.ReadAll(conn)
+			assert.Equal(t, addrs[1], string(b))
+			assert.NoError(t, cmd.Wait())
+		})
+	}
+
+	t.Run("not activated", func(t *testing.T) {
+		_, err := listen("systemd://http")
+		assert.Error(t, err)
+	})
+}
+
+func TestC_Serve_unix(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix sockets permissions are not supported on windows")
+	}
+	path := filepath.Join(t.TempDir(), "http.sock")
+	c := New(
+		WithInline("http.addr", "unix://"+path),
+		WithInline("grpc.disable", true),
+		WithInline("cron.disable", true),
+		WithInline("log.level", "none"),
+	)
+	c.ProvideEssentials()
+	c.AddModule(srvhttp.HealthCheckModule{})
+
+	started := make(chan net.Addr, 1)
+	c.Invoke(func(dispatcher contract.Dispatcher) {
+		dispatcher.Subscribe(events.Listen(OnHTTPServerStart, func(ctx context.Context, start interface{}) error {
+			started <- start.(OnHTTPServerStartPayload).Listener.Addr()
+			return nil
+		}))
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		c.Serve(ctx)
+		close(done)
+	}()
+
+	select {
+	case addr := <-started:
+		assert.Equal(t, "unix", addr.Network())
+		assert.Equal(t, path, addr.String())
+	case <-time.After(5 * time.Second):
+		t.Fatal("the http server did not start")
+	}
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+	resp, err := client.Get("http://unix/live")
+	assert.NoError(t, err)
+	if err == nil {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	cancel()
+	<-done
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestValidateAddr(t *testing.T) {
+	for _, addr := range []string{":8080", "127.0.0.1:0", "unix:///run/app.sock", "unix:///run/app.sock?mode=0660", "systemd://http"} {
+		assert.NoError(t, validateAddr(addr), addr)
+	}
+	for _, addr := range []string{"aaa", "unix://", "unix:///run/app.sock?mode=rw"} {
+		assert.Error(t, validateAddr(addr), addr)
+	}
+}
@@ -0,0 +1,138 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/DoNewsCode/core/config"
+	"github.com/DoNewsCode/core/srvhttp"
+	"github.com/go-kit/kit/log"
+)
+
+// httpMiddlewareConf is the config of the middlewares wrapping the router of
+// the HTTP server:
+//
+//  http:
+//    middleware:
+//      realIP:
+//        enable: true
+//        trustedProxies: [10.0.0.0/8]
+//      requestID:
+//        enable: true
+//        header: X-Request-Id
+//      recovery:
+//        enable: true
+//      cors:
+//        enable: true
+//        allowedOrigins: ["https://example.com"]
+//      bodyLimit:
+//        enable: true
+//        maxBytes: 4194304
+//      timeout:
+//        enable: true
+//        duration: 30s
+//      compression:
+//        enable: true
+//
+// The middlewares apply in the order above, with realIP the outermost, to
+// every request including those matching no route. Every middleware is off by
+// default, so that upgrading leaves the responses of existing servers as is.
+type httpMiddlewareConf struct {
+	RealIP struct {
+		Enable         bool     `json:"enable" yaml:"enable"`
+		TrustedProxies []string `json:"trustedProxies" yaml:"trustedProxies"`
+	} `json:"realIP" yaml:"realIP"`
+	RequestID struct {
+		Enable bool   `json:"enable" yaml:"enable"`
+		Header string `json:"header" yaml:"header"`
+	} `json:"requestID" yaml:"requestID"`
+	Recovery struct {
+		Enable bool `json:"enable" yaml:"enable"`
+	} `json:"recovery" yaml:"recovery"`
+	CORS struct {
+		Enable           bool     `json:"enable" yaml:"enable"`
+		AllowedOrigins   []string `json:"allowedOrigins" yaml:"allowedOrigins"`
+		AllowedMethods   []string `json:"allowedMethods" yaml:"allowedMethods"`
+		AllowedHeaders   []string `json:"allowedHeaders" yaml:"allowedHeaders"`
+		ExposedHeaders   []string `json:"exposedHeaders" yaml:"exposedHeaders"`
+		AllowCredentials bool     `json:"allowCredentials" yaml:"allowCredentials"`
+		MaxAge           int      `json:"maxAge" yaml:"maxAge"`
+	} `json:"cors" yaml:"cors"`
+	BodyLimit struct {
+		Enable   bool  `json:"enable" yaml:"enable"`
+		MaxBytes int64 `json:"maxBytes" yaml:"maxBytes"`
+	} `json:"bodyLimit" yaml:"bodyLimit"`
+	Timeout struct {
+		Enable   bool            `json:"enable" yaml:"enable"`
+		Duration config.Duration `json:"duration" yaml:"duration"`
+	} `json:"timeout" yaml:"timeout"`
+	Compression struct {
+		Enable bool `json:"enable" yaml:"enable"`
+	} `json:"compression" yaml:"compression"`
+}
+
+// middlewares returns the enabled middlewares, from the outermost.
+func (c httpMiddlewareConf) middlewares(logger log.Logger) ([]func(http.Handler) http.Handler, error) {
+	var chain []func(http.Handler) http.Handler
+	if c.RealIP.Enable {
+		trusted, err := srvhttp.ParseCIDRs(c.RealIP.TrustedProxies)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxies: %w", err)
+		}
+		chain = append(chain, srvhttp.RealIP(trusted))
+	}
+	if c.RequestID.Enable {
+		header := c.RequestID.Header
+		if header == "" {
+			header = "X-Request-Id"
+		}
+		chain = append(chain, srvhttp.RequestID(header))
+	}
+	if c.Recovery.Enable {
+		chain = append(chain, srvhttp.Recover(logger))
+	}
+	if c.CORS.Enable {
+		chain = append(chain, srvhttp.CORS(srvhttp.CORSOptions{
+			AllowedOrigins:   c.CORS.AllowedOrigins,
+			AllowedMethods:   c.CORS.AllowedMethods,
+			AllowedHeaders:   c.CORS.AllowedHeaders,
+			ExposedHeaders:   c.CORS.ExposedHeaders,
+			AllowCredentials: c.CORS.AllowCredentials,
+			MaxAge:           c.CORS.MaxAge,
+		}))
+	}
+	if c.BodyLimit.Enable {
+		if c.BodyLimit.MaxBytes <= 0 {
+			return nil, fmt.Errorf("the body limit must be positive, got %d", c.BodyLimit.MaxBytes)
+		}
+		chain = append(chain, srvhttp.BodyLimit(c.BodyLimit.MaxBytes))
+	}
+	if c.Timeout.Enable {
+		if c.Timeout.Duration.Duration <= 0 {
+			return nil, fmt.Errorf("the timeout must be positive, got %s", c.Timeout.Duration.Duration)
+		}
+		chain = append(chain, srvhttp.Timeout(c.Timeout.Duration.Duration))
+	}
+	if c.Compression.Enable {
+		chain = append(chain, srvhttp.Compress())
+	}
+	return chain, nil
+}
+
+// httpHandler wraps the router with the middlewares configured under
+// http.middleware.
+func (s serveIn) httpHandler(router http.Handler) (http.Handler, error) {
+	var conf httpMiddlewareConf
+	if err := s.Config.Unmarshal("http.middleware", &conf); err != nil {
+		return nil, fmt.Errorf("invalid http.middleware: %w", err)
+	}
+	chain, err := conf.middlewares(s.Logger)
+	if err != nil {
+		return nil, fmt.Errorf("invalid http.middleware: %w", err)
+	}
+	var handler = router
+	for i := len(chain) - 1; i >= 0; i-- {
+		handler = chain[i](handler)
+	}
+	return handler, nil
+}
@@ -0,0 +1,76 @@
+package core
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/events"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+type panicModule struct{}
+
+func (p panicModule) ProvideHTTP(router *mux.Router) {
+	router.Path("/panic").HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		panic("boom")
+	})
+}
+
+func TestC_Serve_httpMiddleware(t *testing.T) {
+	c := New(
+		WithInline("http.addr", "127.0.0.1:0"),
+		WithInline("http.middleware.requestID.enable", true),
+		WithInline("http.middleware.requestID.header", "X-Trace"),
+		WithInline("http.middleware.recovery.enable", true),
+		WithInline("http.middleware.compression.enable", true),
+		WithInline("grpc.disable", true),
+		WithInline("cron.disable", true),
+		WithInline("log.level", "none"),
+	)
+	c.ProvideEssentials()
+	c.AddModule(panicModule{})
+
+	started := make(chan string, 1)
+	c.Invoke(func(dispatcher contract.Dispatcher) {
+		dispatcher.Subscribe(events.Listen(OnHTTPServerStart, func(ctx context.Context, start interface{}) error {
+			started <- start.(OnHTTPServerStartPayload).Listener.Addr().String()
+			return nil
+		}))
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Serve(ctx)
+
+	var addr string
+	select {
+	case addr = <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the http server did not start")
+	}
+
+	resp, err := http.Get("http://" + addr + "/panic")
+	assert.NoError(t, err)
+	if err == nil {
+		b, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+		assert.JSONEq(t, `{"code":13,"message":"internal server error"}`, string(b))
+		assert.NotEmpty(t, resp.Header.Get("X-Trace"))
+	}
+
+	// The requests matching no route go through the middlewares too.
+	resp, err = http.Get("http://" + addr + "/missing")
+	assert.NoError(t, err)
+	if err == nil {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		assert.NotEmpty(t, resp.Header.Get("X-Trace"))
+		// The transport decompresses the body transparently.
+		assert.True(t, resp.Uncompressed)
+	}
+}
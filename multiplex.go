@@ -0,0 +1,111 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/DoNewsCode/core/logging"
+	"github.com/oklog/run"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+)
+
+// muxServe serves HTTP and gRPC on the same listener at http.addr, as enabled
+// by grpc.multiplex. The HTTP server accepts HTTP/2 over cleartext (h2c) as
+// well, and hands the HTTP/2 requests with the content type application/grpc
+// to grpc.Server.ServeHTTP. Everything else goes to the router. grpc.addr and
+// grpc.tls are ignored, http.tls applies to both.
+//
+// Note that grpc.Server.ServeHTTP doesn't support some of the features of the
+// native gRPC transport, such as keepalive enforcement. Serve gRPC on its own
+// address if they are needed.
+func (s serveIn) muxServe(ctx context.Context, logger logging.LevelLogger) (func() error, func(err error), error) {
+	ln, stopReload, err := s.listenHTTP(ctx, logger)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed start multiplexed server")
+	}
+	if s.GRPCServer == nil {
+		opts, err := s.grpcServerOptions()
+		if err != nil {
+			stopReload()
+			_ = ln.Close()
+			return nil, nil, errors.Wrap(err, "failed start multiplexed server")
+		}
+		s.GRPCServer = grpc.NewServer(opts...)
+	}
+	s.applyGRPCServer(logger)
+
+	httpExecute, httpInterrupt, err := s.serveHTTP(ctx, logger, ln, s.GRPCServer)
+	if err != nil {
+		stopReload()
+		_ = ln.Close()
+		return nil, nil, errors.Wrap(err, "failed start multiplexed server")
+	}
+
+	// The gRPC server has no listener of its own. Its start and shutdown are
+	// still dispatched, as modules such as srvgateway rely on them.
+	var (
+		g        run.Group
+		stop     sync.Once
+		shutdown sync.Once
+		done     = make(chan struct{})
+	)
+	stopGRPC := func() {
+		stop.Do(func() {
+			s.GRPCServer.Stop()
+			close(done)
+		})
+	}
+	// GracefulStop panics on the transports of ServeHTTP. The HTTP server is
+	// shut down first instead, and the gRPC calls still open, which it would
+	// wait for, are then ended by Stop.
+	interrupt := func(err error) {
+		shutdown.Do(func() {
+			_ = ln.Close()
+			stopped := make(chan struct{})
+			go func() {
+				httpInterrupt(err)
+				close(stopped)
+			}()
+			stopGRPC()
+			<-stopped
+		})
+	}
+	g.Add(httpExecute, interrupt)
+	g.Add(func() error {
+		logger.Infof("gRPC service is multiplexed at %s", ln.Addr())
+		s.Dispatcher.Dispatch(
+			ctx,
+			OnGRPCServerStart,
+			OnGRPCServerStartPayload{s.GRPCServer, ln},
+		)
+		defer s.Dispatcher.Dispatch(
+			ctx,
+			OnGRPCServerShutdown,
+			OnGRPCServerShutdownPayload{s.GRPCServer, ln},
+		)
+		<-done
+		return nil
+	}, interrupt)
+	return g.Run, func(err error) {
+		stopReload()
+		interrupt(err)
+	}, nil
+}
+
+// grpcHandler hands the gRPC requests to the gRPC server, and the others to
+// the HTTP handler.
+type grpcHandler struct {
+	grpc *grpc.Server
+	http http.Handler
+}
+
+func (h grpcHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+		h.grpc.ServeHTTP(w, r)
+		return
+	}
+	h.http.ServeHTTP(w, r)
+}
@@ -1,8 +1,10 @@
 package observability
 
 import (
+	"github.com/DoNewsCode/core/clihttp"
 	"github.com/DoNewsCode/core/di"
 	"github.com/DoNewsCode/core/otgorm"
+	"github.com/DoNewsCode/core/otgrpc"
 	"github.com/DoNewsCode/core/otkafka"
 	"github.com/DoNewsCode/core/otredis"
 	"github.com/DoNewsCode/core/srvgrpc"
@@ -38,6 +40,25 @@ func ProvideHTTPRequestDurationSeconds(in MetricsIn) *srvhttp.RequestDurationSec
 	}
 }
 
+// ProvideHTTPClientRequestDurationSeconds returns a *clihttp.RequestDurationSeconds that is designed to measure
+// outgoing HTTP requests. Note it has three labels: "host", "route", "code". If any label is missing,
+// the system will panic.
+func ProvideHTTPClientRequestDurationSeconds(in MetricsIn) *clihttp.RequestDurationSeconds {
+	http := stdprometheus.NewHistogramVec(stdprometheus.HistogramOpts{
+		Name: "http_client_request_duration_seconds",
+		Help: "Total time spent on outgoing requests.",
+	}, []string{"host", "route", "code"})
+
+	if in.Registerer == nil {
+		in.Registerer = stdprometheus.DefaultRegisterer
+	}
+	in.Registerer.MustRegister(http)
+
+	return &clihttp.RequestDurationSeconds{
+		Histogram: prometheus.NewHistogram(http),
+	}
+}
+
 // ProvideGRPCRequestDurationSeconds returns a metrics.Histogram that is designed to measure incoming GRPC requests
 // to the system. Note it has three labels: "module", "service", "route". If any label is missing,
 // the system will panic.
@@ -57,6 +78,47 @@ func ProvideGRPCRequestDurationSeconds(in MetricsIn) *srvgrpc.RequestDurationSec
 	}
 }
 
+// ProvideGRPCStreamMessages returns a *srvgrpc.StreamMessages that counts the messages sent and received
+// by streaming GRPC requests. Note it has three labels: "module", "service", "route". If any label is missing,
+// the system will panic.
+func ProvideGRPCStreamMessages(in MetricsIn) *srvgrpc.StreamMessages {
+	labels := []string{"module", "service", "route"}
+
+	if in.Registerer == nil {
+		in.Registerer = stdprometheus.DefaultRegisterer
+	}
+
+	return &srvgrpc.StreamMessages{
+		Sent: newCounterFrom(stdprometheus.CounterOpts{
+			Name: "grpc_stream_messages_sent_count",
+			Help: "Total number of messages sent by streaming requests.",
+		}, labels, in.Registerer),
+		Received: newCounterFrom(stdprometheus.CounterOpts{
+			Name: "grpc_stream_messages_received_count",
+			Help: "Total number of messages received by streaming requests.",
+		}, labels, in.Registerer),
+	}
+}
+
+// ProvideGRPCClientRequestDurationSeconds returns a *otgrpc.RequestDurationSeconds that is designed to measure
+// outgoing GRPC calls. Note it has three labels: "client", "method", "code". If any label is missing,
+// the system will panic.
+func ProvideGRPCClientRequestDurationSeconds(in MetricsIn) *otgrpc.RequestDurationSeconds {
+	grpc := stdprometheus.NewHistogramVec(stdprometheus.HistogramOpts{
+		Name: "grpc_client_request_duration_seconds",
+		Help: "Total time spent on outgoing calls.",
+	}, []string{"client", "method", "code"})
+
+	if in.Registerer == nil {
+		in.Registerer = stdprometheus.DefaultRegisterer
+	}
+	in.Registerer.MustRegister(grpc)
+
+	return &otgrpc.RequestDurationSeconds{
+		Histogram: prometheus.NewHistogram(grpc),
+	}
+}
+
 // ProvideGORMMetrics returns a *otgorm.Gauges that measures the connection info in databases.
 // It is meant to be consumed by the otgorm.Providers.
 func ProvideGORMMetrics(in MetricsIn) *otgorm.Gauges {
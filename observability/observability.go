@@ -23,7 +23,10 @@ func Providers() di.Deps {
 		ProvideJaegerLogAdapter,
 		ProvideOpentracing,
 		ProvideHTTPRequestDurationSeconds,
+		ProvideHTTPClientRequestDurationSeconds,
 		ProvideGRPCRequestDurationSeconds,
+		ProvideGRPCStreamMessages,
+		ProvideGRPCClientRequestDurationSeconds,
 		ProvideGORMMetrics,
 		ProvideRedisMetrics,
 		ProvideKafkaReaderMetrics,
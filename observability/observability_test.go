@@ -50,8 +50,14 @@ func TestProvideHistogramMetrics(t *testing.T) {
 		t.Run(c.name, func(t *testing.T) {
 			http := ProvideHTTPRequestDurationSeconds(MetricsIn{Registerer: c.registerer})
 			assert.NotNil(t, http)
+			httpClient := ProvideHTTPClientRequestDurationSeconds(MetricsIn{Registerer: c.registerer})
+			assert.NotNil(t, httpClient)
 			grpc := ProvideGRPCRequestDurationSeconds(MetricsIn{Registerer: c.registerer})
 			assert.NotNil(t, grpc)
+			stream := ProvideGRPCStreamMessages(MetricsIn{Registerer: c.registerer})
+			assert.NotNil(t, stream)
+			client := ProvideGRPCClientRequestDurationSeconds(MetricsIn{Registerer: c.registerer})
+			assert.NotNil(t, client)
 		})
 	}
 }
@@ -54,11 +54,12 @@ type factoryOut struct {
 	Factory        Factory
 	Maker          Maker
 	ExportedConfig []config.ExportedConfig `group:"config,flatten"`
+	Factories      []*di.Factory           `group:"factories,flatten"`
 }
 
 // Provide creates Factory and *elastic.Client. It is a valid dependency for
 // package core.
-func provideEsFactory(p factoryIn) (factoryOut, func()) {
+func provideEsFactory(p factoryIn) (factoryOut, func(), error) {
 	factory := di.NewFactory(func(name string) (di.Pair, error) {
 		var (
 			conf    Config
@@ -114,13 +115,18 @@ func provideEsFactory(p factoryIn) (factoryOut, func()) {
 				client.Stop()
 			},
 		}, nil
-	})
+	}, di.WithConfigKind("es"))
 	f := Factory{factory}
+	if err := f.Preload(p.Conf); err != nil {
+		factory.Close()
+		return factoryOut{}, nil, err
+	}
 	f.SubscribeReloadEventFrom(p.Dispatcher)
 	return factoryOut{
-		Factory: f,
-		Maker:   f,
-	}, factory.Close
+		Factory:   f,
+		Maker:     f,
+		Factories: []*di.Factory{factory},
+	}, factory.Close, nil
 }
 
 func provideDefaultClient(maker Maker) (*elastic.Client, error) {
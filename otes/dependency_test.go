@@ -18,7 +18,7 @@ func TestNewEsFactory(t *testing.T) {
 	}
 	addrs := strings.Split(os.Getenv("ELASTICSEARCH_ADDR"), ",")
 	t.Run("normal construction", func(t *testing.T) {
-		esFactory, cleanup := provideEsFactory(factoryIn{
+		esFactory, cleanup, _ := provideEsFactory(factoryIn{
 			Conf: config.MapAdapter{"es": map[string]Config{
 				"default":     {URL: addrs},
 				"alternative": {URL: addrs},
@@ -37,7 +37,7 @@ func TestNewEsFactory(t *testing.T) {
 	})
 	t.Run("with options", func(t *testing.T) {
 		var called bool
-		esFactory, cleanup := provideEsFactory(factoryIn{
+		esFactory, cleanup, _ := provideEsFactory(factoryIn{
 			Conf: config.MapAdapter{"es": map[string]Config{
 				"default": {URL: addrs},
 			}},
@@ -58,7 +58,7 @@ func TestNewEsFactory(t *testing.T) {
 	})
 
 	t.Run("should not connect to ES", func(t *testing.T) {
-		esFactory, cleanup := provideEsFactory(factoryIn{
+		esFactory, cleanup, _ := provideEsFactory(factoryIn{
 			Conf: config.MapAdapter{"es": map[string]Config{
 				// elasticsearch server doesn't exist at this port
 				"default": {URL: []string{"http://127.0.0.1:9999"}},
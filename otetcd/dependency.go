@@ -50,13 +50,14 @@ type factoryIn struct {
 type FactoryOut struct {
 	di.Out
 
-	Maker   Maker
-	Factory Factory
+	Maker     Maker
+	Factory   Factory
+	Factories []*di.Factory `group:"factories,flatten"`
 }
 
 // provideFactory creates Factory. It is a valid
 // dependency for package core.
-func provideFactory(p factoryIn) (FactoryOut, func()) {
+func provideFactory(p factoryIn) (FactoryOut, func(), error) {
 
 	factory := di.NewFactory(func(name string) (di.Pair, error) {
 		var (
@@ -102,14 +103,19 @@ func provideFactory(p factoryIn) (FactoryOut, func()) {
 				_ = client.Close()
 			},
 		}, nil
-	})
+	}, di.WithConfigKind("etcd"))
 	etcdFactory := Factory{factory}
+	if err := etcdFactory.Preload(p.Conf); err != nil {
+		factory.Close()
+		return FactoryOut{}, nil, err
+	}
 	etcdFactory.SubscribeReloadEventFrom(p.Dispatcher)
 	out := FactoryOut{
-		Maker:   etcdFactory,
-		Factory: etcdFactory,
+		Maker:     etcdFactory,
+		Factory:   etcdFactory,
+		Factories: []*di.Factory{factory},
 	}
-	return out, factory.Close
+	return out, factory.Close, nil
 }
 
 func provideDefaultClient(maker Maker) (*clientv3.Client, error) {
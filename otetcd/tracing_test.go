@@ -22,7 +22,7 @@ func TestTracing(t *testing.T) {
 	addrs := strings.Split(os.Getenv("ETCD_ADDR"), ",")
 	var interceptorCalled bool
 	tracer := mocktracer.New()
-	factory, cleanup := provideFactory(factoryIn{
+	factory, cleanup, _ := provideFactory(factoryIn{
 		Logger: log.NewNopLogger(),
 		Conf: config.MapAdapter{"etcd": map[string]Option{
 			"default": {
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"sort"
 	"time"
 
 	"github.com/DoNewsCode/core/config"
@@ -85,6 +86,7 @@ type databaseOut struct {
 	Factory   Factory
 	Maker     Maker
 	Collector *collector
+	Factories []*di.Factory `group:"factories,flatten"`
 }
 
 // Module implements di.Modular
@@ -172,6 +174,36 @@ func provideDefaultDatabase(maker Maker) (*gorm.DB, error) {
 	return maker.Make("default")
 }
 
+// ProvideNamedDatabases returns the providers that publish every database configured under
+// gorm.<name> as a *gorm.DB named after it. The unnamed default database provided by
+// Providers is left as is. Each database is only connected when it is injected,
+// and a broken one doesn't fail the others. The names are read once, when this
+// function is called, so entries added by a config reload are only available
+// through the Maker:
+//
+//  c.Provide(otgorm.Providers())
+//  c.Provide(otgorm.ProvideNamedDatabases(c))
+//
+//  type repositoryIn struct {
+//    di.In
+//    DB *gorm.DB `name:"reporting"`
+//  }
+func ProvideNamedDatabases(conf contract.ConfigUnmarshaler) di.Deps {
+	var databases map[string]interface{}
+	_ = conf.Unmarshal("gorm", &databases)
+	names := make([]string, 0, len(databases))
+	for name := range databases {
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		names = append(names, "default")
+	}
+	sort.Strings(names)
+	return di.NamedInstances(func(maker Maker, name string) (*gorm.DB, error) {
+		return maker.Make(name)
+	}, names...)
+}
+
 func provideDBFactory(p factoryIn) (databaseOut, func(), error) {
 	logger := log.With(p.Logger, "tag", "database")
 
@@ -205,8 +237,12 @@ func provideDBFactory(p factoryIn) (databaseOut, func(), error) {
 			Conn:   conn,
 			Closer: cleanup,
 		}, err
-	})
+	}, di.WithConfigKind("gorm"))
 	dbFactory := Factory{factory}
+	if err := dbFactory.Preload(p.Conf); err != nil {
+		dbFactory.Close()
+		return databaseOut{}, nil, err
+	}
 	dbFactory.SubscribeReloadEventFrom(p.Dispatcher)
 
 	var collector *collector
@@ -220,6 +256,7 @@ func provideDBFactory(p factoryIn) (databaseOut, func(), error) {
 		Factory:   dbFactory,
 		Maker:     dbFactory,
 		Collector: collector,
+		Factories: []*di.Factory{factory},
 	}, dbFactory.Close, nil
 }
 
@@ -68,3 +68,56 @@ func TestProvideConfigs(t *testing.T) {
 	c := provideConfig()
 	assert.NotEmpty(t, c.Config)
 }
+
+func TestProvideNamedDatabases(t *testing.T) {
+	c := core.New(
+		core.WithInline("gorm.default.database", "sqlite"),
+		core.WithInline("gorm.default.dsn", ":memory:"),
+		core.WithInline("gorm.reporting.database", "sqlite"),
+		core.WithInline("gorm.reporting.dsn", ":memory:"),
+		core.WithInline("log.level", "none"),
+	)
+	c.ProvideEssentials()
+	c.Provide(Providers())
+	c.Provide(ProvideNamedDatabases(c))
+	c.Invoke(func(in struct {
+		di.In
+
+		Default   *gorm.DB
+		Named     *gorm.DB `name:"default"`
+		Reporting *gorm.DB `name:"reporting"`
+	}) {
+		assert.NotNil(t, in.Reporting)
+		assert.Same(t, in.Default, in.Named)
+		assert.NotSame(t, in.Default, in.Reporting)
+	})
+}
+
+func TestProvideDBFactory_eager(t *testing.T) {
+	gorms := map[string]interface{}{
+		"default": map[string]interface{}{
+			"database": "sqlite",
+			"dsn":      ":memory:",
+		},
+	}
+	out, cleanup, err := provideDBFactory(factoryIn{
+		Conf: config.MapAdapter{
+			"gorm":    gorms,
+			"startup": map[string]interface{}{"eager": []string{"gorm"}},
+		},
+		Logger: log.NewNopLogger(),
+	})
+	assert.NoError(t, err)
+	assert.Len(t, out.Factory.List(), 1)
+	cleanup()
+
+	gorms["broken"] = map[string]interface{}{"database": "unknown"}
+	_, _, err = provideDBFactory(factoryIn{
+		Conf: config.MapAdapter{
+			"gorm":    gorms,
+			"startup": map[string]interface{}{"eager": []string{"*"}},
+		},
+		Logger: log.NewNopLogger(),
+	})
+	assert.Error(t, err)
+}
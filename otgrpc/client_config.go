@@ -0,0 +1,104 @@
+package otgrpc
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+
+	"github.com/DoNewsCode/core/config"
+	"google.golang.org/grpc/codes"
+)
+
+// ClientConfig is the configuration of a gRPC client.
+type ClientConfig struct {
+	// Target is the address of the server, in the gRPC name syntax. Use the dns
+	// scheme, for example dns:///app:9090, to balance the load over every
+	// resolved address.
+	Target string `json:"target" yaml:"target"`
+	// TLS is the transport security of the connection. The connection is
+	// insecure if TLS is not enabled.
+	TLS ClientTLSConfig `json:"tls" yaml:"tls"`
+	// Timeout is the default timeout of unary calls without a deadline. Zero
+	// means no timeout.
+	Timeout config.Duration `json:"timeout" yaml:"timeout"`
+	// Retry is the retry policy of unary calls.
+	Retry RetryPolicy `json:"retry" yaml:"retry"`
+	// Keepalive configures the keepalive pings of the connection.
+	Keepalive KeepaliveConfig `json:"keepalive" yaml:"keepalive"`
+	// LoadBalancing is the load balancing policy, either pick_first or
+	// round_robin. It defaults to pick_first.
+	LoadBalancing string `json:"loadBalancing" yaml:"loadBalancing"`
+}
+
+// ClientTLSConfig is the TLS configuration of a gRPC client.
+type ClientTLSConfig struct {
+	Enable bool `json:"enable" yaml:"enable"`
+	// CAFile verifies the server certificate. The system pool is used if empty.
+	CAFile string `json:"caFile" yaml:"caFile"`
+	// CertFile and KeyFile are the client certificate, for mTLS.
+	CertFile   string `json:"certFile" yaml:"certFile"`
+	KeyFile    string `json:"keyFile" yaml:"keyFile"`
+	ServerName string `json:"serverName" yaml:"serverName"`
+}
+
+// Build creates the *tls.Config.
+func (c ClientTLSConfig) Build() (*tls.Config, error) {
+	conf := &tls.Config{ServerName: c.ServerName, MinVersion: tls.VersionTLS12}
+	if c.CAFile != "" {
+		pem, err := ioutil.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the CA file: %w", err)
+		}
+		conf.RootCAs = x509.NewCertPool()
+		if !conf.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in the CA file %s", c.CAFile)
+		}
+	}
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load the client certificate: %w", err)
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+	return conf, nil
+}
+
+// RetryPolicy retries the unary calls failing with one of the retryable
+// status codes. Calls are not retried if MaxAttempts is less than 2, which is
+// the default, as gRPC can't tell whether a method is safe to call twice.
+//
+// The backoff before the nth retry is a random duration up to
+// min(InitialBackoff * BackoffMultiplier^(n-1), MaxBackoff), as in the retry
+// design of gRPC.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first one.
+	MaxAttempts       int             `json:"maxAttempts" yaml:"maxAttempts"`
+	InitialBackoff    config.Duration `json:"initialBackoff" yaml:"initialBackoff"`
+	MaxBackoff        config.Duration `json:"maxBackoff" yaml:"maxBackoff"`
+	BackoffMultiplier float64         `json:"backoffMultiplier" yaml:"backoffMultiplier"`
+	// RetryableStatusCodes are the names of the codes, such as UNAVAILABLE.
+	RetryableStatusCodes []string `json:"retryableStatusCodes" yaml:"retryableStatusCodes"`
+}
+
+func (r RetryPolicy) codes() ([]codes.Code, error) {
+	var cs []codes.Code
+	for _, name := range r.RetryableStatusCodes {
+		var c codes.Code
+		if err := c.UnmarshalJSON([]byte(strconv.Quote(name))); err != nil {
+			return nil, fmt.Errorf("invalid retryable status code %s", name)
+		}
+		cs = append(cs, c)
+	}
+	return cs, nil
+}
+
+// KeepaliveConfig configures the keepalive pings of the connection. See
+// keepalive.ClientParameters.
+type KeepaliveConfig struct {
+	Time                config.Duration `json:"time" yaml:"time"`
+	Timeout             config.Duration `json:"timeout" yaml:"timeout"`
+	PermitWithoutStream bool            `json:"permitWithoutStream" yaml:"permitWithoutStream"`
+}
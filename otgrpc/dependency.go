@@ -0,0 +1,220 @@
+package otgrpc
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/DoNewsCode/core/config"
+	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/di"
+	"github.com/go-kit/kit/log"
+	opentracinggrpc "github.com/opentracing-contrib/go-grpc"
+	"github.com/opentracing/opentracing-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
+)
+
+/*
+Providers returns a set of dependency providers related to gRPC clients. It
+includes the Maker, the default *grpc.ClientConn and exported configs.
+
+	Depends On:
+		log.Logger
+		contract.ConfigUnmarshaler
+		DialOptionsInterceptor  `optional:"true"`
+		opentracing.Tracer      `optional:"true"`
+		*RequestDurationSeconds `optional:"true"`
+		contract.Dispatcher     `optional:"true"`
+	Provide:
+		Maker
+		Factory
+		*grpc.ClientConn
+*/
+func Providers() []interface{} {
+	return []interface{}{provideGRPCFactory, provideDefaultClient, provideConfig}
+}
+
+// DialOptionsInterceptor intercepts the dial options before creating the
+// connection so you can make amendment to them. Useful for the options that can
+// not be mapped to a text representation, such as custom interceptors or
+// resolvers.
+type DialOptionsInterceptor func(name string, opts *[]grpc.DialOption)
+
+// factoryIn is the injection parameter for provideGRPCFactory.
+type factoryIn struct {
+	di.In
+
+	Logger      log.Logger
+	Conf        contract.ConfigUnmarshaler
+	Interceptor DialOptionsInterceptor  `optional:"true"`
+	Tracer      opentracing.Tracer      `optional:"true"`
+	Metrics     *RequestDurationSeconds `optional:"true"`
+	Dispatcher  contract.Dispatcher     `optional:"true"`
+}
+
+// factoryOut is the result of provideGRPCFactory.
+type factoryOut struct {
+	di.Out
+
+	Maker     Maker
+	Factory   Factory
+	Factories []*di.Factory `group:"factories,flatten"`
+}
+
+// provideGRPCFactory creates Factory and *grpc.ClientConn. It is a valid
+// dependency for package core. The connections are closed when the factory is
+// closed, or when their configuration changes on reload.
+func provideGRPCFactory(p factoryIn) (factoryOut, func(), error) {
+	factory := di.NewFactory(func(name string) (di.Pair, error) {
+		var conf ClientConfig
+		if err := p.Conf.Unmarshal(fmt.Sprintf("grpcClient.%s", name), &conf); err != nil {
+			return di.Pair{}, fmt.Errorf("grpcClient configuration %s not valid: %w", name, err)
+		}
+		if conf.Target == "" {
+			conf.Target = "127.0.0.1:9090"
+		}
+		opts, err := dialOptions(name, conf, p)
+		if err != nil {
+			return di.Pair{}, fmt.Errorf("grpcClient configuration %s not valid: %w", name, err)
+		}
+		if p.Interceptor != nil {
+			p.Interceptor(name, &opts)
+		}
+		conn, err := grpc.DialContext(context.Background(), conf.Target, opts...)
+		if err != nil {
+			return di.Pair{}, fmt.Errorf("unable to dial grpcClient %s: %w", name, err)
+		}
+		return di.Pair{
+			Conn: conn,
+			Closer: func() {
+				_ = conn.Close()
+			},
+		}, nil
+	}, di.WithConfigKind("grpcClient"))
+	grpcFactory := Factory{factory}
+	if err := grpcFactory.Preload(p.Conf); err != nil {
+		grpcFactory.Close()
+		return factoryOut{}, nil, err
+	}
+	grpcFactory.SubscribeReloadEventFrom(p.Dispatcher)
+	return factoryOut{
+		Maker:     grpcFactory,
+		Factory:   grpcFactory,
+		Factories: []*di.Factory{factory},
+	}, grpcFactory.Close, nil
+}
+
+// dialOptions builds the dial options from the configuration. The unary
+// interceptors apply from the outermost: unierr, timeout, retry, tracing and
+// metrics, so that every attempt is traced and measured.
+func dialOptions(name string, conf ClientConfig, p factoryIn) ([]grpc.DialOption, error) {
+	var (
+		opts   []grpc.DialOption
+		unary  []grpc.UnaryClientInterceptor
+		stream []grpc.StreamClientInterceptor
+	)
+
+	if conf.TLS.Enable {
+		tlsConf, err := conf.TLS.Build()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(tlsConf)))
+	} else {
+		opts = append(opts, grpc.WithInsecure())
+	}
+
+	switch conf.LoadBalancing {
+	case "", "pick_first":
+	case "round_robin":
+		opts = append(opts, grpc.WithDefaultServiceConfig(`{"loadBalancingConfig":[{"round_robin":{}}]}`))
+	default:
+		return nil, fmt.Errorf("unknown load balancing policy %s", conf.LoadBalancing)
+	}
+
+	if conf.Keepalive.Time.Duration > 0 {
+		opts = append(opts, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                conf.Keepalive.Time.Duration,
+			Timeout:             conf.Keepalive.Timeout.Duration,
+			PermitWithoutStream: conf.Keepalive.PermitWithoutStream,
+		}))
+	}
+
+	unary = append(unary, Unierr())
+	stream = append(stream, UnierrStream())
+	if conf.Timeout.Duration > 0 {
+		unary = append(unary, Timeout(conf.Timeout.Duration))
+	}
+	if conf.Retry.MaxAttempts > 1 {
+		retryable, err := conf.Retry.codes()
+		if err != nil {
+			return nil, err
+		}
+		multiplier := conf.Retry.BackoffMultiplier
+		if multiplier <= 0 {
+			multiplier = 1
+		}
+		unary = append(unary, Retry(
+			conf.Retry.MaxAttempts,
+			conf.Retry.InitialBackoff.Duration,
+			conf.Retry.MaxBackoff.Duration,
+			multiplier,
+			retryable...,
+		))
+	}
+	if p.Tracer != nil {
+		unary = append(unary, opentracinggrpc.OpenTracingClientInterceptor(p.Tracer))
+		stream = append(stream, opentracinggrpc.OpenTracingStreamClientInterceptor(p.Tracer))
+	}
+	if p.Metrics != nil {
+		unary = append(unary, Metrics(name, p.Metrics))
+	}
+
+	opts = append(opts,
+		grpc.WithChainUnaryInterceptor(unary...),
+		grpc.WithChainStreamInterceptor(stream...),
+	)
+	return opts, nil
+}
+
+func provideDefaultClient(maker Maker) (*grpc.ClientConn, error) {
+	return maker.Make("default")
+}
+
+type configOut struct {
+	di.Out
+
+	Config []config.ExportedConfig `group:"config,flatten"`
+}
+
+// provideConfig exports the default gRPC client configuration.
+func provideConfig() configOut {
+	configs := []config.ExportedConfig{
+		{
+			Owner: "otgrpc",
+			Data: map[string]interface{}{
+				"grpcClient": map[string]ClientConfig{
+					"default": {
+						Target:  "127.0.0.1:9090",
+						Timeout: config.Duration{Duration: 5 * time.Second},
+						Retry: RetryPolicy{
+							MaxAttempts:          1,
+							InitialBackoff:       config.Duration{Duration: 100 * time.Millisecond},
+							MaxBackoff:           config.Duration{Duration: time.Second},
+							BackoffMultiplier:    2,
+							RetryableStatusCodes: []string{"UNAVAILABLE"},
+						},
+						Keepalive: KeepaliveConfig{
+							Timeout: config.Duration{Duration: 20 * time.Second},
+						},
+						LoadBalancing: "pick_first",
+					},
+				},
+			},
+			Comment: "The configuration of gRPC clients",
+		},
+	}
+	return configOut{Config: configs}
+}
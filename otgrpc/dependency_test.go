@@ -0,0 +1,116 @@
+package otgrpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core/config"
+	"github.com/DoNewsCode/core/events"
+	"github.com/DoNewsCode/core/unierr"
+	"github.com/go-kit/kit/log"
+	"github.com/knadh/koanf/parsers/yaml"
+	"github.com/knadh/koanf/providers/rawbytes"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	yaml2 "gopkg.in/yaml.v3"
+)
+
+func serveHealth(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	server := grpc.NewServer()
+	grpc_health_v1.RegisterHealthServer(server, health.NewServer())
+	go server.Serve(ln)
+	t.Cleanup(server.Stop)
+	return ln.Addr().String()
+}
+
+func TestNewGRPCFactory(t *testing.T) {
+	addr := serveHealth(t)
+	out, cleanup, err := provideGRPCFactory(factoryIn{
+		Conf: config.MapAdapter{"grpcClient": map[string]interface{}{
+			"default": map[string]interface{}{
+				"target":  addr,
+				"timeout": "1s",
+			},
+			"alternative": map[string]interface{}{
+				"target":        addr,
+				"loadBalancing": "round_robin",
+			},
+		}},
+		Logger: log.NewNopLogger(),
+	})
+	assert.NoError(t, err)
+
+	def, err := out.Maker.Make("default")
+	assert.NoError(t, err)
+	resp, err := grpc_health_v1.NewHealthClient(def).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, resp.Status)
+
+	alt, err := out.Maker.Make("alternative")
+	assert.NoError(t, err)
+	_, err = grpc_health_v1.NewHealthClient(alt).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: "missing"})
+	assert.True(t, unierr.IsNotFoundErr(err))
+
+	cleanup()
+	assert.Equal(t, connectivity.Shutdown, def.GetState())
+	assert.Equal(t, connectivity.Shutdown, alt.GetState())
+}
+
+func TestNewGRPCFactory_invalid(t *testing.T) {
+	cases := []map[string]interface{}{
+		{"loadBalancing": "random"},
+		{"retry": map[string]interface{}{"maxAttempts": 2, "retryableStatusCodes": []string{"FOO"}}},
+		{"tls": map[string]interface{}{"enable": true, "caFile": "/not/exist.pem"}},
+	}
+	for _, c := range cases {
+		out, cleanup, err := provideGRPCFactory(factoryIn{
+			Conf:   config.MapAdapter{"grpcClient": map[string]interface{}{"default": c}},
+			Logger: log.NewNopLogger(),
+		})
+		assert.NoError(t, err)
+		_, err = out.Maker.Make("default")
+		assert.Error(t, err)
+		cleanup()
+	}
+}
+
+func TestNewGRPCFactory_reload(t *testing.T) {
+	addr := serveHealth(t)
+	dispatcher := &events.SyncDispatcher{}
+	out, cleanup, err := provideGRPCFactory(factoryIn{
+		Conf: config.MapAdapter{"grpcClient": map[string]interface{}{
+			"default": map[string]interface{}{"target": addr},
+		}},
+		Logger:     log.NewNopLogger(),
+		Dispatcher: dispatcher,
+	})
+	assert.NoError(t, err)
+	defer cleanup()
+
+	before, err := out.Maker.Make("default")
+	assert.NoError(t, err)
+	dispatcher.Dispatch(context.Background(), events.OnReload, events.OnReloadPayload{})
+	after, err := out.Maker.Make("default")
+	assert.NoError(t, err)
+	assert.NotSame(t, before, after)
+	assert.Equal(t, connectivity.Shutdown, before.GetState())
+}
+
+func TestProvideConfigs(t *testing.T) {
+	var conf ClientConfig
+	c := provideConfig()
+	assert.NotEmpty(t, c.Config)
+	bytes, _ := yaml2.Marshal(c.Config[0].Data)
+	k, _ := config.NewConfig(config.WithProviderLayer(rawbytes.Provider(bytes), yaml.Parser()))
+	assert.NoError(t, k.Unmarshal("grpcClient.default", &conf))
+	assert.Equal(t, "127.0.0.1:9090", conf.Target)
+	assert.Equal(t, 1, conf.Retry.MaxAttempts)
+	assert.Equal(t, 5*time.Second, conf.Timeout.Duration)
+}
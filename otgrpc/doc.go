@@ -0,0 +1,62 @@
+/*
+Package otgrpc provides gRPC clients with opentracing, metrics and unierr
+conversion.
+
+Integration
+
+package otgrpc exports the configuration in the following format:
+	grpcClient:
+	    default:
+	        target: 127.0.0.1:9090
+	        tls:
+	            enable: false
+	            caFile: ""
+	            certFile: ""
+	            keyFile: ""
+	            serverName: ""
+	        timeout: 5s
+	        retry:
+	            maxAttempts: 1
+	            initialBackoff: 100ms
+	            maxBackoff: 1s
+	            backoffMultiplier: 2
+	            retryableStatusCodes:
+	              - UNAVAILABLE
+	        keepalive:
+	            time: 0s
+	            timeout: 20s
+	            permitWithoutStream: false
+	        loadBalancing: pick_first
+
+To see all available configurations, use the config init command.
+
+Add the gRPC client dependency to core:
+
+	var c *core.C = core.New()
+	c.Provide(otgrpc.Providers())
+
+Then you can invoke the client from the application.
+
+	c.Invoke(func(conn *grpc.ClientConn) {
+		client := pb.NewGreeterClient(conn)
+		client.SayHello(context.Background(), &pb.HelloRequest{Name: "world"})
+	})
+
+Inject otgrpc.Maker to factory a *grpc.ClientConn with a specific configuration
+entry.
+
+	c.Invoke(func(maker otgrpc.Maker) {
+		conn, err := maker.Make("default")
+		// do something with conn
+	})
+
+The errors of the calls are converted to *unierr.Error. The calls are traced
+if an opentracing.Tracer is provided, and measured if an
+*otgrpc.RequestDurationSeconds is provided, for example by
+observability.Providers.
+
+The connections are closed when the container shuts down, and when their
+configuration changes on reload. Keep the maker rather than the connection if
+the configuration is reloadable.
+*/
+package otgrpc
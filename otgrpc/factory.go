@@ -0,0 +1,26 @@
+package otgrpc
+
+import (
+	"github.com/DoNewsCode/core/di"
+	"google.golang.org/grpc"
+)
+
+// Maker is models Factory
+type Maker interface {
+	Make(name string) (*grpc.ClientConn, error)
+}
+
+// Factory is a *di.Factory that creates *grpc.ClientConn using a specific
+// configuration entry.
+type Factory struct {
+	*di.Factory
+}
+
+// Make creates *grpc.ClientConn using a specific configuration entry.
+func (r Factory) Make(name string) (*grpc.ClientConn, error) {
+	client, err := r.Factory.Make(name)
+	if err != nil {
+		return nil, err
+	}
+	return client.(*grpc.ClientConn), nil
+}
@@ -0,0 +1,120 @@
+package otgrpc
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/DoNewsCode/core/unierr"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Metrics is a unary client interceptor that records the duration of the calls
+// made by the named client.
+func Metrics(name string, metrics *RequestDurationSeconds) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		metrics.Client(name).Method(method).Code(status.Code(err).String()).Observe(time.Since(start).Seconds())
+		return err
+	}
+}
+
+// Unierr is a unary client interceptor that converts the errors of the calls to
+// *unierr.Error, using unierr.FromStatus.
+func Unierr() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return toUnierr(invoker(ctx, method, req, reply, cc, opts...))
+	}
+}
+
+// UnierrStream is the stream version of Unierr. The errors of creating the
+// stream and of receiving messages are converted.
+func UnierrStream() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			return nil, toUnierr(err)
+		}
+		return &unierrStream{ClientStream: stream}, nil
+	}
+}
+
+type unierrStream struct {
+	grpc.ClientStream
+}
+
+func (u *unierrStream) RecvMsg(m interface{}) error {
+	return toUnierr(u.ClientStream.RecvMsg(m))
+}
+
+func (u *unierrStream) SendMsg(m interface{}) error {
+	return toUnierr(u.ClientStream.SendMsg(m))
+}
+
+// toUnierr converts an error with a status to *unierr.Error. io.EOF, which
+// marks the end of a stream, is kept as is.
+func toUnierr(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); !ok {
+		return err
+	}
+	return unierr.FromStatus(status.Convert(err))
+}
+
+// Timeout is a unary client interceptor that sets a deadline on the calls
+// without one.
+func Timeout(timeout time.Duration) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if _, ok := ctx.Deadline(); !ok {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// Retry is a unary client interceptor that retries the calls failing with one
+// of the codes, up to maxAttempts attempts in total, with the backoff described
+// by RetryPolicy. The calls are retried regardless of the method, so only use
+// it for the services whose methods are idempotent.
+func Retry(maxAttempts int, initial, max time.Duration, multiplier float64, retryable ...codes.Code) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		backoff := float64(initial)
+		for attempt := 1; ; attempt++ {
+			err := invoker(ctx, method, req, reply, cc, opts...)
+			if err == nil || attempt >= maxAttempts || !isRetryable(status.Code(err), retryable) {
+				return err
+			}
+			wait := time.Duration(backoff)
+			if wait > max {
+				wait = max
+			}
+			if wait > 0 {
+				wait = time.Duration(rand.Int63n(int64(wait)))
+			}
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return err
+			case <-timer.C:
+			}
+			backoff *= multiplier
+		}
+	}
+}
+
+func isRetryable(code codes.Code, retryable []codes.Code) bool {
+	for _, c := range retryable {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package otgrpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core/unierr"
+	"github.com/go-kit/kit/metrics"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type histogram struct {
+	labels []string
+	values []float64
+}
+
+func (h *histogram) With(labelValues ...string) metrics.Histogram {
+	h.labels = append(h.labels, labelValues...)
+	return h
+}
+
+func (h *histogram) Observe(value float64) {
+	h.values = append(h.values, value)
+}
+
+type mockStream struct {
+	grpc.ClientStream
+	err error
+}
+
+func (m mockStream) RecvMsg(msg interface{}) error {
+	return m.err
+}
+
+func TestMetrics(t *testing.T) {
+	h := &histogram{}
+	err := Metrics("default", &RequestDurationSeconds{Histogram: h})(context.Background(), "/test/Unary", nil, nil, nil, func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return status.Error(codes.NotFound, "missing")
+	})
+	assert.Error(t, err)
+	assert.Equal(t, []string{"client", "default", "method", "/test/Unary", "code", "NotFound"}, h.labels)
+	assert.Len(t, h.values, 1)
+}
+
+func TestUnierr(t *testing.T) {
+	err := Unierr()(context.Background(), "/test/Unary", nil, nil, nil, func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return status.Error(codes.NotFound, "missing")
+	})
+	var e *unierr.Error
+	assert.True(t, errors.As(err, &e))
+	assert.Equal(t, codes.NotFound, e.GRPCStatus().Code())
+	assert.Equal(t, "missing", e.GRPCStatus().Message())
+
+	streamer := func(err error) grpc.Streamer {
+		return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			return mockStream{err: err}, nil
+		}
+	}
+	stream, err := UnierrStream()(context.Background(), &grpc.StreamDesc{}, nil, "/test/Stream", streamer(status.Error(codes.Aborted, "aborted")))
+	assert.NoError(t, err)
+	assert.True(t, unierr.IsAbortedErr(stream.RecvMsg(nil)))
+
+	stream, err = UnierrStream()(context.Background(), &grpc.StreamDesc{}, nil, "/test/Stream", streamer(io.EOF))
+	assert.NoError(t, err)
+	assert.Equal(t, io.EOF, stream.RecvMsg(nil))
+}
+
+func TestRetry(t *testing.T) {
+	var attempts int
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		attempts++
+		return unierr.New(codes.Unavailable, "unavailable")
+	}
+	err := Retry(3, time.Millisecond, 10*time.Millisecond, 2, codes.Unavailable)(context.Background(), "/test/Unary", nil, nil, nil, invoker)
+	assert.True(t, unierr.IsUnavailableErr(err))
+	assert.Equal(t, 3, attempts)
+
+	attempts = 0
+	err = Retry(3, time.Millisecond, 10*time.Millisecond, 2, codes.Aborted)(context.Background(), "/test/Unary", nil, nil, nil, invoker)
+	assert.Error(t, err)
+	assert.Equal(t, 1, attempts)
+
+	attempts = 0
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = Retry(3, time.Second, time.Second, 2, codes.Unavailable)(ctx, "/test/Unary", nil, nil, nil, invoker)
+	assert.Error(t, err)
+	assert.Equal(t, 1, attempts)
+}
+
+func TestTimeout(t *testing.T) {
+	err := Timeout(time.Second)(context.Background(), "/test/Unary", nil, nil, nil, func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		deadline, ok := ctx.Deadline()
+		assert.True(t, ok)
+		assert.WithinDuration(t, time.Now().Add(time.Second), deadline, 100*time.Millisecond)
+		return nil
+	})
+	assert.NoError(t, err)
+}
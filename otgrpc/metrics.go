@@ -0,0 +1,52 @@
+package otgrpc
+
+import (
+	"github.com/go-kit/kit/metrics"
+)
+
+// RequestDurationSeconds is a Histogram that measures the outgoing gRPC calls.
+// Every attempt of a retried call is observed.
+type RequestDurationSeconds struct {
+	// Histogram is the underlying histogram of RequestDurationSeconds.
+	Histogram metrics.Histogram
+
+	// labels
+	client string
+	method string
+	code   string
+}
+
+// Client specifies the client label for RequestDurationSeconds.
+func (r *RequestDurationSeconds) Client(client string) *RequestDurationSeconds {
+	return &RequestDurationSeconds{
+		Histogram: r.Histogram.With("client", client),
+		client:    client,
+		method:    r.method,
+		code:      r.code,
+	}
+}
+
+// Method specifies the method label for RequestDurationSeconds.
+func (r *RequestDurationSeconds) Method(method string) *RequestDurationSeconds {
+	return &RequestDurationSeconds{
+		Histogram: r.Histogram.With("method", method),
+		client:    r.client,
+		method:    method,
+		code:      r.code,
+	}
+}
+
+// Code specifies the code label for RequestDurationSeconds.
+func (r *RequestDurationSeconds) Code(code string) *RequestDurationSeconds {
+	return &RequestDurationSeconds{
+		Histogram: r.Histogram.With("code", code),
+		client:    r.client,
+		method:    r.method,
+		code:      code,
+	}
+}
+
+// Observe records the time taken by the call.
+func (r RequestDurationSeconds) Observe(seconds float64) {
+	r.Histogram.Observe(seconds)
+}
@@ -33,7 +33,7 @@ Providers is a set of dependencies including ReaderMaker, WriterMaker and export
 		*writerCollector
 */
 func Providers() []interface{} {
-	return []interface{}{provideKafkaFactory, provideConfig}
+	return []interface{}{provideKafkaFactory, provideDefaultReader, provideDefaultWriter, provideConfig}
 }
 
 // WriterMaker models a WriterFactory
@@ -68,10 +68,9 @@ type factoryOut struct {
 	WriterFactory   WriterFactory
 	ReaderMaker     ReaderMaker
 	WriterMaker     WriterMaker
-	Reader          *kafka.Reader
-	Writer          *kafka.Writer
 	ReaderCollector *readerCollector
 	WriterCollector *writerCollector
+	Factories       []*di.Factory `group:"factories,flatten"`
 }
 
 // Module implements di.Modular
@@ -128,13 +127,12 @@ func provideKafkaFactory(p factoryIn) (factoryOut, func(), func(), error) {
 	var writerCollector *writerCollector
 	rf, rc := provideReaderFactory(p)
 	wf, wc := provideWriterFactory(p)
-	dr, err1 := rf.Make("default")
-	if err1 != nil {
-		level.Warn(p.Logger).Log("err", err1)
-	}
-	dw, err2 := wf.Make("default")
-	if err2 != nil {
-		level.Warn(p.Logger).Log("err", err2)
+	for _, factory := range []*di.Factory{rf.Factory, wf.Factory} {
+		if err := factory.Preload(p.Conf); err != nil {
+			rc()
+			wc()
+			return factoryOut{}, nil, nil, err
+		}
 	}
 
 	if p.ReaderStats != nil || p.WriterStats != nil {
@@ -158,13 +156,20 @@ func provideKafkaFactory(p factoryIn) (factoryOut, func(), func(), error) {
 		ReaderFactory:   rf,
 		WriterMaker:     wf,
 		WriterFactory:   wf,
-		Reader:          dr,
-		Writer:          dw,
 		ReaderCollector: readerCollector,
 		WriterCollector: writerCollector,
+		Factories:       []*di.Factory{rf.Factory, wf.Factory},
 	}, wc, rc, nil
 }
 
+func provideDefaultReader(maker ReaderMaker) (*kafka.Reader, error) {
+	return maker.Make("default")
+}
+
+func provideDefaultWriter(maker WriterMaker) (*kafka.Writer, error) {
+	return maker.Make("default")
+}
+
 // provideReaderFactory creates the ReaderFactory. It is valid
 // dependency option for package core.
 func provideReaderFactory(p factoryIn) (ReaderFactory, func()) {
@@ -192,7 +197,7 @@ func provideReaderFactory(p factoryIn) (ReaderFactory, func()) {
 				_ = client.Close()
 			},
 		}, nil
-	})
+	}, di.WithConfigKind("kafka.reader"))
 	return ReaderFactory{factory}, factory.Close
 }
 
@@ -224,7 +229,7 @@ func provideWriterFactory(p factoryIn) (WriterFactory, func()) {
 				_ = writer.Close()
 			},
 		}, nil
-	})
+	}, di.WithConfigKind("kafka.writer"))
 	return WriterFactory{factory}, factory.Close
 }
 
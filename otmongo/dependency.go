@@ -54,13 +54,14 @@ type factoryIn struct {
 type factoryOut struct {
 	dig.Out
 
-	Factory Factory
-	Maker   Maker
+	Factory   Factory
+	Maker     Maker
+	Factories []*di.Factory `group:"factories,flatten"`
 }
 
 // Provide creates Factory and *mongo.Client. It is a valid dependency for
 // package core.
-func provideMongoFactory(p factoryIn) (factoryOut, func()) {
+func provideMongoFactory(p factoryIn) (factoryOut, func(), error) {
 	factory := di.NewFactory(func(name string) (di.Pair, error) {
 		var (
 			conf struct{ URI string }
@@ -90,13 +91,18 @@ func provideMongoFactory(p factoryIn) (factoryOut, func()) {
 				_ = client.Disconnect(context.Background())
 			},
 		}, nil
-	})
+	}, di.WithConfigKind("mongo"))
 	f := Factory{factory}
+	if err := f.Preload(p.Conf); err != nil {
+		factory.Close()
+		return factoryOut{}, nil, err
+	}
 	f.SubscribeReloadEventFrom(p.Dispatcher)
 	return factoryOut{
-		Factory: f,
-		Maker:   f,
-	}, factory.Close
+		Factory:   f,
+		Maker:     f,
+		Factories: []*di.Factory{factory},
+	}, factory.Close, nil
 }
 
 func provideDefaultClient(maker Maker) (*mongo.Client, error) {
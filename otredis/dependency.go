@@ -3,6 +3,7 @@ package otredis
 import (
 	"context"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/DoNewsCode/core/config"
@@ -60,6 +61,7 @@ type factoryOut struct {
 	Maker     Maker
 	Factory   Factory
 	Collector *collector
+	Factories []*di.Factory `group:"factories,flatten"`
 }
 
 // Module implements di.Module
@@ -92,7 +94,7 @@ func (m factoryOut) ProvideRunGroup(group *run.Group) {
 
 // provideRedisFactory creates Factory and redis.UniversalClient. It is a valid
 // dependency for package core.
-func provideRedisFactory(p factoryIn) (factoryOut, func()) {
+func provideRedisFactory(p factoryIn) (factoryOut, func(), error) {
 	factory := di.NewFactory(func(name string) (di.Pair, error) {
 		var (
 			base RedisUniversalOptions
@@ -153,8 +155,12 @@ func provideRedisFactory(p factoryIn) (factoryOut, func()) {
 				_ = client.Close()
 			},
 		}, nil
-	})
+	}, di.WithConfigKind("redis"))
 	redisFactory := Factory{factory}
+	if err := redisFactory.Preload(p.Conf); err != nil {
+		redisFactory.Close()
+		return factoryOut{}, nil, err
+	}
 	redisFactory.SubscribeReloadEventFrom(p.Dispatcher)
 	var collector *collector
 	if p.Gauges != nil {
@@ -166,15 +172,46 @@ func provideRedisFactory(p factoryIn) (factoryOut, func()) {
 		Maker:     redisFactory,
 		Factory:   redisFactory,
 		Collector: collector,
+		Factories: []*di.Factory{factory},
 	}
 
-	return redisOut, redisFactory.Close
+	return redisOut, redisFactory.Close, nil
 }
 
 func provideDefaultClient(maker Maker) (redis.UniversalClient, error) {
 	return maker.Make("default")
 }
 
+// ProvideNamedClients returns the providers that publish every client configured under
+// redis.<name> as a redis.UniversalClient named after it. The unnamed default client provided by
+// Providers is left as is. Each client is only connected when it is injected,
+// and a broken one doesn't fail the others. The names are read once, when this
+// function is called, so entries added by a config reload are only available
+// through the Maker:
+//
+//  c.Provide(otredis.Providers())
+//  c.Provide(otredis.ProvideNamedClients(c))
+//
+//  type cacheIn struct {
+//    di.In
+//    Client redis.UniversalClient `name:"cache"`
+//  }
+func ProvideNamedClients(conf contract.ConfigUnmarshaler) di.Deps {
+	var clients map[string]interface{}
+	_ = conf.Unmarshal("redis", &clients)
+	names := make([]string, 0, len(clients))
+	for name := range clients {
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		names = append(names, "default")
+	}
+	sort.Strings(names)
+	return di.NamedInstances(func(maker Maker, name string) (redis.UniversalClient, error) {
+		return maker.Make(name)
+	}, names...)
+}
+
 type configOut struct {
 	di.Out
 
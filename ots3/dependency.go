@@ -60,12 +60,13 @@ type factoryIn struct {
 type factoryOut struct {
 	di.Out
 
-	Factory Factory
-	Maker   Maker
+	Factory   Factory
+	Maker     Maker
+	Factories []*di.Factory `group:"factories,flatten"`
 }
 
 // provideFactory creates *Factory and *ots3.Manager. It is a valid dependency for package core.
-func provideFactory(p factoryIn) factoryOut {
+func provideFactory(p factoryIn) (factoryOut, error) {
 	factory := di.NewFactory(func(name string) (di.Pair, error) {
 
 		var conf S3Config
@@ -96,15 +97,19 @@ func provideFactory(p factoryIn) factoryOut {
 			Closer: nil,
 			Conn:   manager,
 		}, nil
-	})
+	}, di.WithConfigKind("s3"))
 
 	s3Factory := Factory{factory}
+	if err := s3Factory.Preload(p.Conf); err != nil {
+		return factoryOut{}, err
+	}
 	s3Factory.SubscribeReloadEventFrom(p.Dispatcher)
 
 	return factoryOut{
-		Factory: s3Factory,
-		Maker:   &s3Factory,
-	}
+		Factory:   s3Factory,
+		Maker:     &s3Factory,
+		Factories: []*di.Factory{factory},
+	}, nil
 }
 
 type managerOut struct {
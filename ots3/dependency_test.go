@@ -10,7 +10,7 @@ import (
 )
 
 func TestNewUploadManagerFactory(t *testing.T) {
-	s3out := provideFactory(factoryIn{
+	s3out, _ := provideFactory(factoryIn{
 		Conf: config.MapAdapter{"s3": map[string]S3Config{
 			"default":     {},
 			"alternative": {},
@@ -0,0 +1,206 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/DoNewsCode/core/contract"
+	"github.com/gorilla/mux"
+	"github.com/oklog/run"
+	"github.com/robfig/cron/v3"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+)
+
+type registration struct {
+	name        string
+	constructor interface{}
+}
+
+var registry struct {
+	sync.Mutex
+	modules []registration
+}
+
+// RegisterModule makes a module constructor available under the given name.
+// Packages typically register their modules in an init function, and the
+// application imports them for side effects:
+//
+//  func init() {
+//    core.RegisterModule("orders", NewOrdersModule)
+//  }
+//
+// core.Default adds the registered modules enabled by the config, as if with
+// AddModuleFunc, so that the same binary can run different sets of modules.
+// See ModuleSet for the config. It panics if the name is already taken.
+func RegisterModule(name string, constructor interface{}) {
+	registry.Lock()
+	defer registry.Unlock()
+	if name == "" || constructor == nil {
+		panic("core: module name and constructor must be provided")
+	}
+	for _, r := range registry.modules {
+		if r.name == name {
+			panic(fmt.Sprintf("core: module %s is registered twice", name))
+		}
+	}
+	registry.modules = append(registry.modules, registration{name: name, constructor: constructor})
+}
+
+// RegisteredModules returns the names of the registered modules, in the order
+// of registration.
+func RegisteredModules() []string {
+	registry.Lock()
+	defer registry.Unlock()
+	return namesOf(registry.modules)
+}
+
+// ModuleSet is the effective set of registered modules, resolved by
+// core.Default from the config:
+//
+//  modules:
+//    enabled: [orders, reports]
+//    disabled: [reports]
+//
+// All the registered modules are enabled if "modules.enabled" is empty or
+// contains "*". The modules listed in "modules.disabled" are then removed.
+// Enabling a module that is not registered is an error, while disabling it
+// is not, so that a config can be shared between binaries.
+type ModuleSet struct {
+	Enabled  []string
+	Disabled []string
+}
+
+// String implements fmt.Stringer.
+func (m ModuleSet) String() string {
+	return fmt.Sprintf("enabled: [%s], disabled: [%s]", strings.Join(m.Enabled, ", "), strings.Join(m.Disabled, ", "))
+}
+
+func resolveModules(conf contract.ConfigUnmarshaler, registered []registration) (ModuleSet, []registration, error) {
+	var (
+		set      ModuleSet
+		enabled  []string
+		disabled []string
+		selected []registration
+	)
+	if err := conf.Unmarshal("modules.enabled", &enabled); err != nil {
+		return set, nil, fmt.Errorf("modules.enabled is not valid: %w", err)
+	}
+	if err := conf.Unmarshal("modules.disabled", &disabled); err != nil {
+		return set, nil, fmt.Errorf("modules.disabled is not valid: %w", err)
+	}
+
+	wanted := make(map[string]bool)
+	all := len(enabled) == 0
+	for _, name := range enabled {
+		if name == "*" {
+			all = true
+			continue
+		}
+		wanted[name] = true
+	}
+	off := make(map[string]bool)
+	for _, name := range disabled {
+		off[name] = true
+	}
+
+	for _, r := range registered {
+		if !all && !wanted[r.name] {
+			continue
+		}
+		delete(wanted, r.name)
+		if off[r.name] {
+			set.Disabled = append(set.Disabled, r.name)
+			continue
+		}
+		set.Enabled = append(set.Enabled, r.name)
+		selected = append(selected, r)
+	}
+	for _, name := range enabled {
+		if !wanted[name] {
+			continue
+		}
+		return set, nil, fmt.Errorf("module %s is enabled but not registered, the registered modules are [%s]", name, strings.Join(namesOf(registered), ", "))
+	}
+	return set, selected, nil
+}
+
+func namesOf(registrations []registration) []string {
+	names := make([]string, len(registrations))
+	for i, r := range registrations {
+		names[i] = r.name
+	}
+	return names
+}
+
+// addRegisteredModules adds the enabled registered modules. The constructors
+// are only invoked when the modules are first needed, so that the application
+// can provide their dependencies after core.Default returns.
+func (c *C) addRegisteredModules() {
+	registry.Lock()
+	registered := append([]registration(nil), registry.modules...)
+	registry.Unlock()
+
+	set, selected, err := resolveModules(c.ConfigAccessor, registered)
+	if err != nil {
+		panic(err)
+	}
+	c.provide(func() *ModuleSet { return &set })
+	if len(selected) == 0 {
+		return
+	}
+	c.Container = &lazyContainer{
+		Container: c.Container,
+		load: func() {
+			for _, r := range selected {
+				c.AddModuleFunc(r.constructor)
+			}
+		},
+	}
+}
+
+// lazyContainer runs load before the modules are first used.
+type lazyContainer struct {
+	contract.Container
+	load func()
+}
+
+func (l *lazyContainer) resolve() {
+	if l.load == nil {
+		return
+	}
+	load := l.load
+	l.load = nil
+	load()
+}
+
+func (l *lazyContainer) ApplyRouter(router *mux.Router) {
+	l.resolve()
+	l.Container.ApplyRouter(router)
+}
+
+func (l *lazyContainer) ApplyGRPCServer(server *grpc.Server) {
+	l.resolve()
+	l.Container.ApplyGRPCServer(server)
+}
+
+func (l *lazyContainer) ApplyCron(crontab *cron.Cron) {
+	l.resolve()
+	l.Container.ApplyCron(crontab)
+}
+
+func (l *lazyContainer) ApplyRunGroup(g *run.Group) {
+	l.resolve()
+	l.Container.ApplyRunGroup(g)
+}
+
+func (l *lazyContainer) ApplyRootCommand(command *cobra.Command) {
+	l.resolve()
+	l.Container.ApplyRootCommand(command)
+}
+
+func (l *lazyContainer) Modules() []interface{} {
+	l.resolve()
+	return l.Container.Modules()
+}
@@ -0,0 +1,94 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/DoNewsCode/core/config"
+	"github.com/DoNewsCode/core/di"
+	"github.com/stretchr/testify/assert"
+)
+
+type apiModule struct{ greeting string }
+
+type workerModule struct{}
+
+func withRegistry(t *testing.T, modules ...registration) {
+	registry.Lock()
+	saved := registry.modules
+	registry.modules = nil
+	registry.Unlock()
+	t.Cleanup(func() {
+		registry.Lock()
+		registry.modules = saved
+		registry.Unlock()
+	})
+	for _, m := range modules {
+		RegisterModule(m.name, m.constructor)
+	}
+}
+
+func TestRegisterModule(t *testing.T) {
+	withRegistry(t)
+	RegisterModule("api", func() apiModule { return apiModule{} })
+	RegisterModule("worker", func() workerModule { return workerModule{} })
+	assert.Equal(t, []string{"api", "worker"}, RegisteredModules())
+	assert.Panics(t, func() { RegisterModule("api", func() apiModule { return apiModule{} }) })
+	assert.Panics(t, func() { RegisterModule("", nil) })
+}
+
+func TestResolveModules(t *testing.T) {
+	registered := []registration{{name: "api"}, {name: "worker"}, {name: "cron"}}
+	cases := []struct {
+		name     string
+		conf     map[string]interface{}
+		enabled  []string
+		disabled []string
+		err      bool
+	}{
+		{"default", map[string]interface{}{}, []string{"api", "worker", "cron"}, nil, false},
+		{"enabled", map[string]interface{}{"enabled": []string{"worker", "api"}}, []string{"api", "worker"}, nil, false},
+		{"wildcard", map[string]interface{}{"enabled": []string{"*"}, "disabled": []string{"cron"}}, []string{"api", "worker"}, []string{"cron"}, false},
+		{"disabled", map[string]interface{}{"disabled": []string{"api", "unknown"}}, []string{"worker", "cron"}, []string{"api"}, false},
+		{"unknown", map[string]interface{}{"enabled": []string{"api", "unknown"}}, nil, nil, true},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			set, selected, err := resolveModules(config.MapAdapter{"modules": c.conf}, registered)
+			if c.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, c.enabled, set.Enabled)
+			assert.Equal(t, c.disabled, set.Disabled)
+			assert.Equal(t, c.enabled, namesOf(selected))
+		})
+	}
+}
+
+func TestDefault_registeredModules(t *testing.T) {
+	withRegistry(t,
+		registration{"api", func(greeting string) apiModule { return apiModule{greeting: greeting} }},
+		registration{"worker", func() workerModule { return workerModule{} }},
+	)
+	c := Default(WithInline("modules.enabled", []string{"api"}), WithInline("log.level", "none"))
+	// The dependencies of the registered modules can be provided after Default.
+	c.Provide(di.Deps{func() string { return "hello" }})
+
+	var modules []interface{}
+	for _, m := range c.Modules() {
+		switch m.(type) {
+		case apiModule, workerModule:
+			modules = append(modules, m)
+		}
+	}
+	assert.Equal(t, []interface{}{apiModule{greeting: "hello"}}, modules)
+	c.Invoke(func(set *ModuleSet) {
+		assert.Equal(t, []string{"api"}, set.Enabled)
+		assert.Equal(t, "enabled: [api], disabled: []", set.String())
+	})
+
+	withRegistry(t, registration{"api", func() apiModule { return apiModule{} }})
+	assert.Panics(t, func() { Default(WithInline("modules.enabled", []string{"unknown"})) })
+}
@@ -0,0 +1,241 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/DoNewsCode/core/config"
+)
+
+// restartConf is the config of graceful restarts:
+//
+//  restart:
+//    enable: true
+//    timeout: 30s
+//
+// When enabled, SIGHUP and SIGUSR2 no longer shut the serve command down.
+// Instead, it starts the binary again with the same arguments, handing the
+// listeners of the HTTP and gRPC servers off to the new process. Once the new
+// process is ready, the old one stops accepting connections, drains the
+// pending requests and exits, so that no connection is dropped. The old
+// process keeps serving if the new one fails to get ready within the timeout.
+type restartConf struct {
+	Enable  bool            `json:"enable" yaml:"enable"`
+	Timeout config.Duration `json:"timeout" yaml:"timeout"`
+}
+
+const (
+	// envListeners lists the names and addresses of the listeners handed off
+	// to the new process, as file descriptors from 3.
+	envListeners = "CORE_LISTENERS"
+	// envReadyFD is the pipe written by the new process once it is ready.
+	envReadyFD = "CORE_READY_FD"
+)
+
+type handoff struct {
+	Name string `json:"name"`
+	Addr string `json:"addr"`
+}
+
+// inherited holds the listeners handed off by the previous process.
+var inherited struct {
+	once  sync.Once
+	mu    sync.Mutex
+	files map[string]*os.File
+	addrs map[string]string
+	ready *os.File
+}
+
+func loadInherited() {
+	inherited.once.Do(func() {
+		inherited.files = make(map[string]*os.File)
+		inherited.addrs = make(map[string]string)
+
+		var list []handoff
+		if err := json.Unmarshal([]byte(os.Getenv(envListeners)), &list); err == nil {
+			for i, h := range list {
+				inherited.files[h.Name] = os.NewFile(uintptr(listenFdsStart+i), "inherited:"+h.Name)
+				inherited.addrs[h.Name] = h.Addr
+			}
+		}
+		if fd, err := strconv.Atoi(os.Getenv(envReadyFD)); err == nil {
+			inherited.ready = os.NewFile(uintptr(fd), "ready")
+		}
+		// The variables must not leak into the processes started by this one.
+		_ = os.Unsetenv(envListeners)
+		_ = os.Unsetenv(envReadyFD)
+	})
+}
+
+// adopt returns the listener handed off by the previous process for the
+// server, or nil if there is none. The listener is not adopted if the address
+// of the server has changed in between.
+func adopt(name, addr string) (net.Listener, error) {
+	loadInherited()
+	inherited.mu.Lock()
+	defer inherited.mu.Unlock()
+
+	f, ok := inherited.files[name]
+	if !ok {
+		return nil, nil
+	}
+	delete(inherited.files, name)
+	defer f.Close()
+	if inherited.addrs[name] != addr {
+		return nil, nil
+	}
+	ln, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("unable to adopt the %s listener from the previous process: %w", name, err)
+	}
+	return ln, nil
+}
+
+// notifyReady tells the previous process that this one is ready to serve, if
+// it is started by a graceful restart. The listeners that are not adopted are
+// closed.
+func notifyReady() {
+	loadInherited()
+	inherited.mu.Lock()
+	defer inherited.mu.Unlock()
+
+	for name, f := range inherited.files {
+		_ = f.Close()
+		delete(inherited.files, name)
+	}
+	if inherited.ready != nil {
+		_, _ = inherited.ready.Write([]byte{1})
+		_ = inherited.ready.Close()
+		inherited.ready = nil
+	}
+}
+
+type listenerSetKey struct{}
+
+// listenerSet records the listeners of the serve command, so that they can be
+// handed off to the next process.
+type listenerSet struct {
+	mu        sync.Mutex
+	handoffs  []handoff
+	listeners []net.Listener
+}
+
+// listenersFrom returns the listenerSet of the serve command.
+func listenersFrom(ctx context.Context) *listenerSet {
+	if set, ok := ctx.Value(listenerSetKey{}).(*listenerSet); ok {
+		return set
+	}
+	return &listenerSet{}
+}
+
+// listen adopts the listener of the server from the previous process, or
+// creates a new one.
+func (l *listenerSet) listen(name, addr string) (net.Listener, error) {
+	ln, err := adopt(name, addr)
+	if err != nil {
+		return nil, err
+	}
+	if ln == nil {
+		if ln, err = listen(addr); err != nil {
+			return nil, err
+		}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.handoffs = append(l.handoffs, handoff{Name: name, Addr: addr})
+	l.listeners = append(l.listeners, ln)
+	return ln, nil
+}
+
+// restart starts the command with the listeners, and waits until the new
+// process is ready. It returns an error if the process exits or does not get
+// ready within the timeout, in which case the process is killed.
+func (l *listenerSet) restart(ctx context.Context, timeout time.Duration, args []string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var files []*os.File
+	defer func() {
+		for _, f := range files {
+			_ = f.Close()
+		}
+	}()
+	for i, ln := range l.listeners {
+		filer, ok := ln.(interface{ File() (*os.File, error) })
+		if !ok {
+			return fmt.Errorf("the %s listener %T can not be handed off", l.handoffs[i].Name, ln)
+		}
+		f, err := filer.File()
+		if err != nil {
+			return fmt.Errorf("the %s listener can not be handed off: %w", l.handoffs[i].Name, err)
+		}
+		files = append(files, f)
+	}
+	list, err := json.Marshal(l.handoffs)
+	if err != nil {
+		return err
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Env = append(
+		os.Environ(),
+		fmt.Sprintf("%s=%s", envListeners, list),
+		fmt.Sprintf("%s=%d", envReadyFD, listenFdsStart+len(files)),
+	)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.ExtraFiles = append(files, w)
+	err = cmd.Start()
+	_ = w.Close()
+	if err != nil {
+		return fmt.Errorf("unable to start the new process: %w", err)
+	}
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+	ready := make(chan bool, 1)
+	go func() {
+		// The new process writes a byte once it is ready. The pipe is closed
+		// without it if the process exits before.
+		n, _ := r.Read(make([]byte, 1))
+		ready <- n == 1
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case ok := <-ready:
+		if !ok {
+			return fmt.Errorf("the new process exited: %v", <-exited)
+		}
+	case err := <-exited:
+		return fmt.Errorf("the new process exited: %v", err)
+	case <-timer.C:
+		_ = cmd.Process.Kill()
+		return fmt.Errorf("the new process did not get ready within %s", timeout)
+	case <-ctx.Done():
+		_ = cmd.Process.Kill()
+		return ctx.Err()
+	}
+
+	// The socket files now belong to the new process.
+	for _, ln := range l.listeners {
+		if unix, ok := ln.(*net.UnixListener); ok {
+			unix.SetUnlinkOnClose(false)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package core
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListenerSet_restart(t *testing.T) {
+	switch os.Getenv("CORE_TEST_RESTART") {
+	case "":
+	case "serve":
+		// Running as the new process started below.
+		ln, err := (&listenerSet{}).listen("http", "127.0.0.1:0")
+		if err != nil {
+			os.Exit(1)
+		}
+		notifyReady()
+		conn, err := ln.Accept()
+		if err != nil {
+			os.Exit(1)
+		}
+		conn.Write([]byte("new process"))
+		conn.Close()
+		os.Exit(0)
+	case "hang":
+		time.Sleep(10 * time.Second)
+		os.Exit(0)
+	default:
+		os.Exit(1)
+	}
+	if runtime.GOOS == "windows" {
+		t.Skip("listeners can not be handed off on windows")
+	}
+
+	restart := func(t *testing.T, mode string, timeout time.Duration) (net.Listener, error) {
+		t.Helper()
+		os.Setenv("CORE_TEST_RESTART", mode)
+		defer os.Unsetenv("CORE_TEST_RESTART")
+
+		set := &listenerSet{}
+		ln, err := set.listen("http", "127.0.0.1:0")
+		assert.NoError(t, err)
+		return ln, set.restart(context.Background(), timeout, []string{os.Args[0], "-test.run=^TestListenerSet_restart$"})
+	}
+
+	t.Run("handoff", func(t *testing.T) {
+		ln, err := restart(t, "serve", 5*time.Second)
+		assert.NoError(t, err)
+
+		// The old process stops accepting, and the new one takes over.
+		assert.NoError(t, ln.Close())
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		assert.NoError(t, err)
+		if err == nil {
+			b, _ := ioutil.ReadAll(conn)
+			assert.Equal(t, "new process", string(b))
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		ln, err := restart(t, "hang", 200*time.Millisecond)
+		defer ln.Close()
+		assert.Error(t, err)
+	})
+
+	t.Run("exited", func(t *testing.T) {
+		ln, err := restart(t, "fail", 5*time.Second)
+		defer ln.Close()
+		assert.Error(t, err)
+	})
+}
@@ -1,13 +1,18 @@
 package core
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
 	"fmt"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"runtime"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/DoNewsCode/core/container"
 	"github.com/DoNewsCode/core/contract"
@@ -21,7 +26,10 @@ import (
 	"github.com/pkg/errors"
 	"github.com/robfig/cron/v3"
 	"github.com/spf13/cobra"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 type serveIn struct {
@@ -31,9 +39,12 @@ type serveIn struct {
 	Config     contract.ConfigAccessor
 	Logger     log.Logger
 	Container  contract.Container
-	HTTPServer *http.Server `optional:"true"`
-	GRPCServer *grpc.Server `optional:"true"`
-	Cron       *cron.Cron   `optional:"true"`
+	HTTPServer *http.Server   `optional:"true"`
+	GRPCServer *grpc.Server   `optional:"true"`
+	Cron       *cron.Cron     `optional:"true"`
+	Startup    *StartupReport `optional:"true"`
+	ModuleSet  *ModuleSet     `optional:"true"`
+	Preload    preloadFunc    `optional:"true"`
 }
 
 func NewServeModule(in serveIn) serveModule {
@@ -58,7 +69,45 @@ func (s serveIn) httpServe(ctx context.Context, logger logging.LevelLogger) (fun
 	if s.Config.Bool("http.disable") {
 		return nil, nil, nil
 	}
+	ln, stopReload, err := s.listenHTTP(ctx, logger)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed start http server")
+	}
+	execute, interrupt, err := s.serveHTTP(ctx, logger, ln, nil)
+	if err != nil {
+		stopReload()
+		_ = ln.Close()
+		return nil, nil, errors.Wrap(err, "failed start http server")
+	}
+	return execute, func(err error) {
+		stopReload()
+		interrupt(err)
+	}, nil
+}
+
+// listenHTTP listens on http.addr, with TLS if configured. The certificates
+// are reloaded until stopReload is called.
+func (s serveIn) listenHTTP(ctx context.Context, logger logging.LevelLogger) (ln net.Listener, stopReload func(), err error) {
+	ln, err = listenersFrom(ctx).listen("http", s.Config.String("http.addr"))
+	if err != nil {
+		return nil, nil, err
+	}
+	reloader, err := newTLSReloader(s.Config, "http.tls", []string{"h2", "http/1.1"}, logger)
+	if err != nil {
+		_ = ln.Close()
+		return nil, nil, err
+	}
+	if reloader == nil {
+		return ln, func() {}, nil
+	}
+	return tls.NewListener(ln, reloader.tlsConfig()), reloader.start(ctx, s.Dispatcher), nil
+}
 
+// serveHTTP serves the router on the listener. The router is wrapped with the
+// middlewares configured under http.middleware. If grpcServer is not nil, the
+// gRPC requests are handed to it, and HTTP/2 is accepted over cleartext
+// connections too.
+func (s serveIn) serveHTTP(ctx context.Context, logger logging.LevelLogger, ln net.Listener, grpcServer *grpc.Server) (func() error, func(err error), error) {
 	if s.HTTPServer == nil {
 		s.HTTPServer = &http.Server{}
 	}
@@ -71,13 +120,15 @@ func (s serveIn) httpServe(ctx context.Context, logger logging.LevelLogger) (fun
 		return nil
 	})
 
-	s.HTTPServer.Handler = router
-
-	httpAddr := s.Config.String("http.addr")
-	ln, err := net.Listen("tcp", httpAddr)
+	handler, err := s.httpHandler(router)
 	if err != nil {
-		return nil, nil, errors.Wrap(err, "failed start http server")
+		return nil, nil, err
 	}
+	s.HTTPServer.Handler = handler
+	if grpcServer != nil {
+		s.HTTPServer.Handler = h2c.NewHandler(grpcHandler{grpc: grpcServer, http: handler}, &http2.Server{})
+	}
+
 	return func() error {
 			logger.Infof("http service is listening at %s", ln.Addr())
 			s.Dispatcher.Dispatch(
@@ -101,9 +152,46 @@ func (s serveIn) grpcServe(ctx context.Context, logger logging.LevelLogger) (fun
 	if s.Config.Bool("grpc.disable") {
 		return nil, nil, nil
 	}
+	reloader, err := newTLSReloader(s.Config, "grpc.tls", []string{"h2"}, logger)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed start grpc server")
+	}
+	// The credentials expose the client certificates to the services. A server
+	// provided by the user is served on a TLS listener instead.
+	wrapListener := reloader != nil
 	if s.GRPCServer == nil {
-		s.GRPCServer = grpc.NewServer()
+		opts, err := s.grpcServerOptions()
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "failed start grpc server")
+		}
+		if reloader != nil {
+			opts = append(opts, grpc.Creds(credentials.NewTLS(reloader.tlsConfig())))
+			wrapListener = false
+		}
+		s.GRPCServer = grpc.NewServer(opts...)
+	}
+
+	grpcAddr := s.Config.String("grpc.addr")
+	ln, err := listenersFrom(ctx).listen("grpc", grpcAddr)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed start grpc server")
 	}
+	stopReload := func() {}
+	if reloader != nil {
+		if wrapListener {
+			ln = tls.NewListener(ln, reloader.tlsConfig())
+		}
+		stopReload = reloader.start(ctx, s.Dispatcher)
+	}
+	execute, interrupt := s.serveGRPC(ctx, logger, ln)
+	return execute, func(err error) {
+		stopReload()
+		interrupt(err)
+	}, nil
+}
+
+// applyGRPCServer registers the services of the modules on the gRPC server.
+func (s serveIn) applyGRPCServer(logger logging.LevelLogger) {
 	s.Container.ApplyGRPCServer(s.GRPCServer)
 
 	for module, info := range s.GRPCServer.GetServiceInfo() {
@@ -111,12 +199,12 @@ func (s serveIn) grpcServe(ctx context.Context, logger logging.LevelLogger) (fun
 			level.Debug(logger).Log("service", "grpc", "path", fmt.Sprintf("%s/%s", module, method.Name))
 		}
 	}
+}
+
+// serveGRPC serves the gRPC server on the listener.
+func (s serveIn) serveGRPC(ctx context.Context, logger logging.LevelLogger, ln net.Listener) (func() error, func(err error)) {
+	s.applyGRPCServer(logger)
 
-	grpcAddr := s.Config.String("grpc.addr")
-	ln, err := net.Listen("tcp", grpcAddr)
-	if err != nil {
-		return nil, nil, errors.Wrap(err, "failed start grpc server")
-	}
 	return func() error {
 			logger.Infof("gRPC service is listening at %s", ln.Addr())
 			s.Dispatcher.Dispatch(
@@ -133,7 +221,7 @@ func (s serveIn) grpcServe(ctx context.Context, logger logging.LevelLogger) (fun
 		}, func(err error) {
 			s.GRPCServer.GracefulStop()
 			_ = ln.Close()
-		}, nil
+		}
 }
 
 func (s serveIn) cronServe(ctx context.Context, logger logging.LevelLogger) (func() error, func(err error), error) {
@@ -155,21 +243,57 @@ func (s serveIn) cronServe(ctx context.Context, logger logging.LevelLogger) (fun
 }
 
 func (s serveIn) signalWatch(ctx context.Context, logger logging.LevelLogger) (func() error, func(err error), error) {
+	var conf restartConf
+	if err := s.Config.Unmarshal("restart", &conf); err != nil {
+		return nil, nil, errors.Wrap(err, "the restart config is not valid")
+	}
+	if conf.Enable && len(restartSignals) == 0 {
+		return nil, nil, errors.Errorf("graceful restarts are not supported on %s", runtime.GOOS)
+	}
+	if conf.Timeout.Duration <= 0 {
+		conf.Timeout.Duration = 30 * time.Second
+	}
+	signals := []os.Signal{syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP}
+	if conf.Enable {
+		signals = append(signals, restartSignals...)
+	}
+
 	sig := make(chan os.Signal, 1)
-	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	signal.Notify(sig, signals...)
 	return func() error {
-			select {
-			case n := <-sig:
-				logger.Errf("signal received: %s", n)
-			case <-ctx.Done():
-				logger.Errf(ctx.Err().Error())
+			for {
+				select {
+				case n := <-sig:
+					if n != nil && conf.Enable && isRestartSignal(n) {
+						logger.Infof("signal received: %s, starting the new process", n)
+						if err := listenersFrom(ctx).restart(ctx, conf.Timeout.Duration, os.Args); err != nil {
+							logger.Errf("graceful restart failed, keep serving: %s", err)
+							continue
+						}
+						logger.Info("the new process is ready, draining")
+						return nil
+					}
+					logger.Errf("signal received: %s", n)
+				case <-ctx.Done():
+					logger.Errf(ctx.Err().Error())
+				}
+				return nil
 			}
-			return nil
 		}, func(err error) {
+			signal.Stop(sig)
 			close(sig)
 		}, nil
 }
 
+func isRestartSignal(sig os.Signal) bool {
+	for _, s := range restartSignals {
+		if s == sig {
+			return true
+		}
+	}
+	return false
+}
+
 func newServeCmd(s serveIn) *cobra.Command {
 	var serveCmd = &cobra.Command{
 		Use:   "serve",
@@ -178,14 +302,38 @@ func newServeCmd(s serveIn) *cobra.Command {
 		RunE: func(cmd *cobra.Command, args []string) error {
 
 			var (
-				g run.Group
-				l = logging.WithLevel(s.Logger)
+				g   run.Group
+				l   = logging.WithLevel(s.Logger)
+				ctx = cmd.Context()
 			)
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			// The listeners are recorded to be handed off on graceful restarts.
+			ctx = context.WithValue(ctx, listenerSetKey{}, &listenerSet{})
 
+			if s.ModuleSet != nil {
+				l.Infof("registered modules %s", s.ModuleSet)
+			}
 			for _, m := range s.Container.Modules() {
 				l.Debugf("load module: %T", m)
 			}
 
+			if s.Preload != nil {
+				if err := s.Preload(); err != nil {
+					return err
+				}
+			}
+
+			if s.Startup != nil {
+				var report bytes.Buffer
+				_ = s.Startup.WriteText(&report)
+				for _, line := range strings.Split(strings.TrimSpace(report.String()), "\n") {
+					l.Debugf("startup: %s", strings.TrimSpace(line))
+				}
+				s.Dispatcher.Dispatch(ctx, OnStartupReport, OnStartupReportPayload{Report: s.Startup})
+			}
+
 			// Add serve and signalWatch
 			serves := []runGroupFunc{
 				s.httpServe,
@@ -193,9 +341,16 @@ func newServeCmd(s serveIn) *cobra.Command {
 				s.cronServe,
 				s.signalWatch,
 			}
+			if s.Config.Bool("grpc.multiplex") && !s.Config.Bool("http.disable") && !s.Config.Bool("grpc.disable") {
+				serves = []runGroupFunc{
+					s.muxServe,
+					s.cronServe,
+					s.signalWatch,
+				}
+			}
 
 			for _, serve := range serves {
-				execute, interrupt, err := serve(cmd.Context(), l)
+				execute, interrupt, err := serve(ctx, l)
 				if err != nil {
 					return err
 				}
@@ -208,6 +363,9 @@ func newServeCmd(s serveIn) *cobra.Command {
 			// Additional run groups
 			s.Container.ApplyRunGroup(&g)
 
+			// Let the previous process drain, if started by a graceful restart.
+			notifyReady()
+
 			if err := g.Run(); err != nil {
 				return err
 			}
@@ -0,0 +1,168 @@
+package core
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/events"
+	"github.com/DoNewsCode/core/srvgrpc"
+	"github.com/DoNewsCode/core/srvhttp"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/http2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestC_Serve_multiplex(t *testing.T) {
+	c := New(
+		WithInline("http.addr", "127.0.0.1:0"),
+		WithInline("grpc.addr", "127.0.0.1:-1"),
+		WithInline("grpc.multiplex", true),
+		WithInline("cron.disable", true),
+		WithInline("log.level", "none"),
+	)
+	c.ProvideEssentials()
+	c.AddModule(srvhttp.HealthCheckModule{})
+	c.AddModule(srvgrpc.HealthCheckModule{})
+
+	var shutdown int32
+	httpAddr, grpcAddr := make(chan string, 1), make(chan string, 1)
+	c.Invoke(func(dispatcher contract.Dispatcher) {
+		dispatcher.Subscribe(events.Listen(OnHTTPServerStart, func(ctx context.Context, start interface{}) error {
+			httpAddr <- start.(OnHTTPServerStartPayload).Listener.Addr().String()
+			return nil
+		}))
+		dispatcher.Subscribe(events.Listen(OnGRPCServerStart, func(ctx context.Context, start interface{}) error {
+			grpcAddr <- start.(OnGRPCServerStartPayload).Listener.Addr().String()
+			return nil
+		}))
+		dispatcher.Subscribe(events.Listen(OnHTTPServerShutdown, func(ctx context.Context, event interface{}) error {
+			atomic.AddInt32(&shutdown, 1)
+			return nil
+		}))
+		dispatcher.Subscribe(events.Listen(OnGRPCServerShutdown, func(ctx context.Context, event interface{}) error {
+			atomic.AddInt32(&shutdown, 1)
+			return nil
+		}))
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- c.Serve(ctx)
+	}()
+
+	var addr string
+	for _, ch := range []chan string{httpAddr, grpcAddr} {
+		select {
+		case a := <-ch:
+			if addr != "" {
+				assert.Equal(t, addr, a)
+			}
+			addr = a
+		case <-time.After(5 * time.Second):
+			t.Fatal("the servers did not start")
+		}
+	}
+
+	t.Run("http/1.1", func(t *testing.T) {
+		resp, err := http.Get("http://" + addr + "/live")
+		assert.NoError(t, err)
+		if err == nil {
+			resp.Body.Close()
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, 1, resp.ProtoMajor)
+		}
+	})
+
+	t.Run("h2c", func(t *testing.T) {
+		client := &http.Client{Transport: &http2.Transport{
+			AllowHTTP: true,
+			DialTLS: func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+				return net.Dial(network, addr)
+			},
+		}}
+		// The second request reuses the HTTP/2 connection.
+		for i := 0; i < 2; i++ {
+			resp, err := client.Get("http://" + addr + "/live")
+			assert.NoError(t, err)
+			if err == nil {
+				resp.Body.Close()
+				assert.Equal(t, http.StatusOK, resp.StatusCode)
+				assert.Equal(t, 2, resp.ProtoMajor)
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+	})
+
+	t.Run("grpc", func(t *testing.T) {
+		conn, err := grpc.Dial(addr, grpc.WithInsecure())
+		assert.NoError(t, err)
+		defer conn.Close()
+		health, err := grpc_health_v1.NewHealthClient(conn).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+		assert.NoError(t, err)
+		assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, health.GetStatus())
+	})
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the servers did not stop")
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&shutdown))
+}
+
+func TestC_Serve_multiplexOpenStream(t *testing.T) {
+	c := New(
+		WithInline("http.addr", "127.0.0.1:0"),
+		WithInline("grpc.multiplex", true),
+		WithInline("cron.disable", true),
+		WithInline("log.level", "none"),
+	)
+	c.ProvideEssentials()
+	c.AddModule(srvgrpc.HealthCheckModule{})
+
+	started := make(chan string, 1)
+	c.Invoke(func(dispatcher contract.Dispatcher) {
+		dispatcher.Subscribe(events.Listen(OnHTTPServerStart, func(ctx context.Context, start interface{}) error {
+			started <- start.(OnHTTPServerStartPayload).Listener.Addr().String()
+			return nil
+		}))
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- c.Serve(ctx)
+	}()
+
+	var addr string
+	select {
+	case addr = <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the server did not start")
+	}
+
+	conn, err := grpc.Dial(addr, grpc.WithInsecure())
+	assert.NoError(t, err)
+	defer conn.Close()
+	stream, err := grpc_health_v1.NewHealthClient(conn).Watch(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	assert.NoError(t, err)
+	_, err = stream.Recv()
+	assert.NoError(t, err)
+
+	// The stream is still open while the server shuts down.
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the server did not stop")
+	}
+	_, err = stream.Recv()
+	assert.Error(t, err)
+}
@@ -0,0 +1,11 @@
+//+build !windows
+
+package core
+
+import (
+	"os"
+	"syscall"
+)
+
+// restartSignals trigger graceful restarts, if enabled.
+var restartSignals = []os.Signal{syscall.SIGHUP, syscall.SIGUSR2}
@@ -0,0 +1,9 @@
+package core
+
+import (
+	"os"
+)
+
+// restartSignals trigger graceful restarts, if enabled. Graceful restarts hand
+// the listeners off to a child process, which is not supported on Windows.
+var restartSignals []os.Signal
@@ -0,0 +1,37 @@
+/*
+Package srvgateway exposes the gRPC services of the application as HTTP/JSON
+APIs, using the handlers generated by protoc-gen-grpc-gateway from the
+google.api.http annotations. It saves the duplicate HTTP handlers otherwise
+written by hand for every gRPC service.
+
+Integration
+
+Modules that provide gRPC services register the generated gateway handlers by
+implementing srvgateway.Provider:
+
+	type Module struct{}
+
+	func (m Module) ProvideGRPC(server *grpc.Server) {
+		pb.RegisterGreeterServer(server, greeter{})
+	}
+
+	func (m Module) ProvideGateway(ctx context.Context, mux *runtime.ServeMux, conn *grpc.ClientConn) error {
+		return pb.RegisterGreeterHandler(ctx, mux, conn)
+	}
+
+Then add the gateway module to core:
+
+	var c *core.C = core.New()
+	c.ProvideEssentials()
+	c.AddModule(Module{})
+	c.AddModuleFunc(srvgateway.New)
+
+The gateway calls the gRPC server in process, so the requests go through the
+same interceptors as those of the gRPC clients. The gRPC server must be
+enabled.
+
+Errors are encoded as unierr.Error. The HTTP clients receive the same status
+code and JSON body as they would from any handler using srvhttp.ResponseEncoder,
+while the gRPC clients receive the status of the same error.
+*/
+package srvgateway
@@ -0,0 +1,152 @@
+package srvgateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/DoNewsCode/core"
+	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/di"
+	"github.com/DoNewsCode/core/events"
+	"github.com/DoNewsCode/core/logging"
+	"github.com/DoNewsCode/core/srvhttp"
+	"github.com/DoNewsCode/core/unierr"
+	"github.com/go-kit/kit/log"
+	"github.com/gorilla/mux"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const bufSize = 1024 * 1024
+
+// Provider is implemented by the modules exposing their gRPC services through
+// the gateway. Typically, ProvideGateway calls the RegisterXxxHandler
+// functions generated by protoc-gen-grpc-gateway with the mux and conn.
+type Provider interface {
+	ProvideGateway(ctx context.Context, mux *runtime.ServeMux, conn *grpc.ClientConn) error
+}
+
+// Module is the registration unit for package core. It serves the handlers of
+// every Provider on the HTTP server.
+type Module struct {
+	container contract.Container
+	logger    log.Logger
+	conn      *grpc.ClientConn
+}
+
+// ModuleIn contains the input parameters needed for creating the new module.
+type ModuleIn struct {
+	di.In
+
+	Container  contract.Container
+	Dispatcher contract.Dispatcher
+	Logger     log.Logger
+	Conf       contract.ConfigAccessor
+}
+
+// New creates a Module. The module connects to the gRPC server through an in
+// memory listener, which is served once the gRPC server starts.
+func New(in ModuleIn) (Module, func(), error) {
+	listener := bufconn.Listen(bufSize)
+	if in.Conf.Bool("grpc.disable") {
+		// Fail the calls at once rather than waiting for a server that never starts.
+		_ = listener.Close()
+	}
+	// The dispatcher doesn't support unsubscribing, so the listener is turned
+	// off once the module is closed instead.
+	var closed int32
+	in.Dispatcher.Subscribe(events.Listen(core.OnGRPCServerStart, func(ctx context.Context, event interface{}) error {
+		if atomic.LoadInt32(&closed) == 1 {
+			return nil
+		}
+		server := event.(core.OnGRPCServerStartPayload).GRPCServer
+		go func() {
+			if err := server.Serve(listener); err != nil && atomic.LoadInt32(&closed) == 0 {
+				logging.WithLevel(in.Logger).Errf("the grpc gateway stopped serving: %s", err)
+			}
+		}()
+		return nil
+	}))
+
+	conn, err := grpc.Dial(
+		"bufconn",
+		grpc.WithInsecure(),
+		grpc.WithContextDialer(func(ctx context.Context, s string) (net.Conn, error) {
+			return listener.Dial()
+		}),
+	)
+	if err != nil {
+		return Module{}, nil, fmt.Errorf("unable to connect to the grpc server: %w", err)
+	}
+	return Module{
+		container: in.Container,
+		logger:    in.Logger,
+		conn:      conn,
+	}, func() {
+		atomic.StoreInt32(&closed, 1)
+		_ = conn.Close()
+		_ = listener.Close()
+	}, nil
+}
+
+// ProvideHTTP implements container.HTTPProvider. The gateway handles the
+// requests that match no other route of the router. The requests that match no
+// gateway route either are passed to the NotFoundHandler set on the router
+// before, if any.
+func (m Module) ProvideHTTP(router *mux.Router) {
+	notFound := router.NotFoundHandler
+	gateway := runtime.NewServeMux(
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{
+			MarshalOptions: protojson.MarshalOptions{
+				EmitUnpopulated: true,
+				UseProtoNames:   true,
+			},
+			UnmarshalOptions: protojson.UnmarshalOptions{
+				DiscardUnknown: true,
+			},
+		}),
+		runtime.WithErrorHandler(ErrorHandler),
+		runtime.WithRoutingErrorHandler(func(ctx context.Context, mux *runtime.ServeMux, marshaler runtime.Marshaler, w http.ResponseWriter, r *http.Request, httpStatus int) {
+			if httpStatus == http.StatusNotFound && notFound != nil {
+				notFound.ServeHTTP(w, r)
+				return
+			}
+			runtime.DefaultRoutingErrorHandler(ctx, mux, marshaler, w, r, httpStatus)
+		}),
+	)
+	for _, module := range m.container.Modules() {
+		p, ok := module.(Provider)
+		if !ok {
+			continue
+		}
+		if err := p.ProvideGateway(context.Background(), gateway, m.conn); err != nil {
+			logging.WithLevel(m.logger).Errf("unable to register the gateway handlers of %T: %s", module, err)
+		}
+	}
+	router.NotFoundHandler = gateway
+}
+
+// ErrorHandler is a runtime.ErrorHandlerFunc that encodes the errors as
+// unierr.Error, so that the HTTP clients of the gateway receive the same
+// error body as the gRPC clients.
+func ErrorHandler(ctx context.Context, _ *runtime.ServeMux, _ runtime.Marshaler, w http.ResponseWriter, r *http.Request, err error) {
+	var httpStatus *runtime.HTTPStatusError
+	if errors.As(err, &httpStatus) {
+		err = httpStatus.Err
+	}
+	e := unierr.FromStatus(status.Convert(err))
+	if httpStatus != nil {
+		e.HttpStatusCodeFunc = func(code codes.Code) int {
+			return httpStatus.HTTPStatus
+		}
+	}
+	srvhttp.NewResponseEncoder(w).EncodeError(e)
+}
@@ -0,0 +1,157 @@
+package srvgateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core"
+	"github.com/DoNewsCode/core/container"
+	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/events"
+	"github.com/DoNewsCode/core/srvgateway/internal/testpb"
+	"github.com/DoNewsCode/core/srvhttp"
+	"github.com/DoNewsCode/core/unierr"
+	"github.com/gorilla/mux"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type greeter struct {
+	testpb.UnimplementedGreeterServer
+}
+
+func (g greeter) SayHello(ctx context.Context, req *testpb.HelloRequest) (*testpb.HelloReply, error) {
+	if req.Name == "" {
+		return nil, unierr.New(codes.InvalidArgument, "name is required")
+	}
+	return &testpb.HelloReply{Message: "hello " + req.Name}, nil
+}
+
+type greeterModule struct{}
+
+func (g greeterModule) ProvideGRPC(server *grpc.Server) {
+	testpb.RegisterGreeterServer(server, greeter{})
+}
+
+func (g greeterModule) ProvideGateway(ctx context.Context, mux *runtime.ServeMux, conn *grpc.ClientConn) error {
+	return testpb.RegisterGreeterHandler(ctx, mux, conn)
+}
+
+func TestModule(t *testing.T) {
+	c := core.New(
+		core.WithInline("http.addr", "127.0.0.1:0"),
+		core.WithInline("grpc.addr", "127.0.0.1:0"),
+		core.WithInline("cron.disable", true),
+		core.WithInline("log.level", "none"),
+	)
+	c.ProvideEssentials()
+	c.AddModule(srvhttp.HealthCheckModule{})
+	c.AddModule(greeterModule{})
+	c.AddModuleFunc(New)
+
+	httpAddr, grpcAddr := make(chan string, 1), make(chan string, 1)
+	c.Invoke(func(dispatcher contract.Dispatcher) {
+		dispatcher.Subscribe(events.Listen(core.OnHTTPServerStart, func(ctx context.Context, start interface{}) error {
+			httpAddr <- start.(core.OnHTTPServerStartPayload).Listener.Addr().String()
+			return nil
+		}))
+		dispatcher.Subscribe(events.Listen(core.OnGRPCServerStart, func(ctx context.Context, start interface{}) error {
+			grpcAddr <- start.(core.OnGRPCServerStartPayload).Listener.Addr().String()
+			return nil
+		}))
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Serve(ctx)
+
+	var addrs []string
+	for _, ch := range []chan string{httpAddr, grpcAddr} {
+		select {
+		case addr := <-ch:
+			addrs = append(addrs, addr)
+		case <-time.After(5 * time.Second):
+			t.Fatal("the servers did not start")
+		}
+	}
+	base := "http://" + addrs[0]
+
+	decode := func(t *testing.T, resp *http.Response, v interface{}) {
+		t.Helper()
+		defer resp.Body.Close()
+		b, err := ioutil.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		assert.NoError(t, json.Unmarshal(b, v), string(b))
+	}
+
+	t.Run("get", func(t *testing.T) {
+		resp, err := http.Get(base + "/v1/hello/foo")
+		assert.NoError(t, err)
+		var reply map[string]string
+		decode(t, resp, &reply)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "hello foo", reply["message"])
+	})
+
+	t.Run("post", func(t *testing.T) {
+		resp, err := http.Post(base+"/v1/hello", "application/json", bytes.NewBufferString(`{"name":"bar"}`))
+		assert.NoError(t, err)
+		var reply map[string]string
+		decode(t, resp, &reply)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "hello bar", reply["message"])
+	})
+
+	t.Run("other routes", func(t *testing.T) {
+		resp, err := http.Get(base + "/live")
+		assert.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		conn, err := grpc.Dial(addrs[1], grpc.WithInsecure())
+		assert.NoError(t, err)
+		defer conn.Close()
+		_, grpcErr := testpb.NewGreeterClient(conn).SayHello(context.Background(), &testpb.HelloRequest{})
+		expected, err := unierr.FromStatus(status.Convert(grpcErr)).MarshalJSON()
+		assert.NoError(t, err)
+
+		resp, err := http.Post(base+"/v1/hello", "application/json", bytes.NewBufferString(`{}`))
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		b, _ := ioutil.ReadAll(resp.Body)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.JSONEq(t, string(expected), string(b))
+		assert.JSONEq(t, `{"code":3,"message":"name is required"}`, string(b))
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		resp, err := http.Get(base + "/v1/missing")
+		assert.NoError(t, err)
+		var e unierr.Error
+		decode(t, resp, &e)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		assert.Equal(t, codes.NotFound, status.Convert(&e).Code())
+	})
+}
+
+func TestModule_ProvideHTTP_notFound(t *testing.T) {
+	router := mux.NewRouter()
+	router.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	Module{container: &container.Container{}}.ProvideHTTP(router)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/missing", nil))
+	assert.Equal(t, http.StatusTeapot, w.Code)
+}
@@ -0,0 +1,242 @@
+package srvgrpc
+
+import (
+	"context"
+	"errors"
+	"runtime/debug"
+	"time"
+
+	"github.com/DoNewsCode/core/ctxmeta"
+	"github.com/DoNewsCode/core/logging"
+	"github.com/DoNewsCode/core/unierr"
+	"github.com/go-kit/kit/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Validator is implemented by the request messages that validate themselves,
+// for example those generated by protoc-gen-validate.
+type Validator interface {
+	Validate() error
+}
+
+type wrappedStream struct {
+	grpc.ServerStream
+	ctx     context.Context
+	recvMsg func(m interface{}) error
+}
+
+func (w *wrappedStream) Context() context.Context {
+	return w.ctx
+}
+
+func (w *wrappedStream) RecvMsg(m interface{}) error {
+	if w.recvMsg != nil {
+		return w.recvMsg(m)
+	}
+	return w.ServerStream.RecvMsg(m)
+}
+
+// Recover is a unary interceptor that recovers the panics of the handler. The
+// panic is logged with the stack, and codes.Internal is returned to the
+// client.
+func Recover(logger log.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		defer recoverTo(logger, info.FullMethod, &err)
+		return handler(ctx, req)
+	}
+}
+
+// RecoverStream is the stream interceptor counterpart of Recover.
+func RecoverStream(logger log.Logger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer recoverTo(logger, info.FullMethod, &err)
+		return handler(srv, ss)
+	}
+}
+
+func recoverTo(logger log.Logger, method string, err *error) {
+	p := recover()
+	if p == nil {
+		return
+	}
+	logging.WithLevel(logger).Errw("panic recovered", "method", method, "panic", p, "stack", string(debug.Stack()))
+	*err = status.Error(codes.Internal, "internal server error")
+}
+
+// Log is a unary interceptor logging every request with its method, status
+// code and duration. Failed requests are logged at the warn level.
+func Log(logger log.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		logRequest(ctx, logger, info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+// LogStream is the stream interceptor counterpart of Log.
+func LogStream(logger log.Logger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		logRequest(ss.Context(), logger, info.FullMethod, start, err)
+		return err
+	}
+}
+
+func logRequest(ctx context.Context, logger log.Logger, method string, start time.Time, err error) {
+	code := status.Code(err)
+	fields := []interface{}{"method", method, "code", code.String(), "duration", time.Since(start).String()}
+	if p, ok := peer.FromContext(ctx); ok {
+		fields = append(fields, "peer", p.Addr.String())
+	}
+	if bag := ctxmeta.GetBaggage(ctx); bag != nil {
+		for _, kv := range bag.Slice() {
+			fields = append(fields, kv.Key, kv.Val)
+		}
+	}
+	l := logging.WithLevel(logger)
+	if err != nil {
+		l.Warnw("grpc request failed", append(fields, "err", err)...)
+		return
+	}
+	l.Infow("grpc request", fields...)
+}
+
+// Validate is a unary interceptor that rejects the requests failing their
+// Validate method with codes.InvalidArgument.
+func Validate() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := validate(req); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// ValidateStream is the stream interceptor counterpart of Validate. Every
+// message received from the stream is validated.
+func ValidateStream() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &wrappedStream{
+			ServerStream: ss,
+			ctx:          ss.Context(),
+			recvMsg: func(m interface{}) error {
+				if err := ss.RecvMsg(m); err != nil {
+					return err
+				}
+				return validate(m)
+			},
+		})
+	}
+}
+
+func validate(req interface{}) error {
+	v, ok := req.(Validator)
+	if !ok {
+		return nil
+	}
+	if err := v.Validate(); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return nil
+}
+
+// Baggage is a unary interceptor that injects a ctxmeta.Baggage into the
+// context of the request, if there is none, and copies the incoming metadata
+// of the keys into it.
+func Baggage(keys []string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return handler(injectBaggage(ctx, keys), req)
+	}
+}
+
+// BaggageStream is the stream interceptor counterpart of Baggage.
+func BaggageStream(keys []string) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &wrappedStream{ServerStream: ss, ctx: injectBaggage(ss.Context(), keys)})
+	}
+}
+
+func injectBaggage(ctx context.Context, keys []string) context.Context {
+	bag := ctxmeta.GetBaggage(ctx)
+	if bag == nil {
+		bag, ctx = ctxmeta.Inject(ctx)
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, key := range keys {
+		if values := md.Get(key); len(values) > 0 {
+			_ = bag.Set(key, values[0])
+		}
+	}
+	return ctx
+}
+
+// Deadline is a unary interceptor that bounds the deadline of the requests to
+// max. The requests whose deadline is already exceeded are rejected with
+// codes.DeadlineExceeded.
+func Deadline(max time.Duration) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, cancel := context.WithTimeout(ctx, max)
+		defer cancel()
+		if err := ctx.Err(); err != nil {
+			return nil, status.FromContextError(err).Err()
+		}
+		return handler(ctx, req)
+	}
+}
+
+// DeadlineStream is the stream interceptor counterpart of Deadline.
+func DeadlineStream(max time.Duration) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, cancel := context.WithTimeout(ss.Context(), max)
+		defer cancel()
+		if err := ctx.Err(); err != nil {
+			return status.FromContextError(err).Err()
+		}
+		return handler(srv, &wrappedStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// Unierr is a unary interceptor that converts the errors returned by the
+// handler into unierr.Error, so that the clients receive a proper status even
+// if the unierr.Error is wrapped. Errors with a status are kept as is. Context
+// errors are converted to codes.Canceled and codes.DeadlineExceeded, and other
+// errors to codes.Unknown.
+func Unierr() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		resp, err := handler(ctx, req)
+		return resp, toUnierr(err)
+	}
+}
+
+// UnierrStream is the stream interceptor counterpart of Unierr.
+func UnierrStream() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return toUnierr(handler(srv, ss))
+	}
+}
+
+func toUnierr(err error) error {
+	if err == nil {
+		return nil
+	}
+	var e *unierr.Error
+	if errors.As(err, &e) {
+		return e
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	switch {
+	case errors.Is(err, context.Canceled):
+		return unierr.CanceledErr(err)
+	case errors.Is(err, context.DeadlineExceeded):
+		return unierr.DeadlineExceededErr(err)
+	}
+	return unierr.UnknownErr(err)
+}
@@ -0,0 +1,195 @@
+package srvgrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core/ctxmeta"
+	"github.com/DoNewsCode/core/unierr"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type mockStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	msgs []interface{}
+}
+
+func (m *mockStream) Context() context.Context {
+	return m.ctx
+}
+
+func (m *mockStream) RecvMsg(msg interface{}) error {
+	if len(m.msgs) == 0 {
+		return errors.New("eof")
+	}
+	*(msg.(*request)) = *(m.msgs[0].(*request))
+	m.msgs = m.msgs[1:]
+	return nil
+}
+
+func (m *mockStream) SendMsg(msg interface{}) error {
+	return nil
+}
+
+func (m *mockStream) SetHeader(md metadata.MD) error {
+	return nil
+}
+
+type request struct {
+	valid bool
+}
+
+func (r *request) Validate() error {
+	if !r.valid {
+		return errors.New("invalid request")
+	}
+	return nil
+}
+
+var (
+	unaryInfo  = &grpc.UnaryServerInfo{FullMethod: "/test/Unary"}
+	streamInfo = &grpc.StreamServerInfo{FullMethod: "/test/Stream"}
+)
+
+func TestRecover(t *testing.T) {
+	_, err := Recover(log.NewNopLogger())(context.Background(), nil, unaryInfo, func(ctx context.Context, req interface{}) (interface{}, error) {
+		panic("boom")
+	})
+	assert.Equal(t, codes.Internal, status.Code(err))
+
+	err = RecoverStream(log.NewNopLogger())(nil, &mockStream{ctx: context.Background()}, streamInfo, func(srv interface{}, stream grpc.ServerStream) error {
+		panic("boom")
+	})
+	assert.Equal(t, codes.Internal, status.Code(err))
+}
+
+func TestLog(t *testing.T) {
+	var logged []interface{}
+	logger := log.LoggerFunc(func(kv ...interface{}) error {
+		logged = kv
+		return nil
+	})
+	ctx := context.Background()
+	bag, ctx := ctxmeta.Inject(ctx)
+	bag.Set("x-request-id", "foo")
+
+	_, err := Log(logger)(ctx, nil, unaryInfo, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Error(codes.NotFound, "missing")
+	})
+	assert.Error(t, err)
+	assert.Contains(t, logged, "/test/Unary")
+	assert.Contains(t, logged, "NotFound")
+	assert.Contains(t, logged, "foo")
+
+	err = LogStream(logger)(nil, &mockStream{ctx: ctx}, streamInfo, func(srv interface{}, stream grpc.ServerStream) error {
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Contains(t, logged, "/test/Stream")
+	assert.Contains(t, logged, "OK")
+}
+
+func TestValidate(t *testing.T) {
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return req, nil
+	}
+	_, err := Validate()(context.Background(), &request{valid: false}, unaryInfo, handler)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = Validate()(context.Background(), &request{valid: true}, unaryInfo, handler)
+	assert.NoError(t, err)
+
+	stream := &mockStream{ctx: context.Background(), msgs: []interface{}{&request{valid: true}, &request{valid: false}}}
+	err = ValidateStream()(nil, stream, streamInfo, func(srv interface{}, stream grpc.ServerStream) error {
+		for {
+			if err := stream.RecvMsg(&request{}); err != nil {
+				return err
+			}
+		}
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestBaggage(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-request-id", "foo", "x-other", "bar"))
+	check := func(ctx context.Context) {
+		m := ctxmeta.GetBaggage(ctx).Map()
+		assert.Equal(t, map[string]interface{}{"x-request-id": "foo"}, m)
+	}
+	_, err := Baggage([]string{"x-request-id"})(ctx, nil, unaryInfo, func(ctx context.Context, req interface{}) (interface{}, error) {
+		check(ctx)
+		return nil, nil
+	})
+	assert.NoError(t, err)
+
+	err = BaggageStream([]string{"x-request-id"})(nil, &mockStream{ctx: ctx}, streamInfo, func(srv interface{}, stream grpc.ServerStream) error {
+		check(stream.Context())
+		return nil
+	})
+	assert.NoError(t, err)
+}
+
+func TestDeadline(t *testing.T) {
+	_, err := Deadline(time.Second)(context.Background(), nil, unaryInfo, func(ctx context.Context, req interface{}) (interface{}, error) {
+		deadline, ok := ctx.Deadline()
+		assert.True(t, ok)
+		assert.WithinDuration(t, time.Now().Add(time.Second), deadline, 100*time.Millisecond)
+		return nil, nil
+	})
+	assert.NoError(t, err)
+
+	// A shorter deadline of the client is kept.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = DeadlineStream(time.Second)(nil, &mockStream{ctx: ctx}, streamInfo, func(srv interface{}, stream grpc.ServerStream) error {
+		deadline, _ := stream.Context().Deadline()
+		expected, _ := ctx.Deadline()
+		assert.Equal(t, expected, deadline)
+		return nil
+	})
+	assert.NoError(t, err)
+
+	<-ctx.Done()
+	_, err = Deadline(time.Second)(ctx, nil, unaryInfo, func(ctx context.Context, req interface{}) (interface{}, error) {
+		t.Fatal("the handler should not be called")
+		return nil, nil
+	})
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+}
+
+func TestUnierr(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		code codes.Code
+	}{
+		{"nil", nil, codes.OK},
+		{"wrapped", fmt.Errorf("wrapped: %w", unierr.New(codes.NotFound, "missing")), codes.NotFound},
+		{"status", status.Error(codes.Aborted, "aborted"), codes.Aborted},
+		{"canceled", fmt.Errorf("wrapped: %w", context.Canceled), codes.Canceled},
+		{"deadline", context.DeadlineExceeded, codes.DeadlineExceeded},
+		{"unknown", errors.New("foo"), codes.Unknown},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			_, err := Unierr()(context.Background(), nil, unaryInfo, func(ctx context.Context, req interface{}) (interface{}, error) {
+				return nil, c.err
+			})
+			assert.Equal(t, c.code, status.Code(err))
+
+			err = UnierrStream()(nil, &mockStream{ctx: context.Background()}, streamInfo, func(srv interface{}, stream grpc.ServerStream) error {
+				return c.err
+			})
+			assert.Equal(t, c.code, status.Code(err))
+		})
+	}
+}
@@ -36,6 +36,43 @@ func Metrics(metrics *RequestDurationSeconds) grpc.UnaryServerInterceptor {
 	}
 }
 
+// MetricsStream is a stream interceptor for grpc package. It records the
+// duration of the whole stream in the same histogram as Metrics, and counts the
+// messages sent and received if messages is not nil.
+func MetricsStream(metrics *RequestDurationSeconds, messages *StreamMessages) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		defer func() {
+			metrics.Route(info.FullMethod).Observe(time.Since(start).Seconds())
+		}()
+		if messages != nil {
+			ss = &countingStream{ServerStream: ss, messages: messages.Route(info.FullMethod)}
+		}
+		return handler(srv, ss)
+	}
+}
+
+type countingStream struct {
+	grpc.ServerStream
+	messages *StreamMessages
+}
+
+func (c *countingStream) SendMsg(m interface{}) error {
+	err := c.ServerStream.SendMsg(m)
+	if err == nil {
+		c.messages.Sent.Add(1)
+	}
+	return err
+}
+
+func (c *countingStream) RecvMsg(m interface{}) error {
+	err := c.ServerStream.RecvMsg(m)
+	if err == nil {
+		c.messages.Received.Add(1)
+	}
+	return err
+}
+
 // RequestDurationSeconds is a Histogram that measures the request latency.
 type RequestDurationSeconds struct {
 	// Histogram is the underlying histogram of RequestDurationSeconds.
@@ -81,3 +118,49 @@ func (r *RequestDurationSeconds) Route(route string) *RequestDurationSeconds {
 func (r RequestDurationSeconds) Observe(seconds float64) {
 	r.Histogram.Observe(seconds)
 }
+
+// StreamMessages counts the messages sent and received by streaming RPCs.
+type StreamMessages struct {
+	// Sent is the counter of the messages sent to the client.
+	Sent metrics.Counter
+	// Received is the counter of the messages received from the client.
+	Received metrics.Counter
+
+	// labels
+	module  string
+	service string
+	route   string
+}
+
+// Module specifies the module label for StreamMessages.
+func (s *StreamMessages) Module(module string) *StreamMessages {
+	return &StreamMessages{
+		Sent:     s.Sent.With("module", module),
+		Received: s.Received.With("module", module),
+		module:   module,
+		service:  s.service,
+		route:    s.route,
+	}
+}
+
+// Service specifies the service label for StreamMessages.
+func (s *StreamMessages) Service(service string) *StreamMessages {
+	return &StreamMessages{
+		Sent:     s.Sent.With("service", service),
+		Received: s.Received.With("service", service),
+		module:   s.module,
+		service:  service,
+		route:    s.route,
+	}
+}
+
+// Route specifies the method label for StreamMessages.
+func (s *StreamMessages) Route(route string) *StreamMessages {
+	return &StreamMessages{
+		Sent:     s.Sent.With("route", route),
+		Received: s.Received.With("route", route),
+		module:   s.module,
+		service:  s.service,
+		route:    route,
+	}
+}
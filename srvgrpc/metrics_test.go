@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/go-kit/kit/metrics"
 	"github.com/go-kit/kit/metrics/generic"
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/grpc"
@@ -27,3 +28,46 @@ func TestRequestDurationSeconds(t *testing.T) {
 	_, _ = Metrics(rds)(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/"}, f)
 	assert.GreaterOrEqual(t, 1.0, rds.Histogram.(*generic.Histogram).Quantile(0.5))
 }
+
+// labeledCounter keeps the count across the labels, unlike generic.Counter.
+type labeledCounter struct {
+	*generic.Counter
+}
+
+func (l labeledCounter) With(labelValues ...string) metrics.Counter {
+	return l
+}
+
+func TestMetricsStream(t *testing.T) {
+	rds := &RequestDurationSeconds{
+		Histogram: generic.NewHistogram("foo", 2),
+	}
+	messages := &StreamMessages{
+		Sent:     labeledCounter{generic.NewCounter("sent")},
+		Received: labeledCounter{generic.NewCounter("received")},
+	}
+	rds = rds.Module("m").Service("s")
+	messages = messages.Module("m").Service("s")
+
+	stream := &mockStream{ctx: context.Background(), msgs: []interface{}{&request{}, &request{}}}
+	err := MetricsStream(rds, messages)(nil, stream, &grpc.StreamServerInfo{FullMethod: "/test/Stream"}, func(srv interface{}, stream grpc.ServerStream) error {
+		time.Sleep(time.Millisecond)
+		for stream.RecvMsg(&request{}) == nil {
+			_ = stream.SendMsg(&request{})
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+
+	assert.Less(t, 0.0, rds.Histogram.(*generic.Histogram).Quantile(0.5))
+	assert.ElementsMatch(t, []string{"module", "m", "service", "s", "route", "/test/Stream"}, rds.Route("/test/Stream").Histogram.(*generic.Histogram).LabelValues())
+	assert.Equal(t, 2.0, messages.Sent.(labeledCounter).Value())
+	assert.Equal(t, 2.0, messages.Received.(labeledCounter).Value())
+
+	labeled := (&StreamMessages{
+		Sent:     generic.NewCounter("sent"),
+		Received: generic.NewCounter("received"),
+	}).Module("m").Service("s").Route("r")
+	assert.ElementsMatch(t, []string{"module", "m", "service", "s", "route", "r"}, labeled.Sent.(*generic.Counter).LabelValues())
+	assert.ElementsMatch(t, []string{"module", "m", "service", "s", "route", "r"}, labeled.Received.(*generic.Counter).LabelValues())
+}
@@ -1,8 +1,64 @@
 package srvgrpc
 
-import "github.com/opentracing-contrib/go-grpc"
+import (
+	"io"
+
+	"github.com/opentracing-contrib/go-grpc"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"google.golang.org/grpc"
+)
 
 // Trace is an alias of otgrpc.OpenTracingServerInterceptor. It is recommended to use the trace
 // implementation in github.com/opentracing-contrib/go-grpc. This alias serves
 // as a pointer to it.
 var Trace = otgrpc.OpenTracingServerInterceptor
+
+// TraceStream is a stream interceptor for grpc package. On top of the span of
+// the stream created by otgrpc.OpenTracingStreamServerInterceptor, every
+// message sent or received is recorded in a child span.
+func TraceStream(tracer opentracing.Tracer, options ...otgrpc.Option) grpc.StreamServerInterceptor {
+	interceptor := otgrpc.OpenTracingStreamServerInterceptor(tracer, options...)
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return interceptor(srv, ss, info, func(srv interface{}, ss grpc.ServerStream) error {
+			return handler(srv, &tracingStream{ServerStream: ss, tracer: tracer, method: info.FullMethod})
+		})
+	}
+}
+
+type tracingStream struct {
+	grpc.ServerStream
+	tracer opentracing.Tracer
+	method string
+}
+
+func (t *tracingStream) SendMsg(m interface{}) error {
+	span := t.startSpan("SendMsg")
+	defer span.Finish()
+	err := t.ServerStream.SendMsg(m)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogKV("error", err.Error())
+	}
+	return err
+}
+
+func (t *tracingStream) RecvMsg(m interface{}) error {
+	span := t.startSpan("RecvMsg")
+	defer span.Finish()
+	err := t.ServerStream.RecvMsg(m)
+	// io.EOF marks the end of the client stream rather than a failure.
+	if err != nil && err != io.EOF {
+		ext.Error.Set(span, true)
+		span.LogKV("error", err.Error())
+	}
+	return err
+}
+
+func (t *tracingStream) startSpan(operation string) opentracing.Span {
+	var opts []opentracing.StartSpanOption
+	if parent := opentracing.SpanFromContext(t.Context()); parent != nil {
+		opts = append(opts, opentracing.ChildOf(parent.Context()))
+	}
+	return t.tracer.StartSpan(t.method+"/"+operation, opts...)
+}
@@ -0,0 +1,34 @@
+package srvgrpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+func TestTraceStream(t *testing.T) {
+	tracer := mocktracer.New()
+	stream := &mockStream{ctx: context.Background(), msgs: []interface{}{&request{}}}
+	err := TraceStream(tracer)(nil, stream, &grpc.StreamServerInfo{FullMethod: "/test/Stream"}, func(srv interface{}, stream grpc.ServerStream) error {
+		assert.NoError(t, stream.RecvMsg(&request{}))
+		assert.NoError(t, stream.SendMsg(&request{}))
+		assert.Error(t, stream.RecvMsg(&request{}))
+		return errors.New("foo")
+	})
+	assert.Error(t, err)
+
+	spans := tracer.FinishedSpans()
+	assert.Len(t, spans, 4)
+	parent := spans[3]
+	assert.Equal(t, "/test/Stream", parent.OperationName)
+	for i, operation := range []string{"/test/Stream/RecvMsg", "/test/Stream/SendMsg", "/test/Stream/RecvMsg"} {
+		assert.Equal(t, operation, spans[i].OperationName)
+		assert.Equal(t, parent.SpanContext.SpanID, spans[i].ParentID)
+	}
+	assert.Nil(t, spans[0].Tag("error"))
+	assert.Equal(t, true, spans[2].Tag("error"))
+}
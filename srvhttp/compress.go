@@ -0,0 +1,129 @@
+package srvhttp
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Compress is a middleware that compresses the responses with brotli or gzip,
+// as accepted by the client. Brotli is preferred over gzip. Responses that
+// already have a Content-Encoding are sent as is, and so are server-sent
+// events, whose messages must reach the client as soon as they are flushed.
+func Compress() func(handler http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			writer.Header().Add("Vary", "Accept-Encoding")
+			encoding := acceptedEncoding(request.Header.Get("Accept-Encoding"))
+			if encoding == "" || request.Method == http.MethodHead {
+				handler.ServeHTTP(writer, request)
+				return
+			}
+			cw := &compressWriter{ResponseWriter: writer, encoding: encoding}
+			defer cw.Close()
+			handler.ServeHTTP(cw, request)
+		})
+	}
+}
+
+// acceptedEncoding returns the preferred encoding accepted by the client, or
+// an empty string if none is supported.
+func acceptedEncoding(header string) string {
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if f, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err == nil {
+					q = f
+				}
+			}
+		}
+		accepted[name] = q > 0
+	}
+	for _, encoding := range []string{"br", "gzip"} {
+		if accepted[encoding] {
+			return encoding
+		}
+	}
+	return ""
+}
+
+type compressWriter struct {
+	http.ResponseWriter
+	encoding    string
+	w           io.WriteCloser
+	wroteHeader bool
+}
+
+func (c *compressWriter) WriteHeader(code int) {
+	if c.wroteHeader {
+		return
+	}
+	c.wroteHeader = true
+	h := c.Header()
+	if h.Get("Content-Encoding") == "" && code != http.StatusNoContent && code != http.StatusNotModified &&
+		!strings.HasPrefix(h.Get("Content-Type"), "text/event-stream") {
+		h.Set("Content-Encoding", c.encoding)
+		h.Del("Content-Length")
+		switch c.encoding {
+		case "br":
+			c.w = brotli.NewWriter(c.ResponseWriter)
+		case "gzip":
+			c.w = gzip.NewWriter(c.ResponseWriter)
+		}
+	}
+	c.ResponseWriter.WriteHeader(code)
+}
+
+func (c *compressWriter) Write(b []byte) (int, error) {
+	if !c.wroteHeader {
+		if c.Header().Get("Content-Type") == "" {
+			c.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		c.WriteHeader(http.StatusOK)
+	}
+	if c.w == nil {
+		return c.ResponseWriter.Write(b)
+	}
+	return c.w.Write(b)
+}
+
+// Flush implements http.Flusher. Flushing before any write sends the header,
+// so the encoding is decided at this point.
+func (c *compressWriter) Flush() {
+	if !c.wroteHeader {
+		c.WriteHeader(http.StatusOK)
+	}
+	if f, ok := c.w.(interface{ Flush() error }); ok {
+		_ = f.Flush()
+	}
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker.
+func (c *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := c.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, fmt.Errorf("%T is not a http.Hijacker", c.ResponseWriter)
+}
+
+func (c *compressWriter) Close() error {
+	if c.w == nil {
+		return nil
+	}
+	return c.w.Close()
+}
@@ -0,0 +1,96 @@
+package srvhttp
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompress(t *testing.T) {
+	body := strings.Repeat("hello world ", 100)
+	handler := Compress()(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.URL.Path == "/encoded" {
+			writer.Header().Set("Content-Encoding", "identity")
+		}
+		writer.Write([]byte(body))
+	}))
+
+	cases := []struct {
+		name           string
+		path           string
+		acceptEncoding string
+		encoding       string
+		reader         func(r io.Reader) io.Reader
+	}{
+		{"brotli", "/", "gzip, deflate, br", "br", func(r io.Reader) io.Reader {
+			return brotli.NewReader(r)
+		}},
+		{"gzip", "/", "gzip, br;q=0", "gzip", func(r io.Reader) io.Reader {
+			gr, err := gzip.NewReader(r)
+			assert.NoError(t, err)
+			return gr
+		}},
+		{"none", "/", "deflate", "", func(r io.Reader) io.Reader {
+			return r
+		}},
+		{"already encoded", "/encoded", "gzip", "identity", func(r io.Reader) io.Reader {
+			return r
+		}},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, c.path, nil)
+			r.Header.Set("Accept-Encoding", c.acceptEncoding)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			assert.Equal(t, c.encoding, w.Header().Get("Content-Encoding"))
+			assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+			assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+			b, err := ioutil.ReadAll(c.reader(w.Body))
+			assert.NoError(t, err)
+			assert.Equal(t, body, string(b))
+		})
+	}
+}
+
+func TestCompress_flush(t *testing.T) {
+	handler := Compress()(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.URL.Path == "/events" {
+			writer.Header().Set("Content-Type", "text/event-stream")
+		}
+		// Streaming handlers usually flush the header before writing.
+		writer.(http.Flusher).Flush()
+		writer.Write([]byte("data: hello\n\n"))
+		writer.(http.Flusher).Flush()
+	}))
+
+	t.Run("stream", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+		gr, err := gzip.NewReader(w.Body)
+		assert.NoError(t, err)
+		b, err := ioutil.ReadAll(gr)
+		assert.NoError(t, err)
+		assert.Equal(t, "data: hello\n\n", string(b))
+	})
+
+	t.Run("server-sent events", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/events", nil)
+		r.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		assert.Equal(t, "", w.Header().Get("Content-Encoding"))
+		assert.Equal(t, "data: hello\n\n", w.Body.String())
+	})
+}
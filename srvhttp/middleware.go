@@ -0,0 +1,294 @@
+package srvhttp
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/DoNewsCode/core/ctxmeta"
+	"github.com/DoNewsCode/core/logging"
+	"github.com/DoNewsCode/core/unierr"
+	"github.com/go-kit/kit/log"
+	"github.com/gorilla/handlers"
+	"google.golang.org/grpc/codes"
+)
+
+// RequestIDKey is the key of the request id in the ctxmeta.Baggage.
+const RequestIDKey = "request_id"
+
+// maxRequestIDLength is the maximum length of a request id sent by a client.
+const maxRequestIDLength = 128
+
+// RequestID is a middleware that propagates the request id found in the
+// header, or generates one if missing. The id is sent back in the same
+// response header, and set in the ctxmeta.Baggage of the request under
+// RequestIDKey. A baggage is injected if there is none. As the header comes
+// from the client, an id longer than 128 bytes or with characters other than
+// letters, digits and "-_.:+/=" is replaced by a generated one.
+func RequestID(header string) func(handler http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			id := request.Header.Get(header)
+			if !validRequestID(id) {
+				id = newRequestID()
+				request.Header.Set(header, id)
+			}
+			writer.Header().Set(header, id)
+
+			ctx := request.Context()
+			bag := ctxmeta.GetBaggage(ctx)
+			if bag == nil {
+				bag, ctx = ctxmeta.Inject(ctx)
+			}
+			_ = bag.Set(RequestIDKey, id)
+			handler.ServeHTTP(writer, request.WithContext(ctx))
+		})
+	}
+}
+
+// RequestIDFrom returns the request id set by the RequestID middleware, or
+// an empty string if there is none.
+func RequestIDFrom(ctx context.Context) string {
+	var id string
+	_ = ctxmeta.GetBaggage(ctx).Unmarshal(RequestIDKey, &id)
+	return id
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case strings.IndexByte("-_.:+/=", c) >= 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// Recover is a middleware that recovers the panics of the handler. The panic
+// is logged with the stack, and an internal unierr.Error is sent to the
+// client in place of the response. If the handler has written anything
+// already, the response is aborted instead, so that the client doesn't take a
+// partial response for a complete one.
+func Recover(logger log.Logger) func(handler http.Handler) http.Handler {
+	l := logging.WithLevel(logger)
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, request *http.Request) {
+			writer := &recoverWriter{ResponseWriter: w}
+			defer func() {
+				p := recover()
+				if p == nil {
+					return
+				}
+				// ErrAbortHandler aborts the response on purpose.
+				if p == http.ErrAbortHandler {
+					panic(p)
+				}
+				l.Errw("panic recovered", "method", request.Method, "path", request.URL.Path, "panic", p, "stack", string(debug.Stack()))
+				if writer.written {
+					panic(http.ErrAbortHandler)
+				}
+				NewResponseEncoder(w).EncodeError(unierr.New(codes.Internal, "internal server error"))
+			}()
+			handler.ServeHTTP(writer, request)
+		})
+	}
+}
+
+// recoverWriter records whether the response has been started.
+type recoverWriter struct {
+	http.ResponseWriter
+	written bool
+}
+
+func (r *recoverWriter) WriteHeader(code int) {
+	r.written = true
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *recoverWriter) Write(b []byte) (int, error) {
+	r.written = true
+	return r.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher.
+func (r *recoverWriter) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		r.written = true
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker.
+func (r *recoverWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := r.ResponseWriter.(http.Hijacker); ok {
+		r.written = true
+		return h.Hijack()
+	}
+	return nil, nil, fmt.Errorf("%T is not a http.Hijacker", r.ResponseWriter)
+}
+
+// CORSOptions configures the CORS middleware. Empty lists fall back to the
+// defaults of github.com/gorilla/handlers.
+type CORSOptions struct {
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	// MaxAge is the time the preflight responses can be cached, in seconds.
+	MaxAge int
+}
+
+// CORS is a middleware implementing Cross-Origin Resource Sharing. It uses
+// github.com/gorilla/handlers underneath.
+func CORS(options CORSOptions) func(handler http.Handler) http.Handler {
+	var opts []handlers.CORSOption
+	if len(options.AllowedOrigins) > 0 {
+		opts = append(opts, handlers.AllowedOrigins(options.AllowedOrigins))
+	}
+	if len(options.AllowedMethods) > 0 {
+		opts = append(opts, handlers.AllowedMethods(options.AllowedMethods))
+	}
+	if len(options.AllowedHeaders) > 0 {
+		opts = append(opts, handlers.AllowedHeaders(options.AllowedHeaders))
+	}
+	if len(options.ExposedHeaders) > 0 {
+		opts = append(opts, handlers.ExposedHeaders(options.ExposedHeaders))
+	}
+	if options.AllowCredentials {
+		opts = append(opts, handlers.AllowCredentials())
+	}
+	if options.MaxAge > 0 {
+		opts = append(opts, handlers.MaxAge(options.MaxAge))
+	}
+	return handlers.CORS(opts...)
+}
+
+// BodyLimit is a middleware that limits the size of request bodies to
+// maxBytes. Requests declaring a larger Content-Length are rejected at once
+// with 413, and reading past the limit fails otherwise.
+func BodyLimit(maxBytes int64) func(handler http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			if request.ContentLength > maxBytes {
+				err := unierr.Newf(codes.InvalidArgument, "request body exceeds %d bytes", maxBytes)
+				err.HttpStatusCodeFunc = func(code codes.Code) int {
+					return http.StatusRequestEntityTooLarge
+				}
+				NewResponseEncoder(writer).EncodeError(err)
+				return
+			}
+			request.Body = http.MaxBytesReader(writer, request.Body, maxBytes)
+			handler.ServeHTTP(writer, request)
+		})
+	}
+}
+
+// Timeout is a middleware that cancels the context of the request after the
+// timeout. Handlers are expected to give up once the context is done.
+func Timeout(timeout time.Duration) func(handler http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			ctx, cancel := context.WithTimeout(request.Context(), timeout)
+			defer cancel()
+			handler.ServeHTTP(writer, request.WithContext(ctx))
+		})
+	}
+}
+
+// RealIP is a middleware that sets the RemoteAddr of the request to the
+// client IP reported by the X-Forwarded-For or X-Real-IP headers. The headers
+// are only trusted if the request comes from one of the trusted proxies. The
+// client IP is the rightmost address in X-Forwarded-For not belonging to a
+// trusted proxy.
+func RealIP(trustedProxies []*net.IPNet) func(handler http.Handler) http.Handler {
+	trusted := func(ip net.IP) bool {
+		for _, n := range trustedProxies {
+			if n.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			host, _, err := net.SplitHostPort(request.RemoteAddr)
+			if err != nil {
+				host = request.RemoteAddr
+			}
+			if remote := net.ParseIP(host); remote != nil && trusted(remote) {
+				if ip := clientIP(request.Header, trusted); ip != nil {
+					request.RemoteAddr = ip.String()
+				}
+			}
+			handler.ServeHTTP(writer, request)
+		})
+	}
+}
+
+func clientIP(header http.Header, trusted func(ip net.IP) bool) net.IP {
+	var forwarded []string
+	for _, h := range header.Values("X-Forwarded-For") {
+		forwarded = append(forwarded, strings.Split(h, ",")...)
+	}
+	var ip net.IP
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		ip = net.ParseIP(strings.TrimSpace(forwarded[i]))
+		if ip == nil {
+			// The headers before a malformed address can not be trusted.
+			return nil
+		}
+		if !trusted(ip) {
+			return ip
+		}
+	}
+	if ip != nil {
+		return ip
+	}
+	return net.ParseIP(strings.TrimSpace(header.Get("X-Real-IP")))
+}
+
+// ParseCIDRs parses the IP networks accepted by RealIP. Both CIDR notations
+// and single IP addresses are accepted.
+func ParseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, cidr := range cidrs {
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address %s", cidr)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
@@ -0,0 +1,197 @@
+package srvhttp
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestID(t *testing.T) {
+	var seen string
+	handler := RequestID("X-Request-Id")(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		seen = RequestIDFrom(request.Context())
+	}))
+
+	t.Run("generated", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Len(t, seen, 32)
+		assert.Equal(t, seen, w.Header().Get("X-Request-Id"))
+	})
+
+	t.Run("propagated", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("X-Request-Id", "foo")
+		handler.ServeHTTP(w, r)
+		assert.Equal(t, "foo", seen)
+		assert.Equal(t, "foo", w.Header().Get("X-Request-Id"))
+	})
+
+	for _, id := range []string{strings.Repeat("a", 129), "foo bar", "foo\x00", "<script>"} {
+		t.Run("replaced", func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("X-Request-Id", id)
+			handler.ServeHTTP(w, r)
+			assert.Len(t, seen, 32)
+			assert.Equal(t, seen, w.Header().Get("X-Request-Id"))
+		})
+	}
+}
+
+func TestRecover(t *testing.T) {
+	handler := Recover(log.NewNopLogger())(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		panic("boom")
+	}))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.JSONEq(t, `{"code":13,"message":"internal server error"}`, w.Body.String())
+
+	t.Run("written", func(t *testing.T) {
+		handler := Recover(log.NewNopLogger())(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			writer.WriteHeader(http.StatusAccepted)
+			writer.Write([]byte("partial"))
+			panic("boom")
+		}))
+		w := httptest.NewRecorder()
+		assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		})
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		assert.Equal(t, "partial", w.Body.String())
+	})
+
+	t.Run("abort", func(t *testing.T) {
+		handler := Recover(log.NewNopLogger())(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			panic(http.ErrAbortHandler)
+		}))
+		assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		})
+	})
+}
+
+func TestCORS(t *testing.T) {
+	handler := CORS(CORSOptions{AllowedOrigins: []string{"https://example.com"}})(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {}))
+
+	r := httptest.NewRequest(http.MethodOptions, "/", nil)
+	r.Header.Set("Origin", "https://example.com")
+	r.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "https://example.com", w.Header().Get("Access-Control-Allow-Origin"))
+
+	r.Header.Set("Origin", "https://evil.com")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestBodyLimit(t *testing.T) {
+	handler := BodyLimit(4)(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		_, err := ioutil.ReadAll(request.Body)
+		if err != nil {
+			writer.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("four")))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("fives")))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.JSONEq(t, `{"code":3,"message":"request body exceeds 4 bytes"}`, w.Body.String())
+
+	// The length is unknown until the body is read.
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("fives"))
+	r.ContentLength = -1
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestTimeout(t *testing.T) {
+	handler := Timeout(10 * time.Millisecond)(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		select {
+		case <-request.Context().Done():
+			writer.WriteHeader(http.StatusGatewayTimeout)
+		case <-time.After(time.Second):
+		}
+	}))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+}
+
+func TestRealIP(t *testing.T) {
+	trusted, err := ParseCIDRs([]string{"10.0.0.0/8", "192.168.1.1"})
+	assert.NoError(t, err)
+	var remote string
+	handler := RealIP(trusted)(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		remote = request.RemoteAddr
+	}))
+
+	cases := []struct {
+		name       string
+		remoteAddr string
+		header     http.Header
+		expected   string
+	}{
+		{
+			"untrusted proxy",
+			"1.1.1.1:1234",
+			http.Header{"X-Forwarded-For": {"2.2.2.2"}},
+			"1.1.1.1:1234",
+		},
+		{
+			"rightmost untrusted",
+			"10.0.0.1:1234",
+			http.Header{"X-Forwarded-For": {"3.3.3.3, 2.2.2.2", "192.168.1.1"}},
+			"2.2.2.2",
+		},
+		{
+			"all trusted",
+			"10.0.0.1:1234",
+			http.Header{"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"}},
+			"10.0.0.3",
+		},
+		{
+			"real ip",
+			"192.168.1.1:1234",
+			http.Header{"X-Real-Ip": {"2.2.2.2"}},
+			"2.2.2.2",
+		},
+		{
+			"malformed",
+			"10.0.0.1:1234",
+			http.Header{"X-Forwarded-For": {"2.2.2.2, foo"}},
+			"10.0.0.1:1234",
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = c.remoteAddr
+			r.Header = c.header
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+			assert.Equal(t, c.expected, remote)
+		})
+	}
+
+	t.Run("invalid cidr", func(t *testing.T) {
+		_, err := ParseCIDRs([]string{"foo"})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,97 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ConstructorTiming is the execution of a constructor provided to the core.
+type ConstructorTiming struct {
+	// Name is the package qualified name of the constructor.
+	Name string
+	// Location is the file and line where the constructor is defined.
+	Location string
+	// Duration is the time spent in the constructor, excluding the construction
+	// of its dependencies.
+	Duration time.Duration
+	// Err is the error returned by the constructor, if any.
+	Err error
+}
+
+// StartupReport records the execution of every constructor provided to the
+// core, as they are called by Invoke and AddModuleFunc. It is available in the
+// dependency graph, and the serve command logs it at debug level and
+// dispatches it with the OnStartupReport event before serving, so that slow
+// boots can be diagnosed.
+type StartupReport struct {
+	mu      sync.Mutex
+	timings []ConstructorTiming
+}
+
+func (r *StartupReport) record(timing ConstructorTiming) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.timings = append(r.timings, timing)
+}
+
+// Timings returns the constructors executed so far, in the order they
+// returned.
+func (r *StartupReport) Timings() []ConstructorTiming {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]ConstructorTiming(nil), r.timings...)
+}
+
+// Total returns the time spent in the constructors executed so far.
+func (r *StartupReport) Total() time.Duration {
+	var total time.Duration
+	for _, timing := range r.Timings() {
+		total += timing.Duration
+	}
+	return total
+}
+
+// Slowest returns the n slowest constructors executed so far, the slowest
+// first. It returns all of them if n is not positive.
+func (r *StartupReport) Slowest(n int) []ConstructorTiming {
+	timings := r.Timings()
+	sort.SliceStable(timings, func(i, j int) bool {
+		return timings[i].Duration > timings[j].Duration
+	})
+	if n > 0 && n < len(timings) {
+		timings = timings[:n]
+	}
+	return timings
+}
+
+// WriteText writes a human readable report, the slowest constructors first.
+func (r *StartupReport) WriteText(w io.Writer) error {
+	var (
+		timings = r.Slowest(0)
+		failed  int
+	)
+	for _, timing := range timings {
+		if timing.Err != nil {
+			failed++
+		}
+	}
+	if _, err := fmt.Fprintf(w, "%d constructors took %s, %d failed\n", len(timings), r.Total(), failed); err != nil {
+		return err
+	}
+	for _, timing := range timings {
+		line := fmt.Sprintf("%12s  %s", timing.Duration, timing.Name)
+		if timing.Location != "" {
+			line += fmt.Sprintf(" (%s)", timing.Location)
+		}
+		if timing.Err != nil {
+			line += fmt.Sprintf(": %s", timing.Err)
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/di"
+	"github.com/DoNewsCode/core/events"
+	"github.com/stretchr/testify/assert"
+)
+
+type slow struct{}
+
+func provideSlow() slow {
+	time.Sleep(10 * time.Millisecond)
+	return slow{}
+}
+
+type broken struct{}
+
+func provideBroken(s slow) (broken, error) {
+	return broken{}, errors.New("broken on purpose")
+}
+
+func TestStartupReport(t *testing.T) {
+	c := New()
+	c.ProvideEssentials()
+	c.Provide(di.Deps{provideSlow, provideBroken})
+
+	err := func() (err error) {
+		defer func() {
+			err = recover().(error)
+		}()
+		c.Invoke(func(broken) {})
+		return nil
+	}()
+	assert.Contains(t, err.Error(), "core.provideBroken")
+	assert.Contains(t, err.Error(), "broken on purpose")
+	assert.NotContains(t, err.Error(), "makeFuncStub")
+
+	c.Invoke(func(report *StartupReport) {
+		timings := report.Timings()
+		assert.Len(t, timings, 3)
+		assert.Equal(t, "core.provideSlow", report.Slowest(1)[0].Name)
+		assert.GreaterOrEqual(t, int64(report.Total()), int64(10*time.Millisecond))
+
+		var buf bytes.Buffer
+		assert.NoError(t, report.WriteText(&buf))
+		assert.Contains(t, buf.String(), "3 constructors took")
+		assert.Contains(t, buf.String(), "1 failed")
+		assert.Contains(t, buf.String(), "core.provideBroken")
+	})
+}
+
+func TestStartupReport_event(t *testing.T) {
+	var called bool
+	c := New(
+		WithInline("http.disable", true),
+		WithInline("grpc.disable", true),
+		WithInline("cron.disable", true),
+		WithInline("log.level", "none"),
+	)
+	c.ProvideEssentials()
+	c.Provide(di.Deps{provideSlow})
+	c.Invoke(func(slow) {})
+	c.Invoke(func(dispatcher contract.Dispatcher) {
+		dispatcher.Subscribe(events.Listen(OnStartupReport, func(ctx context.Context, event interface{}) error {
+			called = true
+			assert.Equal(t, "core.provideSlow", event.(OnStartupReportPayload).Report.Slowest(1)[0].Name)
+			return nil
+		}))
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.NoError(t, c.Serve(ctx))
+	assert.True(t, called)
+}
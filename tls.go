@@ -0,0 +1,305 @@
+package core
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/DoNewsCode/core/config/watcher"
+	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/events"
+	"github.com/DoNewsCode/core/logging"
+)
+
+// TLSConfig is the TLS configuration of the built-in servers, found under
+// "http.tls" and "grpc.tls":
+//
+//  http:
+//    tls:
+//      certFile: /etc/tls/tls.crt
+//      keyFile: /etc/tls/tls.key
+//      clientCAFile: /etc/tls/ca.crt
+//      minVersion: "1.2"
+//      cipherSuites: [TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256]
+//
+// TLS is enabled when certFile is set. If clientCAFile is set as well, the
+// server requires client certificates signed by one of its CAs (mTLS). The
+// certificates are reloaded when the files change or when the config is
+// reloaded, without restarting the listener. The files should be replaced
+// atomically, for example by renaming. If they are written in place instead,
+// a failed reload is retried for a few seconds until the cert and the key
+// match again.
+type TLSConfig struct {
+	// CertFile is the PEM encoded certificate chain of the server.
+	CertFile string `json:"certFile" yaml:"certFile"`
+	// KeyFile is the PEM encoded private key of the server.
+	KeyFile string `json:"keyFile" yaml:"keyFile"`
+	// ClientCAFile is the PEM encoded CAs used to verify client certificates.
+	ClientCAFile string `json:"clientCAFile" yaml:"clientCAFile"`
+	// MinVersion is the minimum TLS version, one of 1.0, 1.1, 1.2 and 1.3.
+	// Defaults to 1.2.
+	MinVersion string `json:"minVersion" yaml:"minVersion"`
+	// CipherSuites are the names of the enabled cipher suites for TLS 1.2 and
+	// below, such as TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256. Defaults to the
+	// cipher suites of crypto/tls. The insecure ones listed by
+	// tls.InsecureCipherSuites are rejected.
+	CipherSuites []string `json:"cipherSuites" yaml:"cipherSuites"`
+}
+
+// Enabled returns true if the certificate is configured.
+func (t TLSConfig) Enabled() bool {
+	return t.CertFile != ""
+}
+
+// Build loads the files and returns the resulting tls.Config.
+func (t TLSConfig) Build() (*tls.Config, error) {
+	if t.CertFile == "" || t.KeyFile == "" {
+		return nil, fmt.Errorf("both certFile and keyFile must be provided")
+	}
+	cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the key pair: %w", err)
+	}
+	conf := &tls.Config{Certificates: []tls.Certificate{cert}}
+
+	if conf.MinVersion, err = tlsVersion(t.MinVersion); err != nil {
+		return nil, err
+	}
+	if conf.CipherSuites, err = tlsCipherSuites(t.CipherSuites); err != nil {
+		return nil, err
+	}
+	if t.ClientCAFile != "" {
+		pem, err := ioutil.ReadFile(t.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the client CAs: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no valid certificate found in %s", t.ClientCAFile)
+		}
+		conf.ClientCAs = pool
+		conf.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return conf, nil
+}
+
+func (t TLSConfig) files() []string {
+	files := []string{t.CertFile, t.KeyFile}
+	if t.ClientCAFile != "" {
+		files = append(files, t.ClientCAFile)
+	}
+	return files
+}
+
+func tlsVersion(version string) (uint16, error) {
+	switch version {
+	case "1.0":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("minVersion must be one of 1.0, 1.1, 1.2 or 1.3, got %s", version)
+}
+
+func tlsCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	known := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+	insecure := make(map[string]bool)
+	for _, suite := range tls.InsecureCipherSuites() {
+		insecure[suite.Name] = true
+	}
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		if insecure[name] {
+			return nil, fmt.Errorf("cipher suite %s is insecure", name)
+		}
+		id, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown cipher suite %s", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// tlsReloader serves the latest TLS config of a server to each handshake.
+type tlsReloader struct {
+	key        string
+	nextProtos []string
+	logger     logging.LevelLogger
+
+	mu      sync.Mutex
+	conf    TLSConfig
+	restart chan struct{}
+	current atomic.Value // *tls.Config
+
+	subscribed sync.Once
+	stopped    int32
+}
+
+// newTLSReloader returns nil if TLS is not configured under the key.
+func newTLSReloader(conf contract.ConfigUnmarshaler, key string, nextProtos []string, logger logging.LevelLogger) (*tlsReloader, error) {
+	var tlsConf TLSConfig
+	if err := conf.Unmarshal(key, &tlsConf); err != nil {
+		return nil, fmt.Errorf("%s is not valid: %w", key, err)
+	}
+	if !tlsConf.Enabled() {
+		return nil, nil
+	}
+	r := &tlsReloader{
+		key:        key,
+		nextProtos: nextProtos,
+		logger:     logger,
+		restart:    make(chan struct{}, 1),
+	}
+	if err := r.load(tlsConf); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *tlsReloader) load(tlsConf TLSConfig) error {
+	conf, err := tlsConf.Build()
+	if err != nil {
+		return fmt.Errorf("%s is not valid: %w", r.key, err)
+	}
+	conf.NextProtos = r.nextProtos
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.conf.Enabled() && strings.Join(tlsConf.files(), ",") != strings.Join(r.conf.files(), ",") {
+		select {
+		case r.restart <- struct{}{}:
+		default:
+		}
+	}
+	r.conf = tlsConf
+	r.current.Store(conf)
+	return nil
+}
+
+// tlsReloadRetries and tlsReloadInterval bound the retries of a failed
+// reload, as the files may be in the middle of being written.
+var (
+	tlsReloadRetries  = 20
+	tlsReloadInterval = 100 * time.Millisecond
+)
+
+// reload reloads the files of the current config. The previous certificate
+// stays in use if they are not valid, for example when only one of the cert
+// and the key has been replaced so far, or a file is half written. The reload
+// is retried in the meantime, since the event of the last write may be missed.
+func (r *tlsReloader) reload(ctx context.Context) error {
+	var err error
+	for i := 0; i <= tlsReloadRetries; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(tlsReloadInterval):
+			}
+		}
+		r.mu.Lock()
+		tlsConf := r.conf
+		r.mu.Unlock()
+		if err = r.load(tlsConf); err == nil {
+			r.logger.Infof("%s reloaded", r.key)
+			return nil
+		}
+	}
+	r.logger.Warnf("failed to reload %s: %s", r.key, err)
+	return nil
+}
+
+// tlsConfig returns the config used by the listener. Every handshake picks up
+// the latest certificates.
+func (r *tlsReloader) tlsConfig() *tls.Config {
+	return &tls.Config{
+		NextProtos: r.nextProtos,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return r.current.Load().(*tls.Config), nil
+		},
+	}
+}
+
+// start reloads the certificates on changes until stop is called.
+func (r *tlsReloader) start(ctx context.Context, dispatcher contract.Dispatcher) (stop func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	atomic.StoreInt32(&r.stopped, 0)
+	if dispatcher != nil {
+		r.subscribed.Do(func() { r.subscribe(dispatcher) })
+	}
+	go r.watch(ctx)
+	return func() {
+		atomic.StoreInt32(&r.stopped, 1)
+		cancel()
+	}
+}
+
+// subscribe reloads the config when the key is changed by a config reload.
+// Invalid configs are ignored. The dispatcher doesn't support unsubscribing,
+// so the listener is turned off once the reloader is stopped instead. Each
+// serve run has its own reloader, so a dispatcher outliving the run keeps one
+// idle listener per run and key.
+func (r *tlsReloader) subscribe(dispatcher contract.Dispatcher) {
+	dispatcher.Subscribe(events.Listen(events.OnReload, func(_ context.Context, event interface{}) error {
+		payload, ok := event.(events.OnReloadPayload)
+		if !ok || atomic.LoadInt32(&r.stopped) == 1 || payload.NewConf == nil || !payload.Diff.HasChanged(r.key) {
+			return nil
+		}
+		var tlsConf TLSConfig
+		if err := payload.NewConf.Unmarshal(r.key, &tlsConf); err != nil || !tlsConf.Enabled() {
+			r.logger.Warnf("ignoring the reloaded %s: TLS can not be disabled or misconfigured without a restart", r.key)
+			return nil
+		}
+		if err := r.load(tlsConf); err != nil {
+			r.logger.Warnf("ignoring the reloaded %s: %s", r.key, err)
+			return nil
+		}
+		r.logger.Infof("%s reloaded", r.key)
+		return nil
+	}))
+}
+
+// watch reloads the certificates when the files change, until ctx is done.
+func (r *tlsReloader) watch(ctx context.Context) {
+	for {
+		r.mu.Lock()
+		files := r.conf.files()
+		r.mu.Unlock()
+
+		var w watcher.Multi
+		for _, file := range files {
+			w = append(w, watcher.File{Path: file})
+		}
+		watchCtx, cancel := context.WithCancel(ctx)
+		go func() {
+			if err := w.Watch(watchCtx, func() error { return r.reload(watchCtx) }); err != nil {
+				r.logger.Warnf("failed to watch %s: %s", r.key, err)
+			}
+		}()
+
+		select {
+		case <-ctx.Done():
+			cancel()
+			return
+		case <-r.restart:
+			cancel()
+		}
+	}
+}
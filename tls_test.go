@@ -0,0 +1,414 @@
+package core
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DoNewsCode/core/config"
+	"github.com/DoNewsCode/core/contract"
+	"github.com/DoNewsCode/core/events"
+	"github.com/DoNewsCode/core/logging"
+	"github.com/DoNewsCode/core/srvgrpc"
+	"github.com/DoNewsCode/core/srvhttp"
+	"github.com/go-kit/kit/log"
+	"github.com/knadh/koanf/providers/confmap"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+type testCert struct {
+	cert     *x509.Certificate
+	key      *ecdsa.PrivateKey
+	certFile string
+	keyFile  string
+}
+
+// writeCert writes a certificate for localhost signed by the parent, or a
+// self signed CA if parent is nil.
+func writeCert(t *testing.T, dir, name string, serial int64, parent *testCert) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	assert.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+
+	c := &testCert{
+		cert:     cert,
+		key:      key,
+		certFile: filepath.Join(dir, name+".crt"),
+		keyFile:  filepath.Join(dir, name+".key"),
+	}
+	writeFile(t, c.keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	writeFile(t, c.certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	return c
+}
+
+// writeFile replaces the file atomically, as certificates are rotated in
+// practice.
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	assert.NoError(t, ioutil.WriteFile(path+".tmp", data, os.ModePerm))
+	assert.NoError(t, os.Rename(path+".tmp", path))
+}
+
+func TestTLSConfig_Build(t *testing.T) {
+	dir := t.TempDir()
+	ca := writeCert(t, dir, "ca", 1, nil)
+	server := writeCert(t, dir, "server", 2, ca)
+
+	cases := []struct {
+		name   string
+		conf   TLSConfig
+		assert func(t *testing.T, conf *tls.Config, err error)
+	}{
+		{
+			"tls",
+			TLSConfig{CertFile: server.certFile, KeyFile: server.keyFile},
+			func(t *testing.T, conf *tls.Config, err error) {
+				assert.NoError(t, err)
+				assert.Len(t, conf.Certificates, 1)
+				assert.Equal(t, uint16(tls.VersionTLS12), conf.MinVersion)
+				assert.Equal(t, tls.NoClientCert, conf.ClientAuth)
+			},
+		},
+		{
+			"mtls",
+			TLSConfig{
+				CertFile:     server.certFile,
+				KeyFile:      server.keyFile,
+				ClientCAFile: ca.certFile,
+				MinVersion:   "1.3",
+				CipherSuites: []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"},
+			},
+			func(t *testing.T, conf *tls.Config, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, uint16(tls.VersionTLS13), conf.MinVersion)
+				assert.Equal(t, []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256}, conf.CipherSuites)
+				assert.Equal(t, tls.RequireAndVerifyClientCert, conf.ClientAuth)
+				assert.NotNil(t, conf.ClientCAs)
+			},
+		},
+		{
+			"missing key",
+			TLSConfig{CertFile: server.certFile},
+			func(t *testing.T, conf *tls.Config, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			"mismatched key",
+			TLSConfig{CertFile: server.certFile, KeyFile: ca.keyFile},
+			func(t *testing.T, conf *tls.Config, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			"invalid version",
+			TLSConfig{CertFile: server.certFile, KeyFile: server.keyFile, MinVersion: "2"},
+			func(t *testing.T, conf *tls.Config, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			"unknown cipher suite",
+			TLSConfig{CertFile: server.certFile, KeyFile: server.keyFile, CipherSuites: []string{"foo"}},
+			func(t *testing.T, conf *tls.Config, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			"insecure cipher suite",
+			TLSConfig{CertFile: server.certFile, KeyFile: server.keyFile, CipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}},
+			func(t *testing.T, conf *tls.Config, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			"invalid client CA",
+			TLSConfig{CertFile: server.certFile, KeyFile: server.keyFile, ClientCAFile: server.keyFile},
+			func(t *testing.T, conf *tls.Config, err error) {
+				assert.Error(t, err)
+			},
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			conf, err := c.conf.Build()
+			c.assert(t, conf, err)
+		})
+	}
+}
+
+func serialOf(t *testing.T, r *tlsReloader) int64 {
+	t.Helper()
+	conf, err := r.tlsConfig().GetConfigForClient(&tls.ClientHelloInfo{})
+	assert.NoError(t, err)
+	cert, err := x509.ParseCertificate(conf.Certificates[0].Certificate[0])
+	assert.NoError(t, err)
+	return cert.SerialNumber.Int64()
+}
+
+func newTestConfig(t *testing.T, values map[string]interface{}) *config.KoanfAdapter {
+	t.Helper()
+	conf, err := config.NewConfig(config.WithProviderLayer(confmap.Provider(values, "."), nil))
+	assert.NoError(t, err)
+	return conf
+}
+
+func TestTLSReloader(t *testing.T) {
+	dir := t.TempDir()
+	ca := writeCert(t, dir, "ca", 1, nil)
+	writeCert(t, dir, "server", 2, ca)
+
+	dispatcher := &events.SyncDispatcher{}
+	conf := config.MapAdapter{"tls": map[string]interface{}{
+		"certFile": filepath.Join(dir, "server.crt"),
+		"keyFile":  filepath.Join(dir, "server.key"),
+	}}
+	r, err := newTLSReloader(conf, "tls", []string{"h2"}, logging.WithLevel(log.NewNopLogger()))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), serialOf(t, r))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stop := r.start(ctx, dispatcher)
+	defer stop()
+	time.Sleep(100 * time.Millisecond)
+
+	t.Run("file change", func(t *testing.T) {
+		writeCert(t, dir, "server", 3, ca)
+		assert.Eventually(t, func() bool { return serialOf(t, r) == 3 }, 5*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("non-atomic write", func(t *testing.T) {
+		// Write the key and the cert in place, chunk by chunk, as a cert
+		// manager without renames would.
+		generated := writeCert(t, t.TempDir(), "server", 6, ca)
+		for _, pair := range [][2]string{
+			{generated.keyFile, filepath.Join(dir, "server.key")},
+			{generated.certFile, filepath.Join(dir, "server.crt")},
+		} {
+			data, err := ioutil.ReadFile(pair[0])
+			assert.NoError(t, err)
+			f, err := os.OpenFile(pair[1], os.O_WRONLY|os.O_TRUNC, 0)
+			assert.NoError(t, err)
+			for i := 0; i < len(data); i += 16 {
+				end := i + 16
+				if end > len(data) {
+					end = len(data)
+				}
+				_, err = f.Write(data[i:end])
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, f.Close())
+		}
+		assert.Eventually(t, func() bool { return serialOf(t, r) == 6 }, 5*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("config reload", func(t *testing.T) {
+		renewed := writeCert(t, dir, "renewed", 4, ca)
+		dispatcher.Dispatch(ctx, events.OnReload, events.OnReloadPayload{
+			NewConf: newTestConfig(t, map[string]interface{}{
+				"tls.certFile":   renewed.certFile,
+				"tls.keyFile":    renewed.keyFile,
+				"tls.minVersion": "1.3",
+			}),
+		})
+		assert.Equal(t, int64(4), serialOf(t, r))
+		conf, _ := r.tlsConfig().GetConfigForClient(&tls.ClientHelloInfo{})
+		assert.Equal(t, uint16(tls.VersionTLS13), conf.MinVersion)
+		assert.Equal(t, []string{"h2"}, conf.NextProtos)
+
+		// The new files are watched from now on.
+		time.Sleep(100 * time.Millisecond)
+		writeCert(t, dir, "renewed", 5, ca)
+		assert.Eventually(t, func() bool { return serialOf(t, r) == 5 }, 5*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("invalid reload", func(t *testing.T) {
+		dispatcher.Dispatch(ctx, events.OnReload, events.OnReloadPayload{
+			NewConf: newTestConfig(t, map[string]interface{}{
+				"tls.certFile": filepath.Join(dir, "missing.crt"),
+				"tls.keyFile":  filepath.Join(dir, "missing.key"),
+			}),
+		})
+		assert.Equal(t, int64(5), serialOf(t, r))
+	})
+}
+
+// subscriptionCounter counts the subscriptions to a dispatcher.
+type subscriptionCounter struct {
+	events.SyncDispatcher
+	subscriptions int
+}
+
+func (d *subscriptionCounter) Subscribe(listener contract.Listener) {
+	d.subscriptions++
+	d.SyncDispatcher.Subscribe(listener)
+}
+
+func TestTLSReloader_restart(t *testing.T) {
+	dir := t.TempDir()
+	ca := writeCert(t, dir, "ca", 1, nil)
+	writeCert(t, dir, "server", 2, ca)
+	renewed := writeCert(t, dir, "renewed", 3, ca)
+
+	dispatcher := &subscriptionCounter{}
+	conf := config.MapAdapter{"tls": map[string]interface{}{
+		"certFile": filepath.Join(dir, "server.crt"),
+		"keyFile":  filepath.Join(dir, "server.key"),
+	}}
+	r, err := newTLSReloader(conf, "tls", []string{"h2"}, logging.WithLevel(log.NewNopLogger()))
+	assert.NoError(t, err)
+
+	reload := events.OnReloadPayload{
+		NewConf: newTestConfig(t, map[string]interface{}{
+			"tls.certFile": renewed.certFile,
+			"tls.keyFile":  renewed.keyFile,
+		}),
+	}
+	r.start(context.Background(), dispatcher)()
+	r.start(context.Background(), dispatcher)()
+	assert.Equal(t, 1, dispatcher.subscriptions)
+
+	// Stopped reloaders ignore config reloads.
+	dispatcher.Dispatch(context.Background(), events.OnReload, reload)
+	assert.Equal(t, int64(2), serialOf(t, r))
+
+	stop := r.start(context.Background(), dispatcher)
+	defer stop()
+	assert.Equal(t, 1, dispatcher.subscriptions)
+	dispatcher.Dispatch(context.Background(), events.OnReload, reload)
+	assert.Equal(t, int64(3), serialOf(t, r))
+
+	r, err = newTLSReloader(conf, "tls", []string{"h2"}, logging.WithLevel(log.NewNopLogger()))
+	assert.NoError(t, err)
+	r.start(context.Background(), nil)()
+}
+
+func TestC_Serve_mTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := writeCert(t, dir, "ca", 1, nil)
+	server := writeCert(t, dir, "server", 2, ca)
+	client := writeCert(t, dir, "client", 3, ca)
+
+	c := New(
+		WithInline("http.addr", "127.0.0.1:0"),
+		WithInline("http.tls.certFile", server.certFile),
+		WithInline("http.tls.keyFile", server.keyFile),
+		WithInline("http.tls.clientCAFile", ca.certFile),
+		WithInline("grpc.addr", "127.0.0.1:0"),
+		WithInline("grpc.tls.certFile", server.certFile),
+		WithInline("grpc.tls.keyFile", server.keyFile),
+		WithInline("grpc.tls.clientCAFile", ca.certFile),
+		WithInline("cron.disable", true),
+		WithInline("log.level", "none"),
+	)
+	c.ProvideEssentials()
+	c.AddModule(srvhttp.HealthCheckModule{})
+	c.AddModule(srvgrpc.HealthCheckModule{})
+
+	addr, grpcAddr := make(chan string, 1), make(chan string, 1)
+	c.Invoke(func(dispatcher contract.Dispatcher) {
+		dispatcher.Subscribe(events.Listen(OnHTTPServerStart, func(ctx context.Context, start interface{}) error {
+			addr <- start.(OnHTTPServerStartPayload).Listener.Addr().String()
+			return nil
+		}))
+		dispatcher.Subscribe(events.Listen(OnGRPCServerStart, func(ctx context.Context, start interface{}) error {
+			grpcAddr <- start.(OnGRPCServerStartPayload).Listener.Addr().String()
+			return nil
+		}))
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Serve(ctx)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	clientCert, err := tls.LoadX509KeyPair(client.certFile, client.keyFile)
+	assert.NoError(t, err)
+	var url string
+	select {
+	case a := <-addr:
+		_, port, _ := net.SplitHostPort(a)
+		url = "https://localhost:" + port + "/live"
+	case <-time.After(5 * time.Second):
+		t.Fatal("the http server did not start")
+	}
+
+	t.Run("with client certificate", func(t *testing.T) {
+		httpClient := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			RootCAs:      pool,
+			Certificates: []tls.Certificate{clientCert},
+		}}}
+		resp, err := httpClient.Get(url)
+		assert.NoError(t, err)
+		if err == nil {
+			resp.Body.Close()
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+		}
+	})
+
+	t.Run("without client certificate", func(t *testing.T) {
+		httpClient := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			RootCAs: pool,
+		}}}
+		_, err := httpClient.Get(url)
+		assert.Error(t, err)
+	})
+
+	t.Run("grpc", func(t *testing.T) {
+		var target string
+		select {
+		case a := <-grpcAddr:
+			_, port, _ := net.SplitHostPort(a)
+			target = "localhost:" + port
+		case <-time.After(5 * time.Second):
+			t.Fatal("the gRPC server did not start")
+		}
+		creds := credentials.NewTLS(&tls.Config{RootCAs: pool, Certificates: []tls.Certificate{clientCert}})
+		conn, err := grpc.Dial(target, grpc.WithTransportCredentials(creds))
+		assert.NoError(t, err)
+		defer conn.Close()
+		health, err := grpc_health_v1.NewHealthClient(conn).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+		assert.NoError(t, err)
+		assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, health.GetStatus())
+	})
+}